@@ -0,0 +1,140 @@
+// Command train-classifier trains the Naive Bayes classifier from a labeled
+// version of the conversation corpus and writes the model to disk.
+//
+//    go run ./cmd/train-classifier -bootstrap   # label data/conversations.txt with the rule classifier first
+//    go run ./cmd/train-classifier              # train from data/labeled_conversations.tsv
+//
+// The bootstrapped labels are silver labels: they imitate the rule classifier, so accuracy
+// on them says little. Accuracy is reported on the hand-labelled -eval set instead.
+package main
+
+import (
+    "context"
+    "flag"
+    "fmt"
+    "log"
+    "sort"
+    "strings"
+
+    "customer-query-router/services"
+)
+
+func main() {
+    conversationsFile := flag.String("conversations", "data/conversations.txt", "raw conversation transcripts")
+    labelsFile := flag.String("labels", "data/labeled_conversations.tsv", "labeled examples (intent<TAB>text)")
+    modelFile := flag.String("out", "data/bayes_model.json", "where to write the trained model")
+    bootstrap := flag.Bool("bootstrap", false, "label the raw conversations with the rule classifier and overwrite -labels")
+    taxonomyFile := flag.String("taxonomy", "data/taxonomy.json", "intent taxonomy the labels must match")
+    evalFile := flag.String("eval", "data/eval_conversations.tsv", "hand-labelled held-out examples to measure accuracy on (empty skips)")
+    flag.Parse()
+
+    taxonomy, err := services.LoadTaxonomy(*taxonomyFile)
+    if err != nil {
+        log.Fatal("Failed to load taxonomy:", err)
+    }
+
+    if *bootstrap {
+        if err := bootstrapLabels(*conversationsFile, *labelsFile, taxonomy); err != nil {
+            log.Fatal("Failed to bootstrap labels:", err)
+        }
+    }
+
+    examples, err := services.LoadLabeledExamples(*labelsFile)
+    if err != nil {
+        log.Fatal("Failed to load labels:", err)
+    }
+
+    model, err := services.TrainBayesModel(examples)
+    if err != nil {
+        log.Fatal("Failed to train model:", err)
+    }
+
+    classifier, err := services.NewBayesClassifier(model, taxonomy)
+    if err != nil {
+        log.Fatal("Invalid model:", err)
+    }
+
+    if err := services.SaveBayesModel(model, *modelFile); err != nil {
+        log.Fatal("Failed to save model:", err)
+    }
+
+    // Resubstitution accuracy is optimistic, but catches broken labels quickly
+    trainingAccuracy := accuracy(classifier, examples)
+
+    var evalExamples []services.LabeledExample
+    if *evalFile != "" {
+        evalExamples, err = services.LoadLabeledExamples(*evalFile)
+        if err != nil {
+            log.Fatal("Failed to load evaluation set:", err)
+        }
+    }
+
+    intents := make([]string, 0, len(model.DocCounts))
+    for intent := range model.DocCounts {
+        intents = append(intents, intent)
+    }
+    sort.Strings(intents)
+
+    fmt.Printf("Trained on %d examples, vocabulary of %d tokens\n", model.TotalDocs, len(model.Vocabulary))
+    for _, intent := range intents {
+        fmt.Printf("  %-32s %d\n", intent, model.DocCounts[intent])
+    }
+    fmt.Printf("Training accuracy: %.1f%% (on the training labels, optimistic)\n", 100*trainingAccuracy)
+    if len(evalExamples) > 0 {
+        fmt.Printf("Held-out accuracy: %.1f%% on %d hand-labelled examples from %s\n",
+            100*accuracy(classifier, evalExamples), len(evalExamples), *evalFile)
+    }
+    fmt.Printf("Model written to %s\n", *modelFile)
+}
+
+// accuracy is the share of examples the classifier labels with their intent
+func accuracy(classifier services.Classifier, examples []services.LabeledExample) float64 {
+    if len(examples) == 0 {
+        return 0
+    }
+    correct := 0
+    for _, example := range examples {
+        result, err := classifier.ClassifyQuery(context.Background(), example.Text)
+        if err == nil && result.Intent == example.Intent {
+            correct++
+        }
+    }
+    return float64(correct) / float64(len(examples))
+}
+
+// bootstrapLabels weakly labels every conversation with the rule classifier.
+// The output is meant to be reviewed and corrected by hand before retraining.
+func bootstrapLabels(conversationsFile, labelsFile string, taxonomy *services.Taxonomy) error {
+    conversationService := services.NewConversationService()
+    if err := conversationService.LoadConversations(conversationsFile); err != nil {
+        return err
+    }
+
+    rules, err := services.NewRuleClassifier(services.DefaultRules(), taxonomy)
+    if err != nil {
+        return err
+    }
+
+    examples := []services.LabeledExample{}
+    for _, conversation := range conversationService.GetConversations() {
+        messages := conversationService.GetCustomerMessages(conversation)
+        if len(messages) == 0 {
+            continue
+        }
+
+        // The opening customer line states the problem, the rest adds vocabulary
+        result, err := rules.ClassifyQuery(context.Background(), messages[0])
+        if err != nil || result.Intent == "general" {
+            continue
+        }
+        examples = append(examples, services.LabeledExample{
+            Intent: result.Intent,
+            Text:   strings.Join(messages, " "),
+        })
+    }
+
+    fmt.Printf("Labeled %d of %d conversations\n", len(examples), len(conversationService.GetConversations()))
+    return services.SaveLabeledExamples(examples, labelsFile,
+        "SILVER LABELS: bootstrapped from "+conversationsFile+" with the rule classifier and not reviewed.",
+        "Accuracy measured on these labels is circular; evaluate on data/eval_conversations.tsv instead.")
+}
\ No newline at end of file
@@ -2,4 +2,4 @@ module customer-query-router
 
 go 1.24.5
 
-require github.com/sashabaranov/go-openai v1.40.5 // indirect
+require github.com/sashabaranov/go-openai v1.40.5
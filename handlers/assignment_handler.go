@@ -0,0 +1,105 @@
+package handlers
+
+import (
+    "encoding/json"
+    "errors"
+    "net/http"
+    "customer-query-router/models"
+    "customer-query-router/services"
+)
+
+type AssignmentHandler struct {
+    assignments *services.AssignmentService
+}
+
+func NewAssignmentHandler(assignments *services.AssignmentService) *AssignmentHandler {
+    return &AssignmentHandler{
+        assignments: assignments,
+    }
+}
+
+// assignmentRequest names the assignment to act on. AgentID is the transfer target,
+// empty to let routing pick the best other agent.
+type assignmentRequest struct {
+    AssignmentID string `json:"assignment_id"`
+    AgentID      string `json:"agent_id"`
+}
+
+// assignmentErrorStatus maps lifecycle errors to 404 and 409, anything else to fallback
+func assignmentErrorStatus(err error, fallback int) int {
+    switch {
+    case errors.Is(err, services.ErrAssignmentNotFound):
+        return http.StatusNotFound
+    case errors.Is(err, services.ErrAssignmentState):
+        return http.StatusConflict
+    default:
+        return fallback
+    }
+}
+
+// GetAssignments lists assignments, newest first. Filter with ?status= and ?agent_id=.
+func (ah *AssignmentHandler) GetAssignments(w http.ResponseWriter, r *http.Request) {
+    response := map[string]interface{}{
+        "assignments": ah.assignments.List(r.URL.Query().Get("status"), r.URL.Query().Get("agent_id")),
+        "stats":       ah.assignments.Stats(),
+    }
+
+    w.Header().Set("Content-Type", "application/json")
+    json.NewEncoder(w).Encode(response)
+}
+
+// AcceptAssignment records that the agent took up a pending assignment
+func (ah *AssignmentHandler) AcceptAssignment(w http.ResponseWriter, r *http.Request) {
+    ah.handle(w, r, func(request assignmentRequest) (*models.Assignment, error) {
+        return ah.assignments.Accept(request.AssignmentID)
+    })
+}
+
+// CompleteAssignment closes an accepted assignment and frees the agent's capacity
+func (ah *AssignmentHandler) CompleteAssignment(w http.ResponseWriter, r *http.Request) {
+    ah.handle(w, r, func(request assignmentRequest) (*models.Assignment, error) {
+        return ah.assignments.Complete(request.AssignmentID)
+    })
+}
+
+// AbandonAssignment closes an assignment the agent cannot finish and frees their capacity
+func (ah *AssignmentHandler) AbandonAssignment(w http.ResponseWriter, r *http.Request) {
+    ah.handle(w, r, func(request assignmentRequest) (*models.Assignment, error) {
+        return ah.assignments.Abandon(request.AssignmentID)
+    })
+}
+
+// TransferAssignment moves an assignment to another agent and returns the new assignment
+func (ah *AssignmentHandler) TransferAssignment(w http.ResponseWriter, r *http.Request) {
+    ah.handle(w, r, func(request assignmentRequest) (*models.Assignment, error) {
+        return ah.assignments.Transfer(request.AssignmentID, request.AgentID)
+    })
+}
+
+// handle decodes an assignment request, applies the transition and writes the assignment
+func (ah *AssignmentHandler) handle(w http.ResponseWriter, r *http.Request, transition func(assignmentRequest) (*models.Assignment, error)) {
+    if r.Method != http.MethodPost {
+        http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+        return
+    }
+
+    var request assignmentRequest
+    if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+        http.Error(w, "Invalid JSON", http.StatusBadRequest)
+        return
+    }
+    if request.AssignmentID == "" {
+        writeJSONError(w, http.StatusBadRequest, "assignment_id field is required")
+        return
+    }
+
+    assignment, err := transition(request)
+    if err != nil {
+        // A transfer nobody can take is a capacity problem, like routing
+        writeJSONError(w, assignmentErrorStatus(err, http.StatusServiceUnavailable), err.Error())
+        return
+    }
+
+    w.Header().Set("Content-Type", "application/json")
+    json.NewEncoder(w).Encode(assignment)
+}
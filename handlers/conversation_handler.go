@@ -0,0 +1,117 @@
+package handlers
+
+import (
+    "encoding/json"
+    "net/http"
+    "strings"
+    "customer-query-router/models"
+    "customer-query-router/services"
+)
+
+type ConversationHandler struct {
+    conversationService *services.ConversationService
+    classifier          *services.ConversationClassifier
+    routingService      *services.RoutingService
+}
+
+func NewConversationHandler(conversationService *services.ConversationService, classifier *services.ConversationClassifier, routingService *services.RoutingService) *ConversationHandler {
+    return &ConversationHandler{
+        conversationService: conversationService,
+        classifier:          classifier,
+        routingService:      routingService,
+    }
+}
+
+// conversationRequest carries the turns of a conversation, or a transcript with
+// "Customer:" and "Agent:" lines, and optionally who is handling it now: the current
+// assignment, or the agent and ticket of a conversation routed elsewhere
+type conversationRequest struct {
+    Turns        []models.ConversationTurn `json:"turns"`
+    Transcript   string                    `json:"transcript"`
+    AssignmentID string                    `json:"assignment_id"`
+    AgentID      string                    `json:"agent_id"`
+    TicketID     string                    `json:"ticket_id"`
+}
+
+// decodeConversation reads the request and writes a 4xx response when it is unusable
+func (ch *ConversationHandler) decodeConversation(w http.ResponseWriter, r *http.Request) (*conversationRequest, bool) {
+    if r.Method != http.MethodPost {
+        http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+        return nil, false
+    }
+
+    var request conversationRequest
+    if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+        http.Error(w, "Invalid JSON", http.StatusBadRequest)
+        return nil, false
+    }
+    if len(request.Turns) == 0 && request.Transcript != "" {
+        request.Turns = ch.conversationService.ParseTurns(request.Transcript)
+    }
+
+    hasCustomerTurn := false
+    for _, turn := range request.Turns {
+        if strings.EqualFold(turn.Speaker, services.SpeakerCustomer) {
+            hasCustomerTurn = true
+        }
+    }
+    if !hasCustomerTurn {
+        writeJSONError(w, http.StatusBadRequest, "turns (or transcript) must contain at least one customer turn")
+        return nil, false
+    }
+    return &request, true
+}
+
+// ClassifyConversation returns the overall intent of a conversation and its intent shifts
+func (ch *ConversationHandler) ClassifyConversation(w http.ResponseWriter, r *http.Request) {
+    request, ok := ch.decodeConversation(w, r)
+    if !ok {
+        return
+    }
+
+    conversation, err := ch.classifier.ClassifyConversation(requestContext(r), request.Turns)
+    if err != nil {
+        writeJSONError(w, classificationErrorStatus(err), "Classification failed: "+err.Error())
+        return
+    }
+
+    w.Header().Set("Content-Type", "application/json")
+    json.NewEncoder(w).Encode(conversation)
+}
+
+// RouteConversation classifies a conversation and assigns every open intent the current
+// agent does not cover, transferring the conversation when the customer switched topic
+func (ch *ConversationHandler) RouteConversation(w http.ResponseWriter, r *http.Request) {
+    request, ok := ch.decodeConversation(w, r)
+    if !ok {
+        return
+    }
+
+    conversation, err := ch.classifier.ClassifyConversation(requestContext(r), request.Turns)
+    if err != nil {
+        writeJSONError(w, classificationErrorStatus(err), "Classification failed: "+err.Error())
+        return
+    }
+
+    routing, err := ch.routingService.RouteConversation(conversation, request.AssignmentID, request.AgentID, request.TicketID)
+    if err != nil {
+        writeJSONError(w, assignmentErrorStatus(err, http.StatusBadRequest), err.Error())
+        return
+    }
+
+    w.Header().Set("Content-Type", "application/json")
+    switch {
+    case len(routing.Assignments) > 0:
+    case len(routing.Queued) > 0:
+        w.WriteHeader(http.StatusAccepted)
+    case len(routing.Unassigned) > 0:
+        w.WriteHeader(http.StatusServiceUnavailable)
+    }
+    json.NewEncoder(w).Encode(routing)
+}
+
+func writeJSONError(w http.ResponseWriter, status int, message string) {
+    w.Header().Set("Content-Type", "application/json")
+    w.WriteHeader(status)
+    json.NewEncoder(w).Encode(map[string]string{"error": message})
+}
@@ -0,0 +1,41 @@
+package handlers
+
+import (
+    "encoding/json"
+    "net/http"
+    "customer-query-router/services"
+)
+
+type QueueHandler struct {
+    queue *services.QueueService
+}
+
+func NewQueueHandler(queue *services.QueueService) *QueueHandler {
+    return &QueueHandler{
+        queue: queue,
+    }
+}
+
+// GetQueue lists waiting queries, oldest first, filtered with ?intent=. With ?ticket_id= it
+// returns that ticket's position and estimated wait, or its assignment once dispatched.
+func (qh *QueueHandler) GetQueue(w http.ResponseWriter, r *http.Request) {
+    if ticketID := r.URL.Query().Get("ticket_id"); ticketID != "" {
+        queued, ok := qh.queue.Get(ticketID)
+        if !ok {
+            writeJSONError(w, http.StatusNotFound, "ticket is not queued: "+ticketID)
+            return
+        }
+
+        w.Header().Set("Content-Type", "application/json")
+        json.NewEncoder(w).Encode(queued)
+        return
+    }
+
+    response := map[string]interface{}{
+        "queued": qh.queue.List(r.URL.Query().Get("intent")),
+        "stats":  qh.queue.Stats(),
+    }
+
+    w.Header().Set("Content-Type", "application/json")
+    json.NewEncoder(w).Encode(response)
+}
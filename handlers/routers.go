@@ -2,26 +2,35 @@ package handlers
 
 import (
     "encoding/json"
+    "errors"
     "net/http"
     "customer-query-router/models"
     "customer-query-router/services"
 )
 
 type RouterHandler struct {
-    agentService          *services.AgentService
-    conversationService   *services.ConversationService
-    classificationService *services.ClassificationService
+    agentService        *services.AgentService
+    conversationService *services.ConversationService
+    classifier          services.Classifier
+    routingService      *services.RoutingService
+    entityExtractor     *services.EntityExtractor
+    languageDetector    *services.LanguageDetector
+    queue               *services.QueueService
 }
 
-func NewRouterHandler(agentService *services.AgentService, conversationService *services.ConversationService, classificationService *services.ClassificationService) *RouterHandler {
+func NewRouterHandler(agentService *services.AgentService, queue *services.QueueService, conversationService *services.ConversationService, classifier services.Classifier, routingService *services.RoutingService, entityExtractor *services.EntityExtractor, languageDetector *services.LanguageDetector) *RouterHandler {
     return &RouterHandler{
-        agentService:          agentService,
-        conversationService:   conversationService,
-        classificationService: classificationService,
+        agentService:        agentService,
+        queue:               queue,
+        conversationService: conversationService,
+        classifier:          classifier,
+        routingService:      routingService,
+        entityExtractor:     entityExtractor,
+        languageDetector:    languageDetector,
     }
 }
 
-// ClassifyQuery handles OpenAI-based query classification
+// ClassifyQuery handles query classification with the configured backend
 func (rh *RouterHandler) ClassifyQuery(w http.ResponseWriter, r *http.Request) {
     if r.Method != http.MethodPost {
         http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
@@ -48,27 +57,176 @@ func (rh *RouterHandler) ClassifyQuery(w http.ResponseWriter, r *http.Request) {
         return
     }
 
-    intent, agent, err := rh.classificationService.ClassifyQuery(request.CustomerMessage)
+    result, err := rh.classifier.ClassifyQuery(requestContext(r), request.CustomerMessage)
     if err != nil {
         errorResponse := map[string]string{
             "error": "Classification failed: " + err.Error(),
         }
         w.Header().Set("Content-Type", "application/json")
-        w.WriteHeader(http.StatusInternalServerError)
+        w.WriteHeader(classificationErrorStatus(err))
         json.NewEncoder(w).Encode(errorResponse)
         return
     }
 
     response := map[string]interface{}{
-        "intent":           intent,
-        "recommended_agent": agent,
+        "intent":           result.Intent,
+        "recommended_agent": result.Agent,
+        "confidence":       result.Confidence,
+        "alternatives":     result.Alternatives,
+        "low_confidence":   result.LowConfidence,
+        "backend":          result.Backend,
         "message":          request.CustomerMessage,
     }
+    if result.OriginalIntent != "" {
+        response["original_intent"] = result.OriginalIntent
+    }
+    if len(result.Intents) > 0 {
+        response["intents"] = result.Intents
+    }
+    if result.Rationale != "" {
+        response["rationale"] = result.Rationale
+    }
+    if len(result.Attempts) > 0 {
+        response["attempts"] = result.Attempts
+    }
+    if result.Usage != nil {
+        response["usage"] = result.Usage
+    }
+    if len(result.Entities) > 0 {
+        response["entities"] = result.Entities
+    }
+    if result.Language != "" {
+        response["language"] = result.Language
+        response["language_confidence"] = result.LanguageConfidence
+    }
+    if result.Translation != "" {
+        response["translation"] = result.Translation // "message" stays the original
+    }
+    if result.Priority > 0 {
+        response["sentiment"] = result.Sentiment
+        response["sentiment_score"] = result.SentimentScore
+        response["urgency"] = result.Urgency
+        response["priority"] = result.Priority
+    }
+    if len(result.Redactions) > 0 {
+        response["redactions"] = result.Redactions
+    }
+    if len(result.Votes) > 0 {
+        response["votes"] = result.Votes
+        response["needs_review"] = result.NeedsReview
+    }
+
+    w.Header().Set("Content-Type", "application/json")
+    json.NewEncoder(w).Encode(response)
+}
+
+// classificationErrorStatus maps an unhealthy provider to 503 so clients retry later
+func classificationErrorStatus(err error) int {
+    if errors.Is(err, services.ErrClassifierUnavailable) {
+        return http.StatusServiceUnavailable
+    }
+    return http.StatusInternalServerError
+}
+
+// GetClassificationStats returns classifier statistics, including cache hit rates
+func (rh *RouterHandler) GetClassificationStats(w http.ResponseWriter, r *http.Request) {
+    stats := rh.classifier.GetStats()
+    
+    w.Header().Set("Content-Type", "application/json")
+    json.NewEncoder(w).Encode(stats)
+}
 
+// PurgeClassificationCache drops every cached classification
+func (rh *RouterHandler) PurgeClassificationCache(w http.ResponseWriter, r *http.Request) {
+    if r.Method != http.MethodPost {
+        http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+        return
+    }
+    
+    cache, ok := rh.classifier.(services.CachePurger)
+    if !ok {
+        errorResponse := map[string]string{
+            "error": "classification cache is disabled",
+        }
+        w.Header().Set("Content-Type", "application/json")
+        w.WriteHeader(http.StatusNotFound)
+        json.NewEncoder(w).Encode(errorResponse)
+        return
+    }
+    
+    response := map[string]interface{}{
+        "purged": cache.PurgeCache(),
+    }
+    
     w.Header().Set("Content-Type", "application/json")
     json.NewEncoder(w).Encode(response)
 }
 
+// RouteMessage classifies a customer message and assigns it to a real, available agent
+func (rh *RouterHandler) RouteMessage(w http.ResponseWriter, r *http.Request) {
+    if r.Method != http.MethodPost {
+        http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+        return
+    }
+
+    var request struct {
+        CustomerMessage string `json:"customer_message"`
+        VIP             bool   `json:"vip"`
+    }
+
+    err := json.NewDecoder(r.Body).Decode(&request)
+    if err != nil {
+        http.Error(w, "Invalid JSON", http.StatusBadRequest)
+        return
+    }
+
+    if request.CustomerMessage == "" {
+        errorResponse := map[string]string{
+            "error": "customer_message field is required",
+        }
+        w.Header().Set("Content-Type", "application/json")
+        w.WriteHeader(http.StatusBadRequest)
+        json.NewEncoder(w).Encode(errorResponse)
+        return
+    }
+
+    decision, err := rh.routingService.RouteMessage(requestContext(r), request.CustomerMessage, request.VIP)
+    if err != nil && (decision == nil || len(decision.SubTickets) == 0) {
+        errorResponse := map[string]interface{}{
+            "error": err.Error(),
+        }
+        status := classificationErrorStatus(err)
+        switch {
+        case errors.Is(err, services.ErrHumanTriage):
+            // The classifier is not sure enough for any agent to take it
+            status = http.StatusUnprocessableEntity
+            errorResponse["status"] = "human_triage"
+            errorResponse["intent"] = decision.Intent
+            errorResponse["original_intent"] = decision.Classification.OriginalIntent
+        case decision != nil:
+            // Classified, but nobody can take it
+            status = http.StatusServiceUnavailable
+            errorResponse["status"] = "no_agent_available"
+            errorResponse["intent"] = decision.Intent
+        }
+        w.Header().Set("Content-Type", "application/json")
+        w.WriteHeader(status)
+        json.NewEncoder(w).Encode(errorResponse)
+        return
+    }
+
+    // A split message lists every sub-ticket, even when none of them could be routed
+    w.Header().Set("Content-Type", "application/json")
+    switch {
+    case err != nil:
+        w.WriteHeader(http.StatusServiceUnavailable)
+    case decision.Status == services.QueueQueued:
+        w.WriteHeader(http.StatusAccepted)
+    }
+    json.NewEncoder(w).Encode(decision)
+}
+
+// RouteQuery routes a query whose intent is already known, see RoutingService.RouteQuery
 func (rh *RouterHandler) RouteQuery(w http.ResponseWriter, r *http.Request) {
     var query models.Query
     err := json.NewDecoder(r.Body).Decode(&query)
@@ -77,8 +235,13 @@ func (rh *RouterHandler) RouteQuery(w http.ResponseWriter, r *http.Request) {
         return
     }
     
-    agent, err := rh.agentService.FindAvailableAgent(query.Intent)
-    if err != nil {
+    if query.Language == "" {
+        query.Language = rh.detectLanguage(query.Content)
+    }
+    
+    response, err := rh.routingService.RouteQuery(query)
+    response.Entities = rh.extractEntities(query.Content)
+    if err != nil && len(response.SubTickets) == 0 {
         errorResponse := map[string]string{
             "error": err.Error(),
             "status": "no_agent_available",
@@ -89,17 +252,34 @@ func (rh *RouterHandler) RouteQuery(w http.ResponseWriter, r *http.Request) {
         return
     }
     
-    rh.agentService.AssignQuery(agent.ID)
-    
-    response := models.RoutingResponse{
-        AgentID: agent.ID,
-        Intent:  query.Intent,
-    }
-    
+    // A split query lists every sub-ticket, even when none of them could be routed
     w.Header().Set("Content-Type", "application/json")
+    switch {
+    case err != nil:
+        w.WriteHeader(http.StatusServiceUnavailable)
+    case response.Status == services.QueueQueued:
+        w.WriteHeader(http.StatusAccepted)
+    }
     json.NewEncoder(w).Encode(response)
 }
 
+// extractEntities finds order IDs, products and contact details in the query content
+func (rh *RouterHandler) extractEntities(content string) []models.Entity {
+    if rh.entityExtractor == nil || content == "" {
+        return nil
+    }
+    return rh.entityExtractor.Extract(content)
+}
+
+// detectLanguage returns the language of the query content, or "" when detection is disabled
+func (rh *RouterHandler) detectLanguage(content string) string {
+    if rh.languageDetector == nil || content == "" {
+        return ""
+    }
+    language, _ := rh.languageDetector.Detect(content)
+    return language
+}
+
 // GetAgents returns all agents and their status
 func (rh *RouterHandler) GetAgents(w http.ResponseWriter, r *http.Request) {
     agents := rh.agentService.GetAllAgents()
@@ -111,6 +291,9 @@ func (rh *RouterHandler) GetAgents(w http.ResponseWriter, r *http.Request) {
 // GetAgentStats returns system statistics
 func (rh *RouterHandler) GetAgentStats(w http.ResponseWriter, r *http.Request) {
     stats := rh.agentService.GetAgentStats()
+    if rh.queue != nil {
+        stats["queues"] = rh.queue.Stats()
+    }
     
     w.Header().Set("Content-Type", "application/json")
     json.NewEncoder(w).Encode(stats)
@@ -141,7 +324,7 @@ func (rh *RouterHandler) TestConversations(w http.ResponseWriter, r *http.Reques
     json.NewEncoder(w).Encode(response)
 }
 
-// TestClassificationOnConversations tests the OpenAI classification on your loaded conversations
+// TestClassificationOnConversations tests the configured classifier on your loaded conversations
 func (rh *RouterHandler) TestClassificationOnConversations(w http.ResponseWriter, r *http.Request) {
     conversations := rh.conversationService.GetConversations()
     if len(conversations) == 0 {
@@ -166,17 +349,19 @@ func (rh *RouterHandler) TestClassificationOnConversations(w http.ResponseWriter
         conv := conversations[i]
         firstMessage := rh.conversationService.GetFirstCustomerMessage(conv)
         
-        intent, agent, err := rh.classificationService.ClassifyQuery(firstMessage)
+        classification, err := rh.classifier.ClassifyQuery(requestContext(r), firstMessage)
         
         result := map[string]interface{}{
             "conversation_id": i + 1,
             "customer_message": firstMessage,
-            "classified_intent": intent,
-            "recommended_agent": agent,
         }
         
         if err != nil {
             result["error"] = err.Error()
+        } else {
+            result["classified_intent"] = classification.Intent
+            result["recommended_agent"] = classification.Agent
+            result["confidence"] = classification.Confidence
         }
         
         results = append(results, result)
@@ -184,6 +369,7 @@ func (rh *RouterHandler) TestClassificationOnConversations(w http.ResponseWriter
 
     response := map[string]interface{}{
         "message": "Classification test on conversations",
+        "backend": rh.classifier.Name(),
         "total_tested": limit,
         "results": results,
     }
@@ -0,0 +1,44 @@
+package handlers
+
+import (
+    "encoding/json"
+    "net/http"
+    "customer-query-router/models"
+    "customer-query-router/services"
+)
+
+type SLAHandler struct {
+    sla   *services.SLAMonitor
+    queue *services.QueueService
+}
+
+func NewSLAHandler(sla *services.SLAMonitor, queue *services.QueueService) *SLAHandler {
+    return &SLAHandler{
+        sla:   sla,
+        queue: queue,
+    }
+}
+
+// GetSLA reports time-to-assign compliance per intent, and the queued tickets that are at
+// risk of breaching their SLA or already have
+func (sh *SLAHandler) GetSLA(w http.ResponseWriter, r *http.Request) {
+    atRisk := []*models.QueuedQuery{}
+    breached := []*models.QueuedQuery{}
+    if sh.queue != nil {
+        for _, queued := range sh.queue.List("") {
+            switch queued.SLAStatus {
+            case services.SLAAtRisk:
+                atRisk = append(atRisk, queued)
+            case services.SLABreached:
+                breached = append(breached, queued)
+            }
+        }
+    }
+
+    response := sh.sla.Stats()
+    response["at_risk"] = atRisk
+    response["breached"] = breached
+
+    w.Header().Set("Content-Type", "application/json")
+    json.NewEncoder(w).Encode(response)
+}
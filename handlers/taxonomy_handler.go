@@ -0,0 +1,49 @@
+package handlers
+
+import (
+    "encoding/json"
+    "errors"
+    "net/http"
+    "customer-query-router/services"
+)
+
+type TaxonomyHandler struct {
+    taxonomy *services.Taxonomy
+}
+
+func NewTaxonomyHandler(taxonomy *services.Taxonomy) *TaxonomyHandler {
+    return &TaxonomyHandler{
+        taxonomy: taxonomy,
+    }
+}
+
+// GetTaxonomy returns every intent, including disabled ones
+func (th *TaxonomyHandler) GetTaxonomy(w http.ResponseWriter, r *http.Request) {
+    response := map[string]interface{}{
+        "version": th.taxonomy.Version(),
+        "intents": th.taxonomy.AllIntents(),
+    }
+    
+    w.Header().Set("Content-Type", "application/json")
+    json.NewEncoder(w).Encode(response)
+}
+
+// ReloadTaxonomy re-reads the taxonomy file without restarting the server
+func (th *TaxonomyHandler) ReloadTaxonomy(w http.ResponseWriter, r *http.Request) {
+    if r.Method != http.MethodPost {
+        http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+        return
+    }
+    
+    if err := th.taxonomy.Reload(); err != nil {
+        // A file that was read but is invalid is the caller's to fix, anything else is ours
+        status := http.StatusInternalServerError
+        if errors.Is(err, services.ErrInvalidTaxonomy) {
+            status = http.StatusUnprocessableEntity
+        }
+        writeJSONError(w, status, "Reload failed: "+err.Error())
+        return
+    }
+    
+    th.GetTaxonomy(w, r)
+}
\ No newline at end of file
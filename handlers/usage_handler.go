@@ -0,0 +1,34 @@
+package handlers
+
+import (
+    "context"
+    "encoding/json"
+    "net/http"
+    "customer-query-router/services"
+)
+
+// ClientHeader identifies the API client a request's token usage is billed to. Callers set it
+// themselves, so per-client budgets only hold behind a trusted proxy that authenticates the
+// caller and overwrites the header.
+const ClientHeader = "X-Client-ID"
+
+// requestContext carries the calling API client into the classifiers
+func requestContext(r *http.Request) context.Context {
+    return services.WithClient(r.Context(), r.Header.Get(ClientHeader))
+}
+
+type UsageHandler struct {
+    tracker *services.UsageTracker
+}
+
+func NewUsageHandler(tracker *services.UsageTracker) *UsageHandler {
+    return &UsageHandler{
+        tracker: tracker,
+    }
+}
+
+// GetUsage returns token usage and estimated cost per day, intent, model and API client
+func (uh *UsageHandler) GetUsage(w http.ResponseWriter, r *http.Request) {
+    w.Header().Set("Content-Type", "application/json")
+    json.NewEncoder(w).Encode(uh.tracker.Report())
+}
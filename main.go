@@ -5,30 +5,222 @@ import (
     "log"
     "net/http"
     "os"
+    "strconv"
+    "strings"
+    "time"
     "customer-query-router/handlers"
     "customer-query-router/services"
 )
 
 func main() {
-    // Get OpenAI API key
-    openaiKey := os.Getenv("OPENAI_API_KEY")
-    if openaiKey == "" {
-        log.Fatal("OPENAI_API_KEY environment variable is required")
+    // Load the intent taxonomy and keep watching it for changes
+    taxonomy, err := services.LoadTaxonomy(getEnv("TAXONOMY_FILE", "data/taxonomy.json"))
+    if err != nil {
+        log.Fatal("Failed to load taxonomy:", err)
+    }
+    go taxonomy.Watch(getEnvDuration("TAXONOMY_RELOAD_INTERVAL", 30*time.Second), nil)
+
+    ensembleBackends, err := services.ParseEnsembleBackends(getEnv("ENSEMBLE_BACKENDS", "openai:2,bayes,rules"))
+    if err != nil {
+        log.Fatal("Invalid ENSEMBLE_BACKENDS:", err)
+    }
+
+    // Token usage and cost of LLM calls, with optional daily budgets in USD
+    usageTracker := services.NewUsageTracker(getEnvFloat("DAILY_BUDGET_USD", 0), getEnvFloat("CLIENT_DAILY_BUDGET_USD", 0))
+
+    // Personal data is redacted before classification unless REDACT_PII=false
+    var redactor *services.Redactor
+    if getEnvBool("REDACT_PII", true) {
+        var customPatterns []services.RedactionPattern
+        if filename := os.Getenv("REDACTION_PATTERNS_FILE"); filename != "" {
+            customPatterns, err = services.LoadRedactionPatterns(filename)
+            if err != nil {
+                log.Fatal("Failed to load redaction patterns:", err)
+            }
+        }
+        redactor, err = services.NewRedactor(customPatterns)
+        if err != nil {
+            log.Fatal("Invalid redaction patterns:", err)
+        }
+    }
+
+    // Entities are extracted from messages with local patterns unless EXTRACT_ENTITIES=false
+    var entityExtractor *services.EntityExtractor
+    if getEnvBool("EXTRACT_ENTITIES", true) {
+        products := services.DefaultProducts()
+        if filename := os.Getenv("PRODUCTS_FILE"); filename != "" {
+            products, err = services.LoadProducts(filename)
+            if err != nil {
+                log.Fatal("Failed to load products:", err)
+            }
+        }
+        entityExtractor, err = services.NewEntityExtractor(products)
+        if err != nil {
+            log.Fatal("Invalid entity patterns:", err)
+        }
+    }
+
+    // Sentiment and urgency drive the priority of routed queries unless SCORE_PRIORITY=false
+    var sentimentAnalyzer *services.SentimentAnalyzer
+    if getEnvBool("SCORE_PRIORITY", true) {
+        sentimentAnalyzer = services.NewSentimentAnalyzer()
+    }
+
+    // The language of each message is detected unless DETECT_LANGUAGE=false
+    var languageDetector *services.LanguageDetector
+    if getEnvBool("DETECT_LANGUAGE", true) {
+        languageDetector = services.NewLanguageDetector()
+    }
+
+    // Select the classification backend (defaults to OpenAI)
+    classifierConfig := services.ClassifierConfig{
+        Taxonomy:        taxonomy,
+        Backend:         os.Getenv("CLASSIFIER_BACKEND"),
+        StaticIntent:    os.Getenv("STATIC_INTENT"),
+        RulesFile:       os.Getenv("RULES_FILE"),
+
+        OpenAI: services.OpenAIConfig{
+            APIKey:       os.Getenv("OPENAI_API_KEY"),
+            BaseURL:      os.Getenv("OPENAI_BASE_URL"),
+            Organization: os.Getenv("OPENAI_ORGANIZATION"),
+            Model:        getEnv("OPENAI_MODEL", services.DefaultOpenAIModel),
+            MaxTokens:    getEnvInt("OPENAI_MAX_TOKENS", services.DefaultOpenAIMaxTokens),
+            Temperature:  float32(getEnvFloat("OPENAI_TEMPERATURE", services.DefaultOpenAITemperature)),
+
+            PromptPrice:     getEnvFloat("OPENAI_PROMPT_PRICE", 0),
+            CompletionPrice: getEnvFloat("OPENAI_COMPLETION_PRICE", 0),
+        },
+        StructuredOutput: getEnvBool("OPENAI_STRUCTURED_OUTPUT", true),
+        RetryPolicy: services.RetryPolicy{
+            Timeout:    getEnvDuration("OPENAI_TIMEOUT", services.DefaultCallTimeout),
+            MaxRetries: getEnvInt("OPENAI_MAX_RETRIES", services.DefaultMaxRetries),
+            BaseDelay:  getEnvDuration("OPENAI_RETRY_BASE_DELAY", services.DefaultRetryBaseDelay),
+            MaxDelay:   5 * time.Second,
+        },
+
+        Usage: usageTracker,
+
+        BreakerThreshold: getEnvInt("BREAKER_THRESHOLD", services.DefaultBreakerThreshold),
+        BreakerCooldown:  getEnvDuration("BREAKER_COOLDOWN", services.DefaultBreakerCooldown),
+
+        FallbackChain:         getEnvList("FALLBACK_CHAIN", []string{"rules"}),
+        FallbackMinConfidence: getEnvFloat("FALLBACK_MIN_CONFIDENCE", 0),
+
+        PromptTemplateFile:  os.Getenv("PROMPT_TEMPLATE_FILE"),
+        FewShotExamplesFile: getEnv("FEW_SHOT_EXAMPLES_FILE", "data/labeled_conversations.tsv"),
+        MaxFewShotExamples:  getEnvInt("FEW_SHOT_EXAMPLES", services.DefaultMaxFewShotExamples),
+        PromptTokenBudget:   getEnvInt("PROMPT_TOKEN_BUDGET", services.DefaultPromptTokenBudget),
+
+        BayesModelFile:  getEnv("BAYES_MODEL_FILE", "data/bayes_model.json"),
+        BayesLabelsFile: getEnv("BAYES_LABELS_FILE", "data/labeled_conversations.tsv"),
+
+        ConfidenceThreshold: getEnvFloat("CONFIDENCE_THRESHOLD", 0),
+        LowConfidenceRoute:  getEnv("LOW_CONFIDENCE_ROUTE", "general"),
+        MaxAlternatives:     getEnvInt("MAX_ALTERNATIVES", services.DefaultMaxAlternatives),
+        MultiLabel:          getEnvBool("MULTI_LABEL", false),
+        MultiLabelThreshold: getEnvFloat("MULTI_LABEL_THRESHOLD", services.DefaultMultiLabelThreshold),
+
+        EnsembleBackends:      ensembleBackends,
+        EnsembleStrategy:      getEnv("ENSEMBLE_STRATEGY", services.EnsembleVote),
+        EnsembleStrictIntents: getEnvList("ENSEMBLE_STRICT_INTENTS", services.DefaultStrictIntents),
+
+        Redactor:  redactor,
+        Language:  languageDetector,
+        Entities:  entityExtractor,
+        Sentiment: sentimentAnalyzer,
+
+        CacheTTL:     getEnvDuration("CACHE_TTL", services.DefaultCacheTTL),
+        CacheMaxSize: getEnvInt("CACHE_MAX_SIZE", services.DefaultCacheMaxSize),
+    }
+
+    // Non-English messages are translated with the OpenAI model for classification when TRANSLATE_MESSAGES=true
+    if getEnvBool("TRANSLATE_MESSAGES", false) {
+        if languageDetector == nil {
+            log.Fatal("TRANSLATE_MESSAGES requires DETECT_LANGUAGE")
+        }
+        if classifierConfig.OpenAI.APIKey == "" && classifierConfig.OpenAI.BaseURL == "" {
+            log.Fatal("TRANSLATE_MESSAGES requires OPENAI_API_KEY or OPENAI_BASE_URL")
+        }
+        classifierConfig.Translator = services.NewOpenAITranslator(classifierConfig.OpenAI, classifierConfig.RetryPolicy)
     }
 
     // Initialize services
     agentService := services.NewAgentService()
+    if err := agentService.SetLanguageRouting(getEnv("LANGUAGE_ROUTING", services.LanguageRoutingSoft)); err != nil {
+        log.Fatal("Invalid LANGUAGE_ROUTING:", err)
+    }
+
+    // ROUTING_STRATEGY picks among the agents who can take a ticket, ROUTING_STRATEGY_OVERRIDES
+    // changes it per intent, e.g. "billing_discrepancies:skill_match". ROUTING_SEED makes
+    // weighted_random repeatable.
+    routingSeed := int64(getEnvInt("ROUTING_SEED", 0))
+    strategy, err := services.NewRoutingStrategy(getEnv("ROUTING_STRATEGY", services.StrategyLeastUtilization), routingSeed)
+    if err != nil {
+        log.Fatal("Invalid ROUTING_STRATEGY:", err)
+    }
+    agentService.SetRoutingStrategy(strategy)
+    overrides, err := services.ParseStrategyOverrides(getEnv("ROUTING_STRATEGY_OVERRIDES", ""))
+    if err != nil {
+        log.Fatal("Invalid ROUTING_STRATEGY_OVERRIDES:", err)
+    }
+    if err := services.ValidateStrategyOverrides(overrides, taxonomy); err != nil {
+        log.Fatal("Invalid ROUTING_STRATEGY_OVERRIDES:", err)
+    }
+    for intent, name := range overrides {
+        strategy, err := services.NewRoutingStrategy(name, routingSeed)
+        if err != nil {
+            log.Fatalf("Invalid ROUTING_STRATEGY_OVERRIDES for %s: %v", intent, err)
+        }
+        agentService.SetIntentStrategy(intent, strategy)
+    }
+    defaultStrategy, intentStrategies := agentService.RoutingStrategies()
+    log.Printf("[ROUTING] Agents picked by %s, overrides: %v", defaultStrategy, intentStrategies)
+
+    // Pending assignments not accepted within ASSIGNMENT_TIMEOUT expire and free their agent
+    assignmentTimeout := getEnvDuration("ASSIGNMENT_TIMEOUT", services.DefaultAssignmentTimeout)
+    assignmentService := services.NewAssignmentService(agentService, assignmentTimeout)
+    go assignmentService.Watch(assignmentCheckInterval(assignmentTimeout), nil)
+
+    // Time to assign is measured against each intent's "sla" in the taxonomy, or SLA_TARGET.
+    // Waiting tickets are at risk after SLA_AT_RISK of their target.
+    slaAtRisk := getEnvFloat("SLA_AT_RISK", services.DefaultSLAAtRisk)
+    if slaAtRisk <= 0 || slaAtRisk > 1 {
+        log.Fatal("SLA_AT_RISK must be between 0 and 1")
+    }
+    slaMonitor := services.NewSLAMonitor(taxonomy, getEnvDuration("SLA_TARGET", services.DefaultSLATarget), slaAtRisk)
+
+    // Queries no agent can take wait in per-intent queues of up to QUEUE_MAX_SIZE (0 rejects them),
+    // by priority, gaining a level every QUEUE_AGING they wait
+    var queueService *services.QueueService
+    if queueMaxSize := getEnvInt("QUEUE_MAX_SIZE", services.DefaultQueueMaxSize); queueMaxSize > 0 {
+        queueService = services.NewQueueService(agentService, assignmentService, slaMonitor, queueMaxSize,
+            getEnvDuration("QUEUE_AGING", services.DefaultQueueAging))
+        go queueService.Run(5*time.Second, nil)
+    }
     conversationService := services.NewConversationService()
-    classificationService := services.NewClassificationService(openaiKey)
+    classifier, err := services.NewClassifier(classifierConfig)
+    if err != nil {
+        log.Fatal("Failed to initialize classifier:", err)
+    }
     
     // Load conversations
-    err := conversationService.LoadConversations("data/conversations.txt")
+    err = conversationService.LoadConversations("data/conversations.txt")
     if err != nil {
         log.Fatal("Failed to load conversations:", err)
     }
     
     // Initialize handlers
-    routerHandler := handlers.NewRouterHandler(agentService, conversationService, classificationService)
+    routingService := services.NewRoutingService(classifier, agentService, assignmentService, queueService, slaMonitor)
+    routingService.SetAnyAgentFallback(getEnvBool("ROUTE_TO_ANY_AGENT", false))
+    routingService.SetSentimentAnalyzer(sentimentAnalyzer)
+    routerHandler := handlers.NewRouterHandler(agentService, queueService, conversationService, classifier, routingService, entityExtractor, languageDetector)
+    conversationClassifier := services.NewConversationClassifier(classifier, getEnvFloat("SHIFT_CONFIDENCE", services.DefaultShiftConfidence))
+    conversationHandler := handlers.NewConversationHandler(conversationService, conversationClassifier, routingService)
+    taxonomyHandler := handlers.NewTaxonomyHandler(taxonomy)
+    usageHandler := handlers.NewUsageHandler(usageTracker)
+    assignmentHandler := handlers.NewAssignmentHandler(assignmentService)
+    queueHandler := handlers.NewQueueHandler(queueService)
+    slaHandler := handlers.NewSLAHandler(slaMonitor, queueService)
     uiHandler := handlers.NewUIHandler()
     
     // Set up UI routes
@@ -37,21 +229,138 @@ func main() {
     
     // Set up API routes with CORS
     http.HandleFunc("/api/route", handlers.EnableCORS(routerHandler.RouteQuery))
+    http.HandleFunc("/api/route-message", handlers.EnableCORS(routerHandler.RouteMessage))
     http.HandleFunc("/api/agents", handlers.EnableCORS(routerHandler.GetAgents))
     http.HandleFunc("/api/agents/stats", handlers.EnableCORS(routerHandler.GetAgentStats))
+    http.HandleFunc("/api/assignments", handlers.EnableCORS(assignmentHandler.GetAssignments))
+    http.HandleFunc("/api/assignments/accept", handlers.EnableCORS(assignmentHandler.AcceptAssignment))
+    http.HandleFunc("/api/assignments/complete", handlers.EnableCORS(assignmentHandler.CompleteAssignment))
+    http.HandleFunc("/api/assignments/abandon", handlers.EnableCORS(assignmentHandler.AbandonAssignment))
+    http.HandleFunc("/api/assignments/transfer", handlers.EnableCORS(assignmentHandler.TransferAssignment))
+    if queueService != nil {
+        http.HandleFunc("/api/queue", handlers.EnableCORS(queueHandler.GetQueue))
+    }
+    http.HandleFunc("/api/sla", handlers.EnableCORS(slaHandler.GetSLA))
     http.HandleFunc("/api/test-conversations", handlers.EnableCORS(routerHandler.TestConversations))
     http.HandleFunc("/api/classify", handlers.EnableCORS(routerHandler.ClassifyQuery))
+    http.HandleFunc("/api/classify/stats", handlers.EnableCORS(routerHandler.GetClassificationStats))
+    http.HandleFunc("/api/classify/cache/purge", handlers.EnableCORS(routerHandler.PurgeClassificationCache))
+    http.HandleFunc("/api/classify/conversation", handlers.EnableCORS(conversationHandler.ClassifyConversation))
+    http.HandleFunc("/api/route-conversation", handlers.EnableCORS(conversationHandler.RouteConversation))
     http.HandleFunc("/api/test-classification", handlers.EnableCORS(routerHandler.TestClassificationOnConversations))
+    http.HandleFunc("/api/taxonomy", handlers.EnableCORS(taxonomyHandler.GetTaxonomy))
+    http.HandleFunc("/api/taxonomy/reload", handlers.EnableCORS(taxonomyHandler.ReloadTaxonomy))
+    http.HandleFunc("/api/usage", handlers.EnableCORS(usageHandler.GetUsage))
     
     fmt.Println("Server starting on :8080")
     fmt.Println("🌐 Web UI: http://localhost:8080")
     fmt.Println("\nAPI Endpoints:")
-    fmt.Println("POST /api/classify - Classify customer queries with OpenAI")
+    fmt.Printf("POST /api/classify - Classify customer queries (%s backend)\n", classifier.Name())
+    fmt.Println("GET  /api/classify/stats - Get classification statistics")
+    fmt.Println("POST /api/classify/cache/purge - Purge the classification cache")
+    fmt.Println("POST /api/classify/conversation - Classify a conversation and its intent shifts")
     fmt.Println("POST /api/route - Route customer queries")  
+    fmt.Println("POST /api/route-message - Classify a message and assign it to an available agent")
+    fmt.Println("POST /api/route-conversation - Re-route a conversation after its intent shifted")
     fmt.Println("GET  /api/agents - Get all agents")
     fmt.Println("GET  /api/agents/stats - Get agent statistics")
+    fmt.Println("GET  /api/assignments - List assignments (?status=, ?agent_id=)")
+    fmt.Println("POST /api/assignments/{accept,complete,abandon,transfer} - Move an assignment through its lifecycle")
+    if queueService != nil {
+        fmt.Println("GET  /api/queue - List queries waiting for an agent (?intent=, ?ticket_id=)")
+    }
+    fmt.Println("GET  /api/sla - Get time-to-assign SLA compliance, at-risk and breached tickets")
     fmt.Println("POST /api/test-conversations - Test conversations")
-    fmt.Println("POST /api/test-classification - Test OpenAI classification on loaded conversations")
+    fmt.Println("POST /api/test-classification - Test classification on loaded conversations")
+    fmt.Println("GET  /api/taxonomy - Get the intent taxonomy")
+    fmt.Println("POST /api/taxonomy/reload - Reload the intent taxonomy from disk")
+    fmt.Println("GET  /api/usage - Get token usage and cost by day, intent, model and client")
     
     log.Fatal(http.ListenAndServe(":8080", nil))
-}
\ No newline at end of file
+}
+
+// getEnv returns the environment variable or the fallback when it is unset
+func getEnv(key, fallback string) string {
+    if value := os.Getenv(key); value != "" {
+        return value
+    }
+    return fallback
+}
+
+// getEnvFloat parses a float environment variable, exiting on malformed values
+func getEnvFloat(key string, fallback float64) float64 {
+    value := os.Getenv(key)
+    if value == "" {
+        return fallback
+    }
+    parsed, err := strconv.ParseFloat(value, 64)
+    if err != nil {
+        log.Fatalf("Invalid %s: %v", key, err)
+    }
+    return parsed
+}
+
+// getEnvInt parses an integer environment variable, exiting on malformed values
+func getEnvInt(key string, fallback int) int {
+    value := os.Getenv(key)
+    if value == "" {
+        return fallback
+    }
+    parsed, err := strconv.Atoi(value)
+    if err != nil {
+        log.Fatalf("Invalid %s: %v", key, err)
+    }
+    return parsed
+}
+
+// getEnvBool parses a boolean environment variable, exiting on malformed values
+func getEnvBool(key string, fallback bool) bool {
+    value := os.Getenv(key)
+    if value == "" {
+        return fallback
+    }
+    parsed, err := strconv.ParseBool(value)
+    if err != nil {
+        log.Fatalf("Invalid %s: %v", key, err)
+    }
+    return parsed
+}
+
+// getEnvDuration parses a duration environment variable ("30s", "5m"), exiting on malformed values
+func getEnvDuration(key string, fallback time.Duration) time.Duration {
+    value := os.Getenv(key)
+    if value == "" {
+        return fallback
+    }
+    parsed, err := time.ParseDuration(value)
+    if err != nil {
+        log.Fatalf("Invalid %s: %v", key, err)
+    }
+    return parsed
+}
+// getEnvList splits a comma-separated environment variable, dropping empty items
+func getEnvList(key string, fallback []string) []string {
+    value := os.Getenv(key)
+    if value == "" {
+        return fallback
+    }
+    items := []string{}
+    for _, item := range strings.Split(value, ",") {
+        if item = strings.TrimSpace(item); item != "" {
+            items = append(items, item)
+        }
+    }
+    return items
+}
+
+// assignmentCheckInterval checks for stale assignments a few times per timeout, at least every minute
+func assignmentCheckInterval(timeout time.Duration) time.Duration {
+    interval := timeout / 4
+    if interval > time.Minute {
+        interval = time.Minute
+    }
+    if interval < time.Second {
+        interval = time.Second
+    }
+    return interval
+}
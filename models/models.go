@@ -1,8 +1,15 @@
 package models
 
+import "time"
+
 type Query struct {
-    Content string `json:"content"`
-    Intent  string `json:"intent"`
+    Content  string   `json:"content"`
+    Intent   string   `json:"intent"`
+    Intents  []string `json:"intents,omitempty"`  // Additional intents of a multi-issue message
+    Split    bool     `json:"split,omitempty"`    // Route every intent as its own linked sub-ticket
+    Priority int      `json:"priority,omitempty"` // From 1 (low) to 5 (urgent), senior agents take 4 and 5 first
+    Language string   `json:"language,omitempty"` // ISO 639-1 code, detected from Content when empty
+    VIP      bool     `json:"vip,omitempty"`      // VIP customers get one priority level more
 }
 
 type Agent struct {
@@ -12,9 +19,204 @@ type Agent struct {
     MaxCapacity  int      `json:"max_capacity"`
     CurrentLoad  int      `json:"current_load"`
     IsOnline     bool     `json:"is_online"`
+    Senior       bool     `json:"senior"`
+    Languages    []string `json:"languages,omitempty"` // ISO 639-1 codes, English when empty
+    // Proficiency per intent from 1 to 5, used by skill_match routing. May include intents
+    // outside Specialties, which the agent only gets when no specialist is free.
+    SkillLevels  map[string]int `json:"skill_levels,omitempty"`
 }
 
 type RoutingResponse struct {
-    AgentID string `json:"agent_id"`
-    Intent  string `json:"intent"`
-}
\ No newline at end of file
+    TicketID       string            `json:"ticket_id,omitempty"`
+    ParentTicketID string            `json:"parent_ticket_id,omitempty"`
+    AssignmentID   string            `json:"assignment_id,omitempty"`
+    AgentID        string            `json:"agent_id"`
+    Intent         string            `json:"intent"`
+    Language       string            `json:"language,omitempty"`
+    Priority       int               `json:"priority,omitempty"`
+    Status         string            `json:"status,omitempty"`         // "queued" while waiting for an agent
+    QueuePosition  int               `json:"queue_position,omitempty"` // Place in the intent's queue, from 1
+    EstimatedWait  int               `json:"estimated_wait_seconds,omitempty"`
+    Error          string            `json:"error,omitempty"`
+    SubTickets     []RoutingResponse `json:"sub_tickets,omitempty"`
+    Entities       []Entity          `json:"entities,omitempty"`
+}
+
+type IntentScore struct {
+    Intent string  `json:"intent"`
+    Score  float64 `json:"score"`
+}
+
+type ClassificationResult struct {
+    Intent         string           `json:"intent"`
+    Agent          string           `json:"recommended_agent"`
+    Confidence     float64          `json:"confidence"`
+    Backend        string           `json:"backend"`
+    Alternatives   []IntentScore    `json:"alternatives,omitempty"`
+    LowConfidence  bool             `json:"low_confidence,omitempty"`
+    OriginalIntent string           `json:"original_intent,omitempty"` // Set when LowConfidence overrode the intent
+    Intents        []IntentScore    `json:"intents,omitempty"`         // Multi-label mode: every applicable intent, primary first
+    Rationale      string           `json:"rationale,omitempty"`
+    Votes          []BackendVote    `json:"votes,omitempty"`           // Ensemble mode: what every member answered
+    NeedsReview    bool             `json:"needs_review,omitempty"`    // Ensemble members disagreed
+    Attempts       []BackendAttempt `json:"attempts,omitempty"`        // Fallback chain: every backend tried, in order
+    Usage          *TokenUsage      `json:"usage,omitempty"`           // Tokens and estimated cost of an LLM call
+    Redactions     []RedactedEntity `json:"redactions,omitempty"`      // Personal data removed before classification
+    Entities       []Entity         `json:"entities,omitempty"`        // Order IDs, products, contact details, amounts and dates
+    Sentiment      string           `json:"sentiment,omitempty"`       // negative, neutral or positive
+    SentimentScore float64          `json:"sentiment_score"`           // From -1 (furious) to 1 (delighted)
+    Urgency        float64          `json:"urgency"`                   // From 0 to 1
+    Priority       int              `json:"priority,omitempty"`        // From 1 (low) to 5 (urgent)
+    Language       string           `json:"language,omitempty"`        // ISO 639-1 code of the message
+    LanguageConfidence float64      `json:"language_confidence,omitempty"`
+    Translation    string           `json:"translation,omitempty"`     // English text that was classified instead of the original
+}
+
+// Entity is a piece of information extracted from a customer message
+type Entity struct {
+    Type  string `json:"type"`
+    Value string `json:"value"`
+}
+
+// RedactedEntity maps a redaction token back to the personal data it replaced
+type RedactedEntity struct {
+    Type  string `json:"type"`
+    Token string `json:"token"`
+    Value string `json:"value"`
+}
+
+// TokenUsage is what one LLM call consumed
+type TokenUsage struct {
+    Model            string  `json:"model"`
+    PromptTokens     int     `json:"prompt_tokens"`
+    CompletionTokens int     `json:"completion_tokens"`
+    TotalTokens      int     `json:"total_tokens"`
+    CostUSD          float64 `json:"cost_usd"`
+}
+
+// BackendAttempt is one step of a fallback chain. Error is set when the backend
+// failed, Skipped when its answer was below the chain's minimum confidence.
+type BackendAttempt struct {
+    Backend    string  `json:"backend"`
+    Intent     string  `json:"intent,omitempty"`
+    Confidence float64 `json:"confidence"`
+    Error      string  `json:"error,omitempty"`
+    Skipped    bool    `json:"skipped,omitempty"`
+}
+
+// BackendVote is one ensemble member's answer
+type BackendVote struct {
+    Backend    string  `json:"backend"`
+    Intent     string  `json:"intent,omitempty"`
+    Confidence float64 `json:"confidence"`
+    Weight     float64 `json:"weight"`
+    Error      string  `json:"error,omitempty"`
+}
+
+// RoutingDecision is the outcome of classifying a message and assigning it to an agent
+type RoutingDecision struct {
+    TicketID       string                `json:"ticket_id"`
+    ParentTicketID string                `json:"parent_ticket_id,omitempty"` // Set for intents raised later in a conversation
+    AssignmentID   string                `json:"assignment_id,omitempty"`    // Accept, complete, abandon or transfer it under /api/assignments
+    Intent         string                `json:"intent"`
+    Confidence     float64               `json:"confidence"`
+    AgentID        string                `json:"agent_id"`
+    AgentName      string                `json:"agent_name"`
+    Priority       int                   `json:"priority,omitempty"`
+    Sentiment      string                `json:"sentiment,omitempty"`
+    Language       string                `json:"language,omitempty"`
+    Reason         string                `json:"reason"`
+    Status         string                `json:"status,omitempty"`         // "queued" while waiting for an agent
+    QueuePosition  int                   `json:"queue_position,omitempty"` // Place in the intent's queue, from 1
+    EstimatedWait  int                   `json:"estimated_wait_seconds,omitempty"`
+    Entities       []Entity              `json:"entities,omitempty"` // For the agent, also in Classification
+    Classification *ClassificationResult `json:"classification,omitempty"`
+    Error          string                `json:"error,omitempty"`       // Why a sub-ticket was neither assigned nor queued
+    SubTickets     []RoutingDecision     `json:"sub_tickets,omitempty"` // One per intent of a split ticket
+}
+
+// ConversationTurn is one message of a conversation. Speaker is "customer" or "agent".
+type ConversationTurn struct {
+    Speaker string `json:"speaker"`
+    Text    string `json:"text"`
+}
+
+// TurnIntent is the classification of one customer turn
+type TurnIntent struct {
+    Turn       int     `json:"turn"` // Index into the conversation's turns
+    Intent     string  `json:"intent"`
+    Confidence float64 `json:"confidence"`
+    Skipped    bool    `json:"skipped,omitempty"` // Too short to classify, e.g. "Okay, thanks"
+}
+
+// IntentShift is a point where the customer raised a new intent. Kind is "additional"
+// when the earlier intent still stands ("I also want to cancel") and "switch" when it
+// was replaced ("actually, forget the refund").
+type IntentShift struct {
+    Turn       int     `json:"turn"`
+    From       string  `json:"from"`
+    To         string  `json:"to"`
+    Kind       string  `json:"kind"`
+    Confidence float64 `json:"confidence"`
+    Cue        string  `json:"cue,omitempty"` // The phrase that announced the shift
+}
+
+// ConversationClassification is the overall intent of a conversation and how it moved between turns
+type ConversationClassification struct {
+    Intent        string        `json:"intent"` // Best supported across the customer's turns
+    Confidence    float64       `json:"confidence"`
+    CurrentIntent string        `json:"current_intent"`  // What the customer is talking about now
+    OpenIntents   []string      `json:"open_intents"`    // Intents still to handle, in the order raised
+    Shifts        []IntentShift `json:"shifts,omitempty"`
+    Turns         []TurnIntent  `json:"turns"`
+    Priority      int           `json:"priority,omitempty"` // Highest priority of any turn
+    Language      string        `json:"language,omitempty"` // Language of the latest classified turn
+}
+
+// ConversationRouting is the outcome of re-checking a conversation's assignment after it moved on
+type ConversationRouting struct {
+    Classification *ConversationClassification `json:"classification"`
+    Reroute        bool                        `json:"reroute"`                   // Someone other than the current agent is needed
+    TransferredFrom string                     `json:"transferred_from,omitempty"` // Agent whose assignment was transferred, they cover no open intent
+    Assignments    []RoutingDecision           `json:"assignments,omitempty"`
+    Queued         []RoutingDecision           `json:"queued,omitempty"`     // Open intents waiting for an agent, each under its own ticket
+    Unassigned     []string                    `json:"unassigned,omitempty"` // Open intents no agent was free for and the queue refused
+}
+
+// Assignment is one agent's responsibility for a ticket, from routing until it is closed.
+// Open assignments are pending or accepted and count towards the agent's load.
+type Assignment struct {
+    ID              string     `json:"assignment_id"`
+    TicketID        string     `json:"ticket_id"`
+    ParentTicketID  string     `json:"parent_ticket_id,omitempty"` // Set for sub-tickets of a split query or conversation
+    AgentID         string     `json:"agent_id"`
+    Intent          string     `json:"intent"`
+    Priority        int        `json:"priority,omitempty"`
+    Language        string     `json:"language,omitempty"`
+    Status          string     `json:"status"` // pending, accepted, completed, abandoned, transferred or expired
+    CreatedAt       time.Time  `json:"created_at"`
+    UpdatedAt       time.Time  `json:"updated_at"`
+    ExpiresAt       *time.Time `json:"expires_at,omitempty"`       // Pending assignments not accepted in time expire
+    TransferredFrom string     `json:"transferred_from,omitempty"` // Assignment this one took over
+    TransferredTo   string     `json:"transferred_to,omitempty"`   // Assignment that took this one over
+}
+
+// QueuedQuery is a ticket waiting for an agent with free capacity. Once dispatched, it
+// names the assignment it got.
+type QueuedQuery struct {
+    TicketID          string     `json:"ticket_id"`
+    ParentTicketID    string     `json:"parent_ticket_id,omitempty"`
+    Intent            string     `json:"intent"`
+    Priority          int        `json:"priority,omitempty"`
+    EffectivePriority int        `json:"effective_priority,omitempty"` // Priority plus a level per aging interval waited
+    Language          string     `json:"language,omitempty"`
+    Status            string     `json:"status"`                   // queued or dispatched
+    Position          int        `json:"queue_position,omitempty"` // Place in the intent's queue, from 1
+    EstimatedWait     int        `json:"estimated_wait_seconds,omitempty"`
+    SLADeadline       time.Time  `json:"sla_deadline"`
+    SLAStatus         string     `json:"sla_status"` // on_track, at_risk or breached while queued, then met or breached
+    EnqueuedAt        time.Time  `json:"enqueued_at"`
+    DispatchedAt      *time.Time `json:"dispatched_at,omitempty"`
+    AssignmentID      string     `json:"assignment_id,omitempty"`
+    AgentID           string     `json:"agent_id,omitempty"`
+}
@@ -2,43 +2,355 @@ package services
 
 import (
     "fmt"
+    "sort"
+    "sync"
     "customer-query-router/models"
 )
 
+// AgentService is the agent store. It is safe for concurrent use: agents are only
+// read or changed under mu, and callers get copies, never the stored agents.
 type AgentService struct {
-    agents map[string]*models.Agent
+    mu              sync.RWMutex
+    agents          map[string]*models.Agent
+    languageRouting string
+
+    strategy         RoutingStrategy            // Picks agents for intents without an override
+    intentStrategies map[string]RoutingStrategy // Per-intent overrides
+
+    capacityFreed []func() // Called whenever an agent gets capacity back
 }
 
 func NewAgentService() *AgentService {
     return &AgentService{
-        agents: initializeAgents(),
+        agents:           initializeAgents(),
+        languageRouting:  LanguageRoutingSoft,
+        strategy:         LeastUtilizationStrategy{},
+        intentStrategies: make(map[string]RoutingStrategy),
+    }
+}
+
+// AgentCriteria describes the work an agent is picked for
+type AgentCriteria struct {
+    Intent   string
+    Priority int    // From 1 (low) to 5 (urgent), 0 when unknown
+    Language string // ISO 639-1 code of the customer, empty when unknown
+
+    ExcludeAgentID string // Never pick this agent, e.g. the one a ticket is transferred away from
+}
+
+// SetLanguageRouting makes the customer's language a hard constraint, a soft preference or ignored
+func (as *AgentService) SetLanguageRouting(mode string) error {
+    switch mode {
+    case LanguageRoutingOff, LanguageRoutingSoft, LanguageRoutingHard:
+        as.mu.Lock()
+        as.languageRouting = mode
+        as.mu.Unlock()
+        return nil
+    default:
+        return fmt.Errorf("unknown language routing mode: %s", mode)
+    }
+}
+
+// LanguageRouting returns the configured language routing mode
+func (as *AgentService) LanguageRouting() string {
+    as.mu.RLock()
+    defer as.mu.RUnlock()
+    return as.languageRouting
+}
+
+// SetRoutingStrategy sets how agents are picked for intents without an override
+func (as *AgentService) SetRoutingStrategy(strategy RoutingStrategy) {
+    as.mu.Lock()
+    defer as.mu.Unlock()
+    as.strategy = strategy
+}
+
+// SetIntentStrategy overrides the routing strategy for one intent, nil to remove the override
+func (as *AgentService) SetIntentStrategy(intent string, strategy RoutingStrategy) {
+    as.mu.Lock()
+    defer as.mu.Unlock()
+
+    if strategy == nil {
+        delete(as.intentStrategies, intent)
+        return
+    }
+    as.intentStrategies[intent] = strategy
+}
+
+// RoutingStrategies returns the default strategy name and the per-intent overrides
+func (as *AgentService) RoutingStrategies() (string, map[string]string) {
+    as.mu.RLock()
+    defer as.mu.RUnlock()
+
+    return as.strategy.Name(), as.intentStrategyNames()
+}
+
+// intentStrategyNames maps intents with an override to its strategy name. Callers hold mu.
+func (as *AgentService) intentStrategyNames() map[string]string {
+    names := make(map[string]string, len(as.intentStrategies))
+    for intent, strategy := range as.intentStrategies {
+        names[intent] = strategy.Name()
+    }
+    return names
+}
+
+// RoutingStrategyFor returns the name of the strategy picking agents for intent
+func (as *AgentService) RoutingStrategyFor(intent string) string {
+    as.mu.RLock()
+    defer as.mu.RUnlock()
+    return as.strategyFor(intent).Name()
+}
+
+// strategyFor returns the strategy picking agents for intent. Callers hold mu.
+func (as *AgentService) strategyFor(intent string) RoutingStrategy {
+    if strategy, ok := as.intentStrategies[intent]; ok {
+        return strategy
     }
+    return as.strategy
 }
 
-func (as *AgentService) FindAvailableAgent(intent string) (*models.Agent, error) {
+// FindAvailableAgent returns the online specialist for the intent with spare capacity that the
+// routing strategy picks. Agents who speak the customer's language come first (or exclusively,
+// with hard language routing), and at HighPriority and above senior specialists are preferred, so angry or urgent customers
+// reach the most experienced agent that is free. Nothing is reserved and the strategy only
+// peeks, so lookups never advance a rotation; use ReserveAgent to assign.
+func (as *AgentService) FindAvailableAgent(criteria AgentCriteria) (*models.Agent, error) {
+    as.mu.RLock()
+    defer as.mu.RUnlock()
+
+    best := as.bestAgent(criteria, true, false)
+    if best == nil {
+        return nil, as.noAgentError(criteria, true)
+    }
+    return snapshot(best), nil
+}
+
+// FindAnyAvailableAgent returns the online agent with spare capacity that the routing
+// strategy picks, regardless of specialty. Used when no specialist is free.
+func (as *AgentService) FindAnyAvailableAgent(criteria AgentCriteria) (*models.Agent, error) {
+    as.mu.RLock()
+    defer as.mu.RUnlock()
+
+    best := as.bestAgent(criteria, false, false)
+    if best == nil {
+        return nil, as.noAgentError(criteria, false)
+    }
+    return snapshot(best), nil
+}
+
+// ReserveAgent picks a specialist like FindAvailableAgent and takes one unit of their
+// capacity in the same step, so concurrent requests can never push an agent past
+// MaxCapacity. The returned copy includes the reservation.
+func (as *AgentService) ReserveAgent(criteria AgentCriteria) (*models.Agent, error) {
+    return as.reserve(criteria, true)
+}
+
+// ReserveAnyAgent picks any agent like FindAnyAvailableAgent and reserves their capacity
+func (as *AgentService) ReserveAnyAgent(criteria AgentCriteria) (*models.Agent, error) {
+    return as.reserve(criteria, false)
+}
+
+func (as *AgentService) reserve(criteria AgentCriteria, specialistsOnly bool) (*models.Agent, error) {
+    as.mu.Lock()
+    defer as.mu.Unlock()
+
+    best := as.bestAgent(criteria, specialistsOnly, true)
+    if best == nil {
+        return nil, as.noAgentError(criteria, specialistsOnly)
+    }
+    best.CurrentLoad++
+    return snapshot(best), nil
+}
+
+// ReserveAgentByID takes one unit of a specific agent's capacity if they are online and not full
+func (as *AgentService) ReserveAgentByID(agentID string) (*models.Agent, error) {
+    as.mu.Lock()
+    defer as.mu.Unlock()
+
+    agent, exists := as.agents[agentID]
+    switch {
+    case !exists:
+        return nil, fmt.Errorf("unknown agent: %s", agentID)
+    case !agent.IsOnline:
+        return nil, fmt.Errorf("agent %s is offline", agentID)
+    case agent.CurrentLoad >= agent.MaxCapacity:
+        return nil, fmt.Errorf("agent %s is at capacity (%d/%d)", agentID, agent.CurrentLoad, agent.MaxCapacity)
+    }
+    agent.CurrentLoad++
+    return snapshot(agent), nil
+}
+
+// bestAgent returns the stored agent that fits the criteria best, or nil: among the eligible
+// agents, speakers of the customer's language first, then seniors for high priority work,
+// and the routing strategy for the intent picks from those. The strategy only runs when there
+// are candidates, and only picks (advancing its state) when the agent will be reserved.
+// Callers hold mu.
+func (as *AgentService) bestAgent(criteria AgentCriteria, specialistsOnly, reserving bool) *models.Agent {
+    candidates := []*models.Agent{}
     for _, agent := range as.agents {
-        canHandle := false
-        for _, specialty := range agent.Specialties {
-            if specialty == intent {
-                canHandle = true
-                break
+        if agent.ID == criteria.ExcludeAgentID || specialistsOnly && !specialises(agent, criteria.Intent) {
+            continue
+        }
+        if as.eligible(agent, criteria) {
+            candidates = append(candidates, agent)
+        }
+    }
+    if len(candidates) == 0 {
+        return nil
+    }
+
+    // Map order is random, strategies rely on ID order to break ties
+    sort.Slice(candidates, func(i, j int) bool {
+        return candidates[i].ID < candidates[j].ID
+    })
+    if as.languageRouting == LanguageRoutingSoft && criteria.Language != "" {
+        candidates = preferAgents(candidates, func(agent *models.Agent) bool {
+            return speaks(agent, criteria.Language)
+        })
+    }
+    if criteria.Priority >= HighPriority {
+        candidates = preferAgents(candidates, func(agent *models.Agent) bool {
+            return agent.Senior
+        })
+    }
+    if !reserving {
+        return as.strategyFor(criteria.Intent).Peek(candidates, criteria)
+    }
+    return as.strategyFor(criteria.Intent).Pick(candidates, criteria)
+}
+
+// preferAgents keeps the candidates matching prefer, or all of them when none do
+func preferAgents(candidates []*models.Agent, prefer func(*models.Agent) bool) []*models.Agent {
+    preferred := []*models.Agent{}
+    for _, agent := range candidates {
+        if prefer(agent) {
+            preferred = append(preferred, agent)
+        }
+    }
+    if len(preferred) == 0 {
+        return candidates
+    }
+    return preferred
+}
+
+func (as *AgentService) noAgentError(criteria AgentCriteria, specialistsOnly bool) error {
+    switch {
+    case specialistsOnly && as.requiresLanguage(criteria):
+        return fmt.Errorf("no available agent for intent %s speaking %s", criteria.Intent, criteria.Language)
+    case specialistsOnly:
+        return fmt.Errorf("no available agent for intent: %s", criteria.Intent)
+    case as.requiresLanguage(criteria):
+        return fmt.Errorf("no agent speaking %s available", criteria.Language)
+    default:
+        return fmt.Errorf("no agent available")
+    }
+}
+
+// snapshot copies an agent so it can be used after mu is released
+func snapshot(agent *models.Agent) *models.Agent {
+    copied := *agent
+    return &copied
+}
+
+// specialises reports whether intent is one of the agent's specialties
+func specialises(agent *models.Agent, intent string) bool {
+    for _, specialty := range agent.Specialties {
+        if specialty == intent {
+            return true
+        }
+    }
+    return false
+}
+
+// eligible reports whether agent can take more work that matches the hard constraints
+func (as *AgentService) eligible(agent *models.Agent, criteria AgentCriteria) bool {
+    if !agent.IsOnline || agent.CurrentLoad >= agent.MaxCapacity {
+        return false
+    }
+    return !as.requiresLanguage(criteria) || speaks(agent, criteria.Language)
+}
+
+func (as *AgentService) requiresLanguage(criteria AgentCriteria) bool {
+    return as.languageRouting == LanguageRoutingHard && criteria.Language != ""
+}
+
+// UncoveredIntents lists intents that no agent has as a specialty
+func (as *AgentService) UncoveredIntents(intents []Intent) []string {
+    as.mu.RLock()
+    defer as.mu.RUnlock()
+
+    uncovered := []string{}
+    for _, intent := range intents {
+        covered := false
+        for _, agent := range as.agents {
+            for _, specialty := range agent.Specialties {
+                if specialty == intent.Name {
+                    covered = true
+                }
             }
         }
-        
-        if canHandle && agent.IsOnline && agent.CurrentLoad < agent.MaxCapacity {
-            return agent, nil
+        if !covered {
+            uncovered = append(uncovered, intent.Name)
         }
     }
-    
-    return nil, fmt.Errorf("no available agent for intent: %s", intent)
+    return uncovered
 }
 
+// AssignQuery adds a query to an agent chosen earlier, without a capacity check.
+// Prefer ReserveAgent, which picks and assigns in one step.
 func (as *AgentService) AssignQuery(agentID string) {
+    as.mu.Lock()
+    defer as.mu.Unlock()
+
     if agent, exists := as.agents[agentID]; exists {
         agent.CurrentLoad++
     }
 }
 
+// ReleaseQuery frees the capacity an assignment took when it is closed
+func (as *AgentService) ReleaseQuery(agentID string) {
+    as.mu.Lock()
+    agent, exists := as.agents[agentID]
+    freed := exists && agent.CurrentLoad > 0
+    if freed {
+        agent.CurrentLoad--
+    }
+    listeners := as.capacityFreed
+    as.mu.Unlock()
+
+    if freed {
+        for _, listener := range listeners {
+            listener()
+        }
+    }
+}
+
+// OnCapacityFreed registers listener to be called whenever an agent gets capacity back.
+// It runs on the releasing goroutine, so it must return quickly.
+func (as *AgentService) OnCapacityFreed(listener func()) {
+    as.mu.Lock()
+    defer as.mu.Unlock()
+    as.capacityFreed = append(as.capacityFreed, listener)
+}
+
+// ServingCapacity sums the capacity of the online agents who could take work for the criteria,
+// busy or not: specialists for the intent, or anyone when specialistsOnly is false
+func (as *AgentService) ServingCapacity(criteria AgentCriteria, specialistsOnly bool) int {
+    as.mu.RLock()
+    defer as.mu.RUnlock()
+
+    capacity := 0
+    for _, agent := range as.agents {
+        if !agent.IsOnline || agent.ID == criteria.ExcludeAgentID || specialistsOnly && !specialises(agent, criteria.Intent) {
+            continue
+        }
+        if !as.requiresLanguage(criteria) || speaks(agent, criteria.Language) {
+            capacity += agent.MaxCapacity
+        }
+    }
+    return capacity
+}
+
 func initializeAgents() map[string]*models.Agent {
     return map[string]*models.Agent{
         "billing-specialist": {
@@ -48,6 +360,19 @@ func initializeAgents() map[string]*models.Agent {
             MaxCapacity: 5,
             CurrentLoad: 2,
             IsOnline:    true,
+            Senior:      true,
+            Languages:   []string{"en", "es"},
+            SkillLevels: map[string]int{"billing_discrepancies": 5, "refund_processing_issues": 4},
+        },
+        "billing-associate": {
+            ID:          "billing-associate",
+            Name:        "Priya - Billing Support",
+            Specialties: []string{"billing_discrepancies", "refund_processing_issues"},
+            MaxCapacity: 5,
+            CurrentLoad: 1,
+            IsOnline:    true,
+            Languages:   []string{"en"},
+            SkillLevels: map[string]int{"billing_discrepancies": 3, "refund_processing_issues": 3},
         },
         "account-helper": {
             ID:          "account-helper",
@@ -56,6 +381,8 @@ func initializeAgents() map[string]*models.Agent {
             MaxCapacity: 3,
             CurrentLoad: 3, // At capacity!
             IsOnline:    true,
+            Languages:   []string{"en", "fr"},
+            SkillLevels: map[string]int{"account_access_issues": 4},
         },
         "delivery-tracker": {
             ID:          "delivery-tracker",
@@ -64,6 +391,8 @@ func initializeAgents() map[string]*models.Agent {
             MaxCapacity: 4,
             CurrentLoad: 1,
             IsOnline:    true,
+            Languages:   []string{"en", "fr"},
+            SkillLevels: map[string]int{"delivery_problems": 4, "order_status_uncertainty": 5, "return_process_inquiries": 2},
         },
         "product-expert": {
             ID:          "product-expert",
@@ -72,6 +401,8 @@ func initializeAgents() map[string]*models.Agent {
             MaxCapacity: 6,
             CurrentLoad: 0,
             IsOnline:    true,
+            Languages:   []string{"en"},
+            SkillLevels: map[string]int{"product_quality_concerns": 4, "product_availability_inquiries": 5, "warranty_terms_inquiries": 2},
         },
         "returns-processor": {
             ID:          "returns-processor",
@@ -80,6 +411,9 @@ func initializeAgents() map[string]*models.Agent {
             MaxCapacity: 4,
             CurrentLoad: 2,
             IsOnline:    true,
+            Senior:      true,
+            Languages:   []string{"en", "es", "fr"},
+            SkillLevels: map[string]int{"return_process_inquiries": 5, "order_cancellation_requests": 4, "refund_processing_issues": 2},
         },
         "warranty-advisor": {
             ID:          "warranty-advisor",
@@ -88,6 +422,8 @@ func initializeAgents() map[string]*models.Agent {
             MaxCapacity: 3,
             CurrentLoad: 1,
             IsOnline:    true,
+            Languages:   []string{"en", "es"},
+            SkillLevels: map[string]int{"warranty_terms_inquiries": 4, "product_quality_concerns": 2},
         },
         "tech-support": {
             ID:          "tech-support",
@@ -96,23 +432,41 @@ func initializeAgents() map[string]*models.Agent {
             MaxCapacity: 5,
             CurrentLoad: 0,
             IsOnline:    false, // Offline for maintenance
+            Languages:   []string{"en"},
+            SkillLevels: map[string]int{"installation_support_requests": 4},
         },
     }
 }
 
-// GetAllAgents returns all agents and their current status
+// GetAllAgents returns a copy of all agents and their current status
 func (as *AgentService) GetAllAgents() map[string]*models.Agent {
-    return as.agents
+    as.mu.RLock()
+    defer as.mu.RUnlock()
+
+    agents := make(map[string]*models.Agent, len(as.agents))
+    for id, agent := range as.agents {
+        agents[id] = snapshot(agent)
+    }
+    return agents
 }
 
-// GetAgent returns a specific agent by ID
+// GetAgent returns a copy of a specific agent by ID
 func (as *AgentService) GetAgent(agentID string) (*models.Agent, bool) {
+    as.mu.RLock()
+    defer as.mu.RUnlock()
+
     agent, exists := as.agents[agentID]
-    return agent, exists
+    if !exists {
+        return nil, false
+    }
+    return snapshot(agent), true
 }
 
 // GetAgentStats returns summary statistics
 func (as *AgentService) GetAgentStats() map[string]interface{} {
+    as.mu.RLock()
+    defer as.mu.RUnlock()
+
     totalAgents := len(as.agents)
     onlineAgents := 0
     totalCapacity := 0
@@ -127,11 +481,13 @@ func (as *AgentService) GetAgentStats() map[string]interface{} {
     }
     
     return map[string]interface{}{
-        "total_agents":    totalAgents,
-        "online_agents":   onlineAgents,
-        "offline_agents":  totalAgents - onlineAgents,
-        "total_capacity":  totalCapacity,
-        "current_load":    totalLoad,
-        "utilization":     float64(totalLoad) / float64(totalCapacity),
+        "total_agents":      totalAgents,
+        "online_agents":     onlineAgents,
+        "offline_agents":    totalAgents - onlineAgents,
+        "total_capacity":    totalCapacity,
+        "current_load":      totalLoad,
+        "utilization":       float64(totalLoad) / float64(totalCapacity),
+        "routing_strategy":  as.strategy.Name(),
+        "intent_strategies": as.intentStrategyNames(),
     }
 }
\ No newline at end of file
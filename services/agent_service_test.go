@@ -0,0 +1,92 @@
+package services
+
+import (
+    "sync"
+    "testing"
+
+    "customer-query-router/models"
+)
+
+// testAgentService holds only the given agents instead of the built-in ones
+func testAgentService(agents ...*models.Agent) *AgentService {
+    as := NewAgentService()
+    as.agents = make(map[string]*models.Agent)
+    for _, agent := range agents {
+        as.agents[agent.ID] = agent
+    }
+    return as
+}
+
+// Run with -race: concurrent reservations must never push an agent past MaxCapacity
+func TestReserveAgentNeverExceedsCapacity(t *testing.T) {
+    as := NewAgentService()
+    criteria := AgentCriteria{Intent: "billing_discrepancies", Priority: DefaultPriority}
+
+    free := 0
+    for _, agent := range as.GetAllAgents() {
+        if specialises(agent, criteria.Intent) && agent.IsOnline {
+            free += agent.MaxCapacity - agent.CurrentLoad
+        }
+    }
+
+    const requests = 100
+    var wg sync.WaitGroup
+    var mu sync.Mutex
+    reserved := 0
+    for i := 0; i < requests; i++ {
+        wg.Add(1)
+        go func() {
+            defer wg.Done()
+            if _, err := as.ReserveAgent(criteria); err == nil {
+                mu.Lock()
+                reserved++
+                mu.Unlock()
+            }
+        }()
+        // Readers run alongside the reservations
+        wg.Add(1)
+        go func() {
+            defer wg.Done()
+            as.GetAgentStats()
+            as.FindAvailableAgent(criteria)
+        }()
+    }
+    wg.Wait()
+
+    if reserved != free {
+        t.Errorf("reserved %d slots, want exactly the %d free ones", reserved, free)
+    }
+    for _, agent := range as.GetAllAgents() {
+        if agent.CurrentLoad > agent.MaxCapacity {
+            t.Errorf("%s is at %d/%d", agent.ID, agent.CurrentLoad, agent.MaxCapacity)
+        }
+    }
+}
+
+func TestReserveAnyAgentWithReleases(t *testing.T) {
+    as := NewAgentService()
+    criteria := AgentCriteria{Priority: DefaultPriority}
+
+    var wg sync.WaitGroup
+    for i := 0; i < 200; i++ {
+        wg.Add(1)
+        go func() {
+            defer wg.Done()
+            agent, err := as.ReserveAnyAgent(criteria)
+            if err != nil {
+                return
+            }
+            if agent.CurrentLoad > agent.MaxCapacity {
+                t.Errorf("%s reserved at %d/%d", agent.ID, agent.CurrentLoad, agent.MaxCapacity)
+            }
+            as.ReleaseQuery(agent.ID)
+        }()
+    }
+    wg.Wait()
+
+    for _, agent := range as.GetAllAgents() {
+        if agent.CurrentLoad > agent.MaxCapacity {
+            t.Errorf("%s is at %d/%d", agent.ID, agent.CurrentLoad, agent.MaxCapacity)
+        }
+    }
+}
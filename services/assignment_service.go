@@ -0,0 +1,360 @@
+package services
+
+import (
+    "errors"
+    "fmt"
+    "log"
+    "sort"
+    "sync"
+    "sync/atomic"
+    "time"
+
+    "customer-query-router/models"
+)
+
+// Assignment statuses. Pending and accepted assignments are open and hold agent capacity.
+const (
+    AssignmentPending     = "pending"
+    AssignmentAccepted    = "accepted"
+    AssignmentCompleted   = "completed"
+    AssignmentAbandoned   = "abandoned"
+    AssignmentTransferred = "transferred"
+    AssignmentExpired     = "expired"
+
+    DefaultAssignmentTimeout = 30 * time.Minute
+
+    // Closed assignments are kept this long for the API, then forgotten
+    closedAssignmentRetention = 24 * time.Hour
+
+    // Weight of the latest completion in the moving average handle time
+    handleTimeSmoothing = 0.2
+)
+
+var (
+    ErrAssignmentNotFound = errors.New("assignment not found")
+    // ErrAssignmentState is returned for a transition the assignment's status does not allow
+    ErrAssignmentState = errors.New("invalid assignment state")
+)
+
+var assignmentCounter int64
+
+// AssignmentService tracks every routed ticket until its agent completes, abandons or
+// transfers it, giving the capacity back to the agent. Pending assignments nobody accepts
+// within the timeout expire, so a forgotten ticket cannot hold an agent's capacity forever.
+// Accepted ones are being worked on and only close through their agent.
+type AssignmentService struct {
+    agentService *AgentService
+    timeout      time.Duration // Until a pending assignment expires, 0 never
+
+    mu          sync.Mutex
+    assignments map[string]*models.Assignment
+    handleTimes map[string]time.Duration // Intent -> moving average time from open to completed
+    released    []string                 // Agents of assignments closed under mu, released by unlock
+    now         func() time.Time
+}
+
+func NewAssignmentService(agentService *AgentService, timeout time.Duration) *AssignmentService {
+    log.Printf("[ASSIGNMENTS] Pending assignments expire after %v if not accepted (0 never)", timeout)
+
+    return &AssignmentService{
+        agentService: agentService,
+        timeout:      timeout,
+        assignments:  make(map[string]*models.Assignment),
+        handleTimes:  make(map[string]time.Duration),
+        now:          time.Now,
+    }
+}
+
+// Open records that agent was reserved for a ticket, a sub-ticket of parentTicketID when that
+// is set. The agent's capacity must already be taken, e.g. by AgentService.ReserveAgent.
+func (as *AssignmentService) Open(ticketID, parentTicketID string, agent *models.Agent, criteria AgentCriteria) *models.Assignment {
+    as.mu.Lock()
+    defer as.mu.Unlock()
+    return as.open(ticketID, parentTicketID, agent.ID, criteria)
+}
+
+func (as *AssignmentService) open(ticketID, parentTicketID, agentID string, criteria AgentCriteria) *models.Assignment {
+    now := as.now()
+    assignment := &models.Assignment{
+        ID:             fmt.Sprintf("ASG-%06d", atomic.AddInt64(&assignmentCounter, 1)),
+        TicketID:       ticketID,
+        ParentTicketID: parentTicketID,
+        AgentID:        agentID,
+        Intent:         criteria.Intent,
+        Priority:       criteria.Priority,
+        Language:       criteria.Language,
+        Status:         AssignmentPending,
+        CreatedAt:      now,
+        UpdatedAt:      now,
+    }
+    as.assignments[assignment.ID] = assignment
+    return as.view(assignment)
+}
+
+// Get returns a copy of an assignment
+func (as *AssignmentService) Get(id string) (*models.Assignment, error) {
+    as.mu.Lock()
+    defer as.mu.Unlock()
+
+    assignment, ok := as.assignments[id]
+    if !ok {
+        return nil, fmt.Errorf("%w: %s", ErrAssignmentNotFound, id)
+    }
+    return as.view(assignment), nil
+}
+
+// List returns assignments, newest first, optionally filtered by status and agent
+func (as *AssignmentService) List(status, agentID string) []*models.Assignment {
+    as.mu.Lock()
+    defer as.mu.Unlock()
+
+    list := []*models.Assignment{}
+    for _, assignment := range as.assignments {
+        if (status == "" || assignment.Status == status) && (agentID == "" || assignment.AgentID == agentID) {
+            list = append(list, as.view(assignment))
+        }
+    }
+    sort.Slice(list, func(i, j int) bool {
+        return list[i].ID > list[j].ID
+    })
+    return list
+}
+
+// OpenIntents returns the intents of the open assignments of a ticket and its sub-tickets
+func (as *AssignmentService) OpenIntents(ticketID string) map[string]bool {
+    as.mu.Lock()
+    defer as.mu.Unlock()
+
+    intents := make(map[string]bool)
+    for _, assignment := range as.assignments {
+        if as.isOpen(assignment) && (assignment.TicketID == ticketID || assignment.ParentTicketID == ticketID) {
+            intents[assignment.Intent] = true
+        }
+    }
+    return intents
+}
+
+// Stats counts assignments by status
+func (as *AssignmentService) Stats() map[string]interface{} {
+    as.mu.Lock()
+    defer as.mu.Unlock()
+
+    counts := make(map[string]int64)
+    for _, assignment := range as.assignments {
+        counts[assignment.Status]++
+    }
+    return map[string]interface{}{
+        "by_status": counts,
+        "timeout":   as.timeout.String(),
+    }
+}
+
+// Accept marks a pending assignment as taken up by its agent
+func (as *AssignmentService) Accept(id string) (*models.Assignment, error) {
+    as.mu.Lock()
+    defer as.mu.Unlock()
+
+    assignment, err := as.find(id, AssignmentPending)
+    if err != nil {
+        return nil, err
+    }
+    assignment.Status = AssignmentAccepted
+    assignment.UpdatedAt = as.now()
+    return as.view(assignment), nil
+}
+
+// Complete closes an accepted assignment and gives the capacity back
+func (as *AssignmentService) Complete(id string) (*models.Assignment, error) {
+    as.mu.Lock()
+    defer as.unlock()
+
+    assignment, err := as.find(id, AssignmentAccepted)
+    if err != nil {
+        return nil, err
+    }
+    as.close(assignment, AssignmentCompleted)
+
+    handleTime := assignment.UpdatedAt.Sub(assignment.CreatedAt)
+    if average, ok := as.handleTimes[assignment.Intent]; ok {
+        handleTime = average + time.Duration(handleTimeSmoothing*float64(handleTime-average))
+    }
+    as.handleTimes[assignment.Intent] = handleTime
+    return as.view(assignment), nil
+}
+
+// AverageHandleTime returns the moving average time from routing to completion for intent,
+// 0 before anything was completed
+func (as *AssignmentService) AverageHandleTime(intent string) time.Duration {
+    as.mu.Lock()
+    defer as.mu.Unlock()
+    return as.handleTimes[intent]
+}
+
+// Abandon closes an open assignment the agent cannot finish and gives the capacity back
+func (as *AssignmentService) Abandon(id string) (*models.Assignment, error) {
+    as.mu.Lock()
+    defer as.unlock()
+
+    assignment, err := as.find(id, AssignmentPending, AssignmentAccepted)
+    if err != nil {
+        return nil, err
+    }
+    as.close(assignment, AssignmentAbandoned)
+    return as.view(assignment), nil
+}
+
+// Transfer hands an open assignment to toAgentID, or to the best other agent for its
+// intent when toAgentID is empty. The ticket continues under a new pending assignment,
+// which is returned; the old one is closed and its agent's capacity given back.
+// The SLA monitor does not count transfers, the ticket already had its first agent.
+func (as *AssignmentService) Transfer(id, toAgentID string) (*models.Assignment, error) {
+    as.mu.Lock()
+    defer as.unlock()
+
+    assignment, err := as.find(id, AssignmentPending, AssignmentAccepted)
+    if err != nil {
+        return nil, err
+    }
+
+    var agent *models.Agent
+    criteria := AgentCriteria{
+        Intent:         assignment.Intent,
+        Priority:       assignment.Priority,
+        Language:       assignment.Language,
+        ExcludeAgentID: assignment.AgentID,
+    }
+    switch {
+    case toAgentID == assignment.AgentID:
+        return nil, fmt.Errorf("%w: %s is already assigned to %s", ErrAssignmentState, id, toAgentID)
+    case toAgentID != "":
+        agent, err = as.agentService.ReserveAgentByID(toAgentID)
+    default:
+        agent, err = as.agentService.ReserveAgent(criteria)
+        if err != nil {
+            agent, err = as.agentService.ReserveAnyAgent(criteria)
+        }
+    }
+    if err != nil {
+        return nil, fmt.Errorf("transfer failed: %w", err)
+    }
+
+    next := as.transfer(assignment, agent.ID, criteria)
+    log.Printf("[ASSIGNMENTS] %s transferred from %s to %s as %s", assignment.ID, assignment.AgentID, agent.ID, next.ID)
+    return next, nil
+}
+
+// TransferTo closes an open assignment because the ticket now continues under the agent
+// reserved for newAssignmentID, e.g. after a conversation switched topic
+func (as *AssignmentService) TransferTo(id, newAssignmentID string) error {
+    as.mu.Lock()
+    defer as.unlock()
+
+    assignment, err := as.find(id, AssignmentPending, AssignmentAccepted)
+    if err != nil {
+        return err
+    }
+    if next, ok := as.assignments[newAssignmentID]; ok {
+        next.TransferredFrom = assignment.ID
+    }
+    assignment.TransferredTo = newAssignmentID
+    as.close(assignment, AssignmentTransferred)
+    return nil
+}
+
+// transfer opens the follow-up assignment for an agent already reserved. Callers hold mu.
+func (as *AssignmentService) transfer(assignment *models.Assignment, agentID string, criteria AgentCriteria) *models.Assignment {
+    next := as.open(assignment.TicketID, assignment.ParentTicketID, agentID, criteria)
+    as.assignments[next.ID].TransferredFrom = assignment.ID
+    next.TransferredFrom = assignment.ID
+
+    assignment.TransferredTo = next.ID
+    as.close(assignment, AssignmentTransferred)
+    return next
+}
+
+// ExpireStale expires pending assignments not accepted within the timeout and returns how many
+func (as *AssignmentService) ExpireStale() int {
+    as.mu.Lock()
+    defer as.unlock()
+
+    now := as.now()
+    expired := 0
+    for id, assignment := range as.assignments {
+        switch {
+        case assignment.Status == AssignmentPending && as.timeout > 0 && now.Sub(assignment.UpdatedAt) >= as.timeout:
+            as.close(assignment, AssignmentExpired)
+            log.Printf("[ASSIGNMENTS] %s expired, not accepted within %v, %s released", id, as.timeout, assignment.AgentID)
+            expired++
+        case !as.isOpen(assignment) && now.Sub(assignment.UpdatedAt) >= closedAssignmentRetention:
+            delete(as.assignments, id)
+        }
+    }
+    return expired
+}
+
+// Watch expires stale assignments every interval until stop is closed
+func (as *AssignmentService) Watch(interval time.Duration, stop <-chan struct{}) {
+    if as.timeout <= 0 || interval <= 0 {
+        return
+    }
+
+    ticker := time.NewTicker(interval)
+    defer ticker.Stop()
+
+    for {
+        select {
+        case <-stop:
+            return
+        case <-ticker.C:
+            as.ExpireStale()
+        }
+    }
+}
+
+// find returns the stored assignment if its status is one of allowed. Callers hold mu.
+func (as *AssignmentService) find(id string, allowed ...string) (*models.Assignment, error) {
+    assignment, ok := as.assignments[id]
+    if !ok {
+        return nil, fmt.Errorf("%w: %s", ErrAssignmentNotFound, id)
+    }
+    for _, status := range allowed {
+        if assignment.Status == status {
+            return assignment, nil
+        }
+    }
+    return nil, fmt.Errorf("%w: %s is %s", ErrAssignmentState, id, assignment.Status)
+}
+
+func (as *AssignmentService) isOpen(assignment *models.Assignment) bool {
+    return assignment.Status == AssignmentPending || assignment.Status == AssignmentAccepted
+}
+
+// close moves an open assignment to a final status. Its agent is released once the caller,
+// who holds mu, lets go of it with unlock.
+func (as *AssignmentService) close(assignment *models.Assignment, status string) {
+    assignment.Status = status
+    assignment.UpdatedAt = as.now()
+    as.released = append(as.released, assignment.AgentID)
+}
+
+// unlock releases mu, then the agents of the assignments closed meanwhile. Releasing runs the
+// capacity listeners, such as the queue's dispatch, which must not run under mu.
+func (as *AssignmentService) unlock() {
+    released := as.released
+    as.released = nil
+    as.mu.Unlock()
+
+    for _, agentID := range released {
+        as.agentService.ReleaseQuery(agentID)
+    }
+}
+
+// view copies an assignment for callers, with its expiry while it is pending. Callers hold mu.
+func (as *AssignmentService) view(assignment *models.Assignment) *models.Assignment {
+    copied := *assignment
+    if assignment.Status == AssignmentPending && as.timeout > 0 {
+        expiresAt := assignment.UpdatedAt.Add(as.timeout)
+        copied.ExpiresAt = &expiresAt
+    }
+    return &copied
+}
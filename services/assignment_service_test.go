@@ -0,0 +1,173 @@
+package services
+
+import (
+    "errors"
+    "testing"
+    "time"
+
+    "customer-query-router/models"
+)
+
+func TestAssignmentLifecycle(t *testing.T) {
+    agents := testAgentService(
+        &models.Agent{ID: "a", Specialties: []string{"billing_discrepancies"}, MaxCapacity: 3, IsOnline: true},
+        &models.Agent{ID: "b", Specialties: []string{"billing_discrepancies"}, MaxCapacity: 3, IsOnline: true},
+    )
+    assignments := NewAssignmentService(agents, 30*time.Minute)
+    now := time.Now()
+    assignments.now = func() time.Time { return now }
+    criteria := AgentCriteria{Intent: "billing_discrepancies"}
+
+    // open reserves agent a, like routing does, and opens an assignment for them
+    open := func() string {
+        agent, err := agents.ReserveAgentByID("a")
+        if err != nil {
+            t.Fatal(err)
+        }
+        return assignments.Open(NewTicketID(), "", agent, criteria).ID
+    }
+    first, second, third, fourth := open(), open(), open(), ""
+
+    steps := []struct {
+        name    string
+        advance time.Duration
+        action  func() (*models.Assignment, error)
+        status  string // Of the returned assignment
+        err     error
+        loadA   int
+        loadB   int
+    }{
+        {"accept", time.Minute, func() (*models.Assignment, error) { return assignments.Accept(first) }, AssignmentAccepted, nil, 3, 0},
+        {"accept twice", 0, func() (*models.Assignment, error) { return assignments.Accept(first) }, "", ErrAssignmentState, 3, 0},
+        {"complete pending", 0, func() (*models.Assignment, error) { return assignments.Complete(second) }, "", ErrAssignmentState, 3, 0},
+        {"complete", 4 * time.Minute, func() (*models.Assignment, error) { return assignments.Complete(first) }, AssignmentCompleted, nil, 2, 0},
+        {"abandon closed", 0, func() (*models.Assignment, error) { return assignments.Abandon(first) }, "", ErrAssignmentState, 2, 0},
+        {"unknown", 0, func() (*models.Assignment, error) { return assignments.Accept("ASG-999999") }, "", ErrAssignmentNotFound, 2, 0},
+        {"abandon", 0, func() (*models.Assignment, error) { return assignments.Abandon(second) }, AssignmentAbandoned, nil, 1, 0},
+        {"transfer to the same agent", 0, func() (*models.Assignment, error) { return assignments.Transfer(third, "a") }, "", ErrAssignmentState, 1, 0},
+        {"transfer", 0, func() (*models.Assignment, error) {
+            next, err := assignments.Transfer(third, "")
+            if err == nil {
+                fourth = next.ID
+            }
+            return next, err
+        }, AssignmentPending, nil, 0, 1},
+        {"accept after transfer", 0, func() (*models.Assignment, error) { return assignments.Accept(third) }, "", ErrAssignmentState, 0, 1},
+    }
+
+    for _, step := range steps {
+        now = now.Add(step.advance)
+        assignment, err := step.action()
+        if !errors.Is(err, step.err) {
+            t.Fatalf("%s: error %v, want %v", step.name, err, step.err)
+        }
+        if err == nil && assignment.Status != step.status {
+            t.Errorf("%s: status %s, want %s", step.name, assignment.Status, step.status)
+        }
+        a, _ := agents.GetAgent("a")
+        b, _ := agents.GetAgent("b")
+        if a.CurrentLoad != step.loadA || b.CurrentLoad != step.loadB {
+            t.Errorf("%s: loads %d and %d, want %d and %d", step.name, a.CurrentLoad, b.CurrentLoad, step.loadA, step.loadB)
+        }
+    }
+
+    if handleTime := assignments.AverageHandleTime("billing_discrepancies"); handleTime != 5*time.Minute {
+        t.Errorf("average handle time %v, want 5m", handleTime)
+    }
+    transferred, _ := assignments.Get(third)
+    next, _ := assignments.Get(fourth)
+    if transferred.TransferredTo != fourth || next.TransferredFrom != third || next.AgentID != "b" {
+        t.Errorf("transfer not linked: %+v -> %+v", transferred, next)
+    }
+}
+
+func TestAssignmentExpiryFreesOnlyPendingAssignments(t *testing.T) {
+    agents := testAgentService(&models.Agent{ID: "a", Specialties: []string{"billing_discrepancies"}, MaxCapacity: 3, IsOnline: true})
+    assignments := NewAssignmentService(agents, 30*time.Minute)
+    now := time.Now()
+    assignments.now = func() time.Time { return now }
+    criteria := AgentCriteria{Intent: "billing_discrepancies"}
+
+    var ids []string
+    for i := 0; i < 2; i++ {
+        agent, err := agents.ReserveAgent(criteria)
+        if err != nil {
+            t.Fatal(err)
+        }
+        ids = append(ids, assignments.Open(NewTicketID(), "", agent, criteria).ID)
+    }
+    accepted, err := assignments.Accept(ids[1])
+    if err != nil {
+        t.Fatal(err)
+    }
+    if accepted.ExpiresAt != nil {
+        t.Errorf("accepted assignment expires at %v", accepted.ExpiresAt)
+    }
+
+    now = now.Add(29 * time.Minute)
+    if n := assignments.ExpireStale(); n != 0 {
+        t.Fatalf("expired %d before the timeout", n)
+    }
+    now = now.Add(time.Minute)
+    if n := assignments.ExpireStale(); n != 1 {
+        t.Fatalf("expired %d, want the pending one", n)
+    }
+
+    expired, _ := assignments.Get(ids[0])
+    if expired.Status != AssignmentExpired {
+        t.Errorf("pending assignment is %s, want %s", expired.Status, AssignmentExpired)
+    }
+    if agent, _ := agents.GetAgent("a"); agent.CurrentLoad != 1 {
+        t.Errorf("load %d after expiry, want 1", agent.CurrentLoad)
+    }
+
+    // Hours of work on the accepted one still end in a normal completion
+    now = now.Add(3 * time.Hour)
+    assignments.ExpireStale()
+    if _, err := assignments.Complete(ids[1]); err != nil {
+        t.Errorf("completing a long accepted assignment: %v", err)
+    }
+    if agent, _ := agents.GetAgent("a"); agent.CurrentLoad != 0 {
+        t.Errorf("load %d after completion, want 0", agent.CurrentLoad)
+    }
+}
+
+// Capacity listeners run after the assignment service lets go of its lock, so they may use it
+func TestReleasedCapacityListenersCanUseAssignments(t *testing.T) {
+    agents := testWarrantyAgents()
+    assignments := NewAssignmentService(agents, time.Minute)
+    now := time.Now()
+    assignments.now = func() time.Time { return now }
+    criteria := AgentCriteria{Intent: "warranty_terms_inquiries"}
+
+    var open []int
+    agents.OnCapacityFreed(func() {
+        open = append(open, len(assignments.List(AssignmentPending, "")))
+    })
+
+    ids := []string{}
+    for i := 0; i < 2; i++ {
+        agent, err := agents.ReserveAgent(criteria)
+        if err != nil {
+            t.Fatal(err)
+        }
+        ids = append(ids, assignments.Open(NewTicketID(), "", agent, criteria).ID)
+    }
+
+    done := make(chan struct{})
+    go func() {
+        defer close(done)
+        assignments.Abandon(ids[0])
+        now = now.Add(time.Minute)
+        assignments.ExpireStale()
+    }()
+    select {
+    case <-done:
+    case <-time.After(5 * time.Second):
+        t.Fatal("closing an assignment deadlocked in a capacity listener")
+    }
+
+    if len(open) != 2 || open[0] != 1 || open[1] != 0 {
+        t.Errorf("listeners saw %v pending assignments, want [1 0]", open)
+    }
+}
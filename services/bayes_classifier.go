@@ -0,0 +1,294 @@
+package services
+
+import (
+    "bufio"
+    "context"
+    "encoding/json"
+    "fmt"
+    "log"
+    "math"
+    "os"
+    "strings"
+    "sync/atomic"
+    "time"
+
+    "customer-query-router/models"
+)
+
+// LabeledExample is one training message with its intent
+type LabeledExample struct {
+    Intent string
+    Text   string
+}
+
+// BayesModel is a multinomial Naive Bayes model with Laplace smoothing.
+// It is plain data so it can be written to and read from disk as JSON.
+type BayesModel struct {
+    TrainedAt   time.Time                 `json:"trained_at"`
+    TotalDocs   int                       `json:"total_docs"`
+    DocCounts   map[string]int            `json:"doc_counts"`
+    TokenCounts map[string]map[string]int `json:"token_counts"`
+    TokenTotals map[string]int            `json:"token_totals"`
+    Vocabulary  map[string]bool           `json:"vocabulary"`
+}
+
+// TrainBayesModel fits a model on the labeled examples
+func TrainBayesModel(examples []LabeledExample) (*BayesModel, error) {
+    if len(examples) == 0 {
+        return nil, fmt.Errorf("no training examples")
+    }
+
+    model := &BayesModel{
+        TrainedAt:   time.Now(),
+        DocCounts:   make(map[string]int),
+        TokenCounts: make(map[string]map[string]int),
+        TokenTotals: make(map[string]int),
+        Vocabulary:  make(map[string]bool),
+    }
+
+    for _, example := range examples {
+        model.TotalDocs++
+        model.DocCounts[example.Intent]++
+        if model.TokenCounts[example.Intent] == nil {
+            model.TokenCounts[example.Intent] = make(map[string]int)
+        }
+
+        for _, token := range Tokenize(example.Text) {
+            model.TokenCounts[example.Intent][token]++
+            model.TokenTotals[example.Intent]++
+            model.Vocabulary[token] = true
+        }
+    }
+
+    return model, nil
+}
+
+// LogScores returns the unnormalized log posterior of every trained intent.
+// known is false when none of the tokens were seen during training.
+func (m *BayesModel) LogScores(text string) (scores map[string]float64, known bool) {
+    tokens := Tokenize(text)
+    vocabSize := float64(len(m.Vocabulary))
+    scores = make(map[string]float64, len(m.DocCounts))
+
+    for intent, docs := range m.DocCounts {
+        score := math.Log(float64(docs) / float64(m.TotalDocs))
+        denominator := float64(m.TokenTotals[intent]) + vocabSize
+        for _, token := range tokens {
+            if !m.Vocabulary[token] {
+                continue
+            }
+            known = true
+            score += math.Log((float64(m.TokenCounts[intent][token]) + 1) / denominator)
+        }
+        scores[intent] = score
+    }
+
+    return scores, known
+}
+
+// SaveBayesModel writes the model to disk as JSON
+func SaveBayesModel(model *BayesModel, filename string) error {
+    data, err := json.Marshal(model)
+    if err != nil {
+        return fmt.Errorf("error encoding model: %w", err)
+    }
+    if err := os.WriteFile(filename, data, 0644); err != nil {
+        return fmt.Errorf("error writing model file: %w", err)
+    }
+    return nil
+}
+
+// LoadBayesModel reads a model written by SaveBayesModel
+func LoadBayesModel(filename string) (*BayesModel, error) {
+    data, err := os.ReadFile(filename)
+    if err != nil {
+        return nil, fmt.Errorf("error reading model file: %w", err)
+    }
+
+    var model BayesModel
+    if err := json.Unmarshal(data, &model); err != nil {
+        return nil, fmt.Errorf("error parsing model file: %w", err)
+    }
+    if model.TotalDocs == 0 {
+        return nil, fmt.Errorf("model file %s contains no training data", filename)
+    }
+    return &model, nil
+}
+
+// LoadLabeledExamples reads "intent<TAB>text" lines. Blank lines and lines
+// starting with # are ignored.
+func LoadLabeledExamples(filename string) ([]LabeledExample, error) {
+    file, err := os.Open(filename)
+    if err != nil {
+        return nil, fmt.Errorf("error opening labels file: %w", err)
+    }
+    defer file.Close()
+
+    var examples []LabeledExample
+    scanner := bufio.NewScanner(file)
+    scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+    lineNumber := 0
+
+    for scanner.Scan() {
+        lineNumber++
+        line := strings.TrimSpace(scanner.Text())
+        if line == "" || strings.HasPrefix(line, "#") {
+            continue
+        }
+
+        parts := strings.SplitN(line, "\t", 2)
+        if len(parts) != 2 {
+            return nil, fmt.Errorf("labels file line %d: expected intent<TAB>text", lineNumber)
+        }
+        examples = append(examples, LabeledExample{
+            Intent: strings.TrimSpace(parts[0]),
+            Text:   strings.TrimSpace(parts[1]),
+        })
+    }
+
+    return examples, scanner.Err()
+}
+
+// SaveLabeledExamples writes examples in the format read by LoadLabeledExamples,
+// after a # line for each comment
+func SaveLabeledExamples(examples []LabeledExample, filename string, comments ...string) error {
+    file, err := os.Create(filename)
+    if err != nil {
+        return fmt.Errorf("error creating labels file: %w", err)
+    }
+    defer file.Close()
+
+    writer := bufio.NewWriter(file)
+    for _, comment := range comments {
+        fmt.Fprintf(writer, "# %s\n", comment)
+    }
+    fmt.Fprintln(writer, "# intent<TAB>customer text")
+    for _, example := range examples {
+        text := strings.Join(strings.Fields(example.Text), " ")
+        fmt.Fprintf(writer, "%s\t%s\n", example.Intent, text)
+    }
+    return writer.Flush()
+}
+
+// BayesClassifier serves a trained BayesModel through the Classifier interface
+type BayesClassifier struct {
+    taxonomy     *Taxonomy
+    model        *BayesModel
+    requestCount int64
+}
+
+func NewBayesClassifier(model *BayesModel, taxonomy *Taxonomy) (*BayesClassifier, error) {
+    intents := taxonomy.AllIntents()
+    for intent := range model.DocCounts {
+        if !isKnownIntent(intents, intent) {
+            return nil, fmt.Errorf("model contains unknown intent %q", intent)
+        }
+    }
+
+    log.Printf("[BAYES CLASSIFIER] Loaded model trained on %d examples (%d tokens, trained %s)",
+        model.TotalDocs, len(model.Vocabulary), model.TrainedAt.Format(time.RFC3339))
+
+    return &BayesClassifier{
+        taxonomy: taxonomy,
+        model:    model,
+    }, nil
+}
+
+// LoadOrTrainBayesModel loads the model file, training and saving it from the
+// labels file first when the model does not exist yet
+func LoadOrTrainBayesModel(modelFile, labelsFile string) (*BayesModel, error) {
+    if _, err := os.Stat(modelFile); err == nil || labelsFile == "" {
+        return LoadBayesModel(modelFile)
+    }
+
+    log.Printf("[BAYES CLASSIFIER] Model %s not found, training from %s", modelFile, labelsFile)
+    examples, err := LoadLabeledExamples(labelsFile)
+    if err != nil {
+        return nil, err
+    }
+    model, err := TrainBayesModel(examples)
+    if err != nil {
+        return nil, err
+    }
+    if err := SaveBayesModel(model, modelFile); err != nil {
+        return nil, err
+    }
+    return model, nil
+}
+
+func (bc *BayesClassifier) ClassifyQuery(ctx context.Context, customerMessage string) (*models.ClassificationResult, error) {
+    atomic.AddInt64(&bc.requestCount, 1)
+
+    intents := bc.taxonomy.Intents()
+    scores, known := bc.model.LogScores(customerMessage)
+
+    // Intents disabled in the taxonomy drop out of the vote
+    for intent := range scores {
+        if !isKnownIntent(intents, intent) {
+            delete(scores, intent)
+        }
+    }
+    if len(scores) == 0 {
+        known = false
+    }
+    if !known {
+        return &models.ClassificationResult{
+            Intent:     "general",
+            Agent:      agentForIntent(intents, "general"),
+            Confidence: 0,
+            Backend:    bc.Name(),
+        }, nil
+    }
+
+    // Softmax over the log scores turns them into probabilities
+    bestScore := math.Inf(-1)
+    for _, score := range scores {
+        bestScore = math.Max(bestScore, score)
+    }
+    total := 0.0
+    for intent, score := range scores {
+        scores[intent] = math.Exp(score - bestScore)
+        total += scores[intent]
+    }
+    for intent := range scores {
+        scores[intent] /= total
+    }
+    ranked := rankIntents(intents, scores)
+
+    return &models.ClassificationResult{
+        Intent:       ranked[0].Intent,
+        Agent:        agentForIntent(intents, ranked[0].Intent),
+        Confidence:   ranked[0].Score,
+        Backend:      bc.Name(),
+        Alternatives: ranked[1:],
+    }, nil
+}
+
+// UncoveredIntents lists intents without training examples, which this backend can never predict
+func (bc *BayesClassifier) UncoveredIntents(intents []Intent) []string {
+    uncovered := []string{}
+    for _, intent := range intents {
+        if intent.Name != "general" && bc.model.DocCounts[intent.Name] == 0 {
+            uncovered = append(uncovered, intent.Name)
+        }
+    }
+    return uncovered
+}
+
+func (bc *BayesClassifier) GetAllIntents() []Intent {
+    return bc.taxonomy.Intents()
+}
+
+func (bc *BayesClassifier) GetStats() map[string]interface{} {
+    return map[string]interface{}{
+        "backend":         bc.Name(),
+        "total_requests":  atomic.LoadInt64(&bc.requestCount),
+        "training_docs":   bc.model.TotalDocs,
+        "vocabulary_size": len(bc.model.Vocabulary),
+        "trained_at":      bc.model.TrainedAt.Format(time.RFC3339),
+    }
+}
+
+func (bc *BayesClassifier) Name() string {
+    return "bayes"
+}
\ No newline at end of file
@@ -0,0 +1,102 @@
+package services
+
+import (
+    "context"
+    "math"
+    "path/filepath"
+    "testing"
+)
+
+func testExamples() []LabeledExample {
+    return []LabeledExample{
+        {Intent: "billing_discrepancies", Text: "I was charged twice on my invoice"},
+        {Intent: "billing_discrepancies", Text: "extra charge on my card statement"},
+        {Intent: "refund_processing_issues", Text: "still waiting for my refund money"},
+        {Intent: "delivery_problems", Text: "the parcel never arrived, courier lost it"},
+    }
+}
+
+func TestTrainBayesModelCountsExamples(t *testing.T) {
+    model, err := TrainBayesModel(testExamples())
+    if err != nil {
+        t.Fatal(err)
+    }
+
+    if model.TotalDocs != 4 || model.DocCounts["billing_discrepancies"] != 2 {
+        t.Errorf("counted %d docs, %d billing, want 4 and 2", model.TotalDocs, model.DocCounts["billing_discrepancies"])
+    }
+    if model.TokenCounts["billing_discrepancies"]["charged"] != 1 || !model.Vocabulary["refund"] {
+        t.Errorf("token counts not recorded: %+v", model.TokenCounts["billing_discrepancies"])
+    }
+    if _, err := TrainBayesModel(nil); err == nil {
+        t.Error("trained without examples")
+    }
+}
+
+func TestBayesClassifierScoresMessages(t *testing.T) {
+    model, _ := TrainBayesModel(testExamples())
+    bc, err := NewBayesClassifier(model, DefaultTaxonomy())
+    if err != nil {
+        t.Fatal(err)
+    }
+
+    tests := []struct {
+        message string
+        intent  string
+    }{
+        {"Why is there a double charge on my card?", "billing_discrepancies"},
+        {"Where is my refund?", "refund_processing_issues"},
+        {"The courier never delivered the parcel", "delivery_problems"},
+        // No token was seen in training
+        {"Bonjour", "general"},
+    }
+
+    for _, tt := range tests {
+        result, err := bc.ClassifyQuery(context.Background(), tt.message)
+        if err != nil {
+            t.Fatalf("%q: %v", tt.message, err)
+        }
+        if result.Intent != tt.intent {
+            t.Errorf("%q: got %s, want %s", tt.message, result.Intent, tt.intent)
+        }
+        if tt.intent == "general" {
+            continue
+        }
+
+        // Softmax probabilities over the trained intents add up to one
+        total := result.Confidence
+        for _, alternative := range result.Alternatives {
+            total += alternative.Score
+        }
+        if math.Abs(total-1) > 1e-9 || len(result.Alternatives) != 2 {
+            t.Errorf("%q: probabilities add up to %.3f over %d alternatives", tt.message, total, len(result.Alternatives))
+        }
+    }
+}
+
+func TestBayesModelRoundTrip(t *testing.T) {
+    model, _ := TrainBayesModel(testExamples())
+    filename := filepath.Join(t.TempDir(), "model.json")
+    if err := SaveBayesModel(model, filename); err != nil {
+        t.Fatal(err)
+    }
+
+    loaded, err := LoadBayesModel(filename)
+    if err != nil {
+        t.Fatal(err)
+    }
+    want, _ := model.LogScores("charged twice")
+    got, _ := loaded.LogScores("charged twice")
+    for intent, score := range want {
+        if math.Abs(got[intent]-score) > 1e-9 {
+            t.Errorf("%s scores %f after loading, want %f", intent, got[intent], score)
+        }
+    }
+}
+
+func TestBayesClassifierRejectsUnknownIntents(t *testing.T) {
+    model, _ := TrainBayesModel([]LabeledExample{{Intent: "teleportation", Text: "beam me up"}})
+    if _, err := NewBayesClassifier(model, DefaultTaxonomy()); err == nil {
+        t.Error("accepted a model with an intent missing from the taxonomy")
+    }
+}
@@ -0,0 +1,186 @@
+package services
+
+import (
+    "container/list"
+    "context"
+    "fmt"
+    "log"
+    "regexp"
+    "strings"
+    "sync"
+    "time"
+
+    "customer-query-router/models"
+)
+
+const (
+    DefaultCacheTTL     = 10 * time.Minute
+    DefaultCacheMaxSize = 1000
+)
+
+// CachePurger is implemented by classifiers that keep a result cache
+type CachePurger interface {
+    PurgeCache() int
+}
+
+type cacheEntry struct {
+    key       string
+    result    models.ClassificationResult
+    expiresAt time.Time
+}
+
+// CachingClassifier sits in front of a classifier and reuses results for
+// messages that normalize to the same text. Keys include the taxonomy version
+// and a prompt version, so a taxonomy reload or prompt change never serves stale intents.
+// Entries expire after ttl; beyond maxSize the least recently used entry is evicted.
+type CachingClassifier struct {
+    Classifier
+    taxonomy      *Taxonomy
+    promptVersion string
+    ttl           time.Duration
+    maxSize       int
+
+    mu          sync.Mutex
+    entries     map[string]*list.Element
+    lru         *list.List // Front is most recently used
+    hits        int64
+    misses      int64
+    evictions   int64
+    expirations int64
+    now         func() time.Time
+}
+
+func NewCachingClassifier(inner Classifier, taxonomy *Taxonomy, promptVersion string, ttl time.Duration, maxSize int) *CachingClassifier {
+    if maxSize <= 0 {
+        maxSize = DefaultCacheMaxSize
+    }
+
+    log.Printf("[CACHE] Caching classifications for %v, up to %d entries", ttl, maxSize)
+
+    return &CachingClassifier{
+        Classifier:    inner,
+        taxonomy:      taxonomy,
+        promptVersion: promptVersion,
+        ttl:           ttl,
+        maxSize:       maxSize,
+        entries:       make(map[string]*list.Element),
+        lru:           list.New(),
+        now:           time.Now,
+    }
+}
+
+func (cc *CachingClassifier) ClassifyQuery(ctx context.Context, customerMessage string) (*models.ClassificationResult, error) {
+    key := fmt.Sprintf("%d|%s|%s", cc.taxonomy.Version(), cc.promptVersion, NormalizeMessage(customerMessage))
+
+    if result, ok := cc.get(key); ok {
+        return result, nil
+    }
+
+    result, err := cc.Classifier.ClassifyQuery(ctx, customerMessage)
+    if err != nil {
+        return nil, err // Failures are never cached
+    }
+
+    // Answers from a fallback backend are not cached, the primary should get the next try
+    if result.Backend == cc.Name() {
+        cc.put(key, result)
+    }
+    return result, nil
+}
+
+func (cc *CachingClassifier) get(key string) (*models.ClassificationResult, bool) {
+    cc.mu.Lock()
+    defer cc.mu.Unlock()
+
+    element, ok := cc.entries[key]
+    if !ok {
+        cc.misses++
+        return nil, false
+    }
+
+    entry := element.Value.(*cacheEntry)
+    if cc.now().After(entry.expiresAt) {
+        cc.lru.Remove(element)
+        delete(cc.entries, key)
+        cc.expirations++
+        cc.misses++
+        return nil, false
+    }
+
+    cc.lru.MoveToFront(element)
+    cc.hits++
+    result := entry.result // Callers get their own copy
+    result.Usage = nil      // Hits cost nothing
+    return &result, true
+}
+
+func (cc *CachingClassifier) put(key string, result *models.ClassificationResult) {
+    cc.mu.Lock()
+    defer cc.mu.Unlock()
+
+    if element, ok := cc.entries[key]; ok {
+        entry := element.Value.(*cacheEntry)
+        entry.result = *result
+        entry.expiresAt = cc.now().Add(cc.ttl)
+        cc.lru.MoveToFront(element)
+        return
+    }
+
+    cc.entries[key] = cc.lru.PushFront(&cacheEntry{
+        key:       key,
+        result:    *result,
+        expiresAt: cc.now().Add(cc.ttl),
+    })
+
+    for cc.lru.Len() > cc.maxSize {
+        oldest := cc.lru.Back()
+        cc.lru.Remove(oldest)
+        delete(cc.entries, oldest.Value.(*cacheEntry).key)
+        cc.evictions++
+    }
+}
+
+// PurgeCache drops every cached result and returns how many were removed
+func (cc *CachingClassifier) PurgeCache() int {
+    cc.mu.Lock()
+    defer cc.mu.Unlock()
+
+    purged := cc.lru.Len()
+    cc.entries = make(map[string]*list.Element)
+    cc.lru.Init()
+
+    log.Printf("[CACHE] Purged %d entries", purged)
+    return purged
+}
+
+func (cc *CachingClassifier) GetStats() map[string]interface{} {
+    stats := cc.Classifier.GetStats()
+
+    cc.mu.Lock()
+    defer cc.mu.Unlock()
+
+    hitRate := 0.0
+    if lookups := cc.hits + cc.misses; lookups > 0 {
+        hitRate = float64(cc.hits) / float64(lookups)
+    }
+    stats["cache"] = map[string]interface{}{
+        "size":        cc.lru.Len(),
+        "max_size":    cc.maxSize,
+        "ttl":         cc.ttl.String(),
+        "hits":        cc.hits,
+        "misses":      cc.misses,
+        "hit_rate":    hitRate,
+        "evictions":   cc.evictions,
+        "expirations": cc.expirations,
+    }
+    return stats
+}
+
+var messagePunctuation = regexp.MustCompile(`[^\p{L}\p{N}\s]+`)
+
+// NormalizeMessage lowercases a message and strips punctuation and extra whitespace,
+// so retries that differ only in formatting share a cache entry
+func NormalizeMessage(message string) string {
+    normalized := messagePunctuation.ReplaceAllString(strings.ToLower(message), " ")
+    return strings.Join(strings.Fields(normalized), " ")
+}
\ No newline at end of file
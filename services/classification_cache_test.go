@@ -0,0 +1,118 @@
+package services
+
+import (
+    "context"
+    "testing"
+    "time"
+)
+
+func TestCachingClassifierReusesResultsUntilTheyExpire(t *testing.T) {
+    inner := &scriptedClassifier{name: "openai", intent: "billing_discrepancies", confidence: 0.9}
+    cache := NewCachingClassifier(inner, DefaultTaxonomy(), "v1", time.Minute, 10)
+    now := time.Now()
+    cache.now = func() time.Time { return now }
+
+    steps := []struct {
+        name    string
+        message string
+        advance time.Duration
+        calls   int
+        hit     bool
+    }{
+        {"first lookup", "I was charged twice!", 0, 1, false},
+        {"formatting differences share the entry", "  i was CHARGED twice ", 0, 1, true},
+        {"still fresh", "I was charged twice", 59 * time.Second, 1, true},
+        {"expired", "I was charged twice", 2 * time.Second, 2, false},
+        {"cached again", "I was charged twice", 0, 2, true},
+    }
+
+    for _, step := range steps {
+        now = now.Add(step.advance)
+        result, err := cache.ClassifyQuery(context.Background(), step.message)
+        if err != nil {
+            t.Fatalf("%s: %v", step.name, err)
+        }
+        if inner.calls != step.calls {
+            t.Errorf("%s: inner classifier called %d times, want %d", step.name, inner.calls, step.calls)
+        }
+        if step.hit != (result.Usage == nil) {
+            t.Errorf("%s: token usage %+v on a hit=%v", step.name, result.Usage, step.hit)
+        }
+    }
+
+    stats := cache.GetStats()["cache"].(map[string]interface{})
+    if stats["hits"] != int64(3) || stats["expirations"] != int64(1) {
+        t.Errorf("stats %+v, want 3 hits and 1 expiration", stats)
+    }
+}
+
+func TestCachingClassifierEvictsLeastRecentlyUsed(t *testing.T) {
+    inner := &scriptedClassifier{name: "openai", intent: "billing_discrepancies", confidence: 0.9}
+    cache := NewCachingClassifier(inner, DefaultTaxonomy(), "v1", time.Minute, 2)
+    ctx := context.Background()
+
+    cache.ClassifyQuery(ctx, "a")
+    cache.ClassifyQuery(ctx, "b")
+    cache.ClassifyQuery(ctx, "a") // b is now least recently used
+    cache.ClassifyQuery(ctx, "c")
+
+    calls := inner.calls
+    cache.ClassifyQuery(ctx, "a")
+    if inner.calls != calls {
+        t.Error("recently used entry was evicted")
+    }
+    cache.ClassifyQuery(ctx, "b")
+    if inner.calls != calls+1 {
+        t.Error("least recently used entry was kept")
+    }
+}
+
+func TestCachingClassifierSkipsFailuresAndFallbacks(t *testing.T) {
+    ctx := context.Background()
+
+    inner := failing("openai", "timeout")
+    cache := NewCachingClassifier(inner, DefaultTaxonomy(), "v1", time.Minute, 10)
+    cache.ClassifyQuery(ctx, "hello")
+    cache.ClassifyQuery(ctx, "hello")
+    if inner.calls != 2 {
+        t.Errorf("failure was cached, %d calls", inner.calls)
+    }
+
+    // Answered by the fallback backend, not the primary the cache wraps
+    fallback := &scriptedClassifier{name: "rules", intent: "general"}
+    cache = NewCachingClassifier(&renamedClassifier{fallback, "openai"}, DefaultTaxonomy(), "v1", time.Minute, 10)
+    cache.ClassifyQuery(ctx, "hello")
+    cache.ClassifyQuery(ctx, "hello")
+    if fallback.calls != 2 {
+        t.Errorf("fallback answer was cached, %d calls", fallback.calls)
+    }
+}
+
+func TestCachingClassifierKeysOnTaxonomyVersion(t *testing.T) {
+    inner := &scriptedClassifier{name: "openai", intent: "billing_discrepancies", confidence: 0.9}
+    taxonomy := DefaultTaxonomy()
+    cache := NewCachingClassifier(inner, taxonomy, "v1", time.Minute, 10)
+    ctx := context.Background()
+
+    cache.ClassifyQuery(ctx, "hello")
+    if err := taxonomy.set(defaultIntents()); err != nil {
+        t.Fatal(err)
+    }
+    cache.ClassifyQuery(ctx, "hello")
+    if inner.calls != 2 {
+        t.Error("served a result classified under the previous taxonomy")
+    }
+    if purged := cache.PurgeCache(); purged != 2 {
+        t.Errorf("purged %d entries, want 2", purged)
+    }
+}
+
+// renamedClassifier reports another name than the backend that answers, like a fallback chain
+type renamedClassifier struct {
+    Classifier
+    name string
+}
+
+func (rc *renamedClassifier) Name() string {
+    return rc.name
+}
@@ -1,85 +1,144 @@
 package services
 
 import (
+    "bytes"
     "context"
+    "encoding/json"
     "fmt"
+    "io"
     "log"
+    "math"
+    "net/http"
     "strings"
+    "sync"
     "time"
 
+    "customer-query-router/models"
     openai "github.com/sashabaranov/go-openai"
 )
 
+const (
+    DefaultOpenAIModel       = openai.GPT3Dot5Turbo
+    DefaultOpenAIMaxTokens   = 50
+    DefaultOpenAITemperature = 0.1
+
+    // Function call arguments need more room than a bare intent name
+    minStructuredMaxTokens = 150
+)
+
+// OpenAIConfig points the client at OpenAI or any OpenAI-compatible server
+// (llama.cpp, vLLM, Ollama). APIKey may be empty for keyless local endpoints.
+type OpenAIConfig struct {
+    APIKey       string
+    BaseURL      string // Empty means api.openai.com
+    Organization string
+    Model        string
+    MaxTokens    int
+    Temperature  float32
+
+    // USD per million tokens, for cost accounting. Zero uses the list price of Model.
+    PromptPrice     float64
+    CompletionPrice float64
+}
+
 type ClassificationService struct {
     client *openai.Client
-    intents []Intent
+    model string
+    maxTokens int
+    temperature float32
+    price ModelPrice
+    taxonomy *Taxonomy
+    multiLabel bool
+    structuredOutput bool
+    promptBuilder *PromptBuilder
+    retryPolicy RetryPolicy
+
+    // Requests are classified concurrently, mu guards the counters
+    mu sync.Mutex
     requestCount int64
     totalProcessingTime time.Duration
 }
 
-type Intent struct {
-    Name  string
-    Agent string
-}
+func NewClassificationService(config OpenAIConfig, taxonomy *Taxonomy) (*ClassificationService, error) {
+    intents := taxonomy.Intents()
 
-func NewClassificationService(apiKey string) *ClassificationService {
-    intents := []Intent{
-        {"account_access_issues", "account-support"},
-        {"billing_discrepancies", "billing-team"},
-        {"delivery_problems", "logistics-team"},
-        {"installation_support_requests", "technical-support"},
-        {"order_cancellation_requests", "order-management"},
-        {"order_status_uncertainty", "order-tracking"},
-        {"product_availability_inquiries", "inventory-team"},
-        {"refund_processing_issues", "finance-team"},
-        {"return_process_inquiries", "returns-team"},
-        {"warranty_terms_inquiries", "warranty-team"},
-        {"general", "general-agent"}, // Fallback
+    clientConfig := openai.DefaultConfig(config.APIKey)
+    if config.BaseURL != "" {
+        clientConfig.BaseURL = strings.TrimRight(config.BaseURL, "/")
+    }
+    clientConfig.OrgID = config.Organization
+    clientConfig.HTTPClient = &http.Client{Transport: zeroTemperatureTransport{base: http.DefaultTransport}}
+    if config.Model == "" {
+        config.Model = DefaultOpenAIModel
+    }
+    if config.MaxTokens <= 0 {
+        config.MaxTokens = DefaultOpenAIMaxTokens
     }
 
-    log.Printf("[CLASSIFICATION SERVICE] Initialized with %d intent categories", len(intents)-1)
+    price := priceForConfig(config)
+
+    categories := 0
+    for _, intent := range intents {
+        if intent.Name != "general" {
+            categories++
+        }
+    }
+    log.Printf("[CLASSIFICATION SERVICE] Initialized with %d intent categories", categories)
+    log.Printf("[CLASSIFICATION SERVICE] Using model %s at %s ($%.2f/$%.2f per million prompt/completion tokens)",
+        config.Model, clientConfig.BaseURL, price.Prompt, price.Completion)
     
-    // Create the service instance first
     service := &ClassificationService{
-        client:  openai.NewClient(apiKey),
-        intents: intents,
-        requestCount: 0,
-        totalProcessingTime: 0,
+        client:  openai.NewClientWithConfig(clientConfig),
+        model: config.Model,
+        maxTokens: config.MaxTokens,
+        temperature: config.Temperature,
+        price: price,
+        taxonomy: taxonomy,
+        structuredOutput: true,
+        retryPolicy: DefaultRetryPolicy(),
     }
     
-    log.Printf("[CLASSIFICATION SERVICE] Available intents: %s", service.getIntentNames(intents))
+    // Default prompt: intent descriptions only, no few-shot examples
+    builder, err := NewPromptBuilder("", nil, 0, DefaultPromptTokenBudget)
+    if err != nil {
+        return nil, err
+    }
+    service.promptBuilder = builder
     
-    return service
+    log.Printf("[CLASSIFICATION SERVICE] Available intents: %s", service.getIntentNames(intents))
     
-    return &ClassificationService{
-        client:  openai.NewClient(apiKey),
-        intents: intents,
-        requestCount: 0,
-        totalProcessingTime: 0,
-    }
+    return service, nil
 }
 
-func (cs *ClassificationService) ClassifyQuery(customerMessage string) (string, string, error) {
+func (cs *ClassificationService) ClassifyQuery(ctx context.Context, customerMessage string) (*models.ClassificationResult, error) {
     startTime := time.Now()
+    cs.mu.Lock()
     cs.requestCount++
     requestID := cs.requestCount
+    cs.mu.Unlock()
     
     log.Printf("[REQUEST %d] Starting classification process", requestID)
     log.Printf("[REQUEST %d] STEP 1 - Message received: \"%s\"", requestID, truncateMessage(customerMessage, 100))
     log.Printf("[REQUEST %d] Message length: %d characters", requestID, len(customerMessage))
     
+    // Read the taxonomy once so a concurrent reload cannot change it mid-request
+    intents := cs.taxonomy.Intents()
+    
     // Build the classification prompt
-    prompt := cs.buildClassificationPrompt()
+    prompt, err := cs.buildClassificationPrompt(intents, customerMessage)
+    if err != nil {
+        return nil, err
+    }
     log.Printf("[REQUEST %d] STEP 2 - Built classification prompt (%d characters)", requestID, len(prompt))
     
     // Log the actual prompt being sent (truncated for readability)
     log.Printf("[REQUEST %d] Prompt preview: \"%s\"", requestID, truncateMessage(prompt, 150))
     
-    log.Printf("[REQUEST %d] STEP 3 - Sending request to OpenAI GPT-3.5-turbo", requestID)
+    log.Printf("[REQUEST %d] STEP 3 - Sending request to %s", requestID, cs.model)
     
     // Create the OpenAI request
     openaiRequest := openai.ChatCompletionRequest{
-        Model: openai.GPT3Dot5Turbo,
+        Model: cs.model,
         Messages: []openai.ChatCompletionMessage{
             {
                 Role:    openai.ChatMessageRoleSystem,
@@ -90,111 +149,278 @@ func (cs *ClassificationService) ClassifyQuery(customerMessage string) (string,
                 Content: customerMessage,
             },
         },
-        MaxTokens:   50,
-        Temperature: 0.1,
+        MaxTokens:   cs.maxTokens,
+        Temperature: cs.temperature, // Zero is added back by zeroTemperatureTransport
+        LogProbs:    true,
+        TopLogProbs: 5,
+    }
+    
+    // Structured mode forces a classify_query function call whose intent is an enum
+    if cs.structuredOutput {
+        if openaiRequest.MaxTokens < minStructuredMaxTokens {
+            openaiRequest.MaxTokens = minStructuredMaxTokens
+        }
+        openaiRequest.Tools = []openai.Tool{classifyTool(intents, cs.multiLabel)}
+        openaiRequest.ToolChoice = openai.ToolChoice{
+            Type:     openai.ToolTypeFunction,
+            Function: openai.ToolFunction{Name: classifyFunctionName},
+        }
     }
     
-    log.Printf("[REQUEST %d] OpenAI request configured - Model: %s, MaxTokens: %d, Temperature: %.1f", 
+    log.Printf("[REQUEST %d] OpenAI request configured - Model: %s, MaxTokens: %d, Temperature: %g", 
         requestID, openaiRequest.Model, openaiRequest.MaxTokens, openaiRequest.Temperature)
     
-    // Make the API call
+    // Make the API call, bounded per attempt and retried on transient failures
     apiStartTime := time.Now()
-    resp, err := cs.client.CreateChatCompletion(context.Background(), openaiRequest)
+    var resp openai.ChatCompletionResponse
+    err = cs.retryPolicy.Do(ctx, func(callCtx context.Context) error {
+        var callErr error
+        resp, callErr = cs.client.CreateChatCompletion(callCtx, openaiRequest)
+        return callErr
+    })
     apiDuration := time.Since(apiStartTime)
     
     if err != nil {
         log.Printf("[REQUEST %d] ERROR - OpenAI API call failed: %v", requestID, err)
         log.Printf("[REQUEST %d] API call duration: %v", requestID, apiDuration)
-        return "", "", fmt.Errorf("OpenAI API error: %w", err)
+        return nil, fmt.Errorf("OpenAI API error: %w", err)
+    }
+    
+    if len(resp.Choices) == 0 {
+        log.Printf("[REQUEST %d] ERROR - OpenAI returned no choices", requestID)
+        return nil, fmt.Errorf("OpenAI API error: empty response")
     }
     
     log.Printf("[REQUEST %d] STEP 4 - Received response from OpenAI in %v", requestID, apiDuration)
-    log.Printf("[REQUEST %d] Response tokens used: %d", requestID, resp.Usage.TotalTokens)
+    usage := &models.TokenUsage{
+        Model:            cs.model,
+        PromptTokens:     resp.Usage.PromptTokens,
+        CompletionTokens: resp.Usage.CompletionTokens,
+        TotalTokens:      resp.Usage.TotalTokens,
+        CostUSD:          cs.price.Cost(resp.Usage.PromptTokens, resp.Usage.CompletionTokens),
+    }
+    log.Printf("[REQUEST %d] Tokens used: %d prompt + %d completion, estimated cost $%.6f",
+        requestID, usage.PromptTokens, usage.CompletionTokens, usage.CostUSD)
     
     // Extract and process the classification result
-    rawIntent := resp.Choices[0].Message.Content
-    intent := strings.TrimSpace(rawIntent)
+    message := resp.Choices[0].Message
+    answer := parseClassificationAnswer(message, cs.multiLabel)
+    rawIntent := answer.Intent
+    intent, isValidIntent := normalizeIntent(intents, rawIntent)
     
-    log.Printf("[REQUEST %d] Raw OpenAI response: \"%s\"", requestID, rawIntent)
-    log.Printf("[REQUEST %d] STEP 5 - Processed intent: \"%s\"", requestID, intent)
+    log.Printf("[REQUEST %d] Raw OpenAI response: \"%s\" (%d tool calls)", requestID, message.Content, len(message.ToolCalls))
+    log.Printf("[REQUEST %d] STEP 5 - Processed intent: \"%s\" -> \"%s\"", requestID, rawIntent, intent)
+    
+    // Prefer the confidence the model reported, otherwise score the answer from the token logprobs
+    logProbConfidence, alternatives := cs.scoreFromLogProbs(resp.Choices[0].LogProbs, intent, intents)
+    confidence := logProbConfidence
+    if answer.Confidence >= 0 {
+        confidence = math.Min(answer.Confidence, 1)
+    }
+    log.Printf("[REQUEST %d] Confidence: %.3f, %d alternatives", requestID, confidence, len(alternatives))
     
     // Validate the intent
-    isValidIntent := cs.isValidIntent(intent)
     if !isValidIntent {
-        log.Printf("[REQUEST %d] WARNING - Unrecognized intent \"%s\", falling back to \"general\"", requestID, intent)
+        log.Printf("[REQUEST %d] WARNING - Unrecognized intent \"%s\", falling back to \"general\"", requestID, rawIntent)
         intent = "general"
+        confidence = 0.0
+    }
+    
+    var intentScores []models.IntentScore
+    if cs.multiLabel {
+        intentScores = append(intentScores, models.IntentScore{Intent: intent, Score: confidence})
+        previous := confidence
+        for _, label := range answer.AdditionalIntents {
+            extra, ok := normalizeIntent(intents, label.Intent)
+            if !ok || extra == "general" || extra == intent {
+                continue
+            }
+            // A secondary intent never outscores the primary
+            score := math.Min(label.Confidence, confidence)
+            if label.Confidence < 0 {
+                score = previous * secondaryIntentDiscount
+            }
+            previous = score
+            intentScores = append(intentScores, models.IntentScore{Intent: extra, Score: score})
+        }
     }
     
     // Get the assigned agent
-    agent := cs.getAgentForIntent(intent)
+    agent := agentForIntent(intents, intent)
     log.Printf("[REQUEST %d] STEP 6 - Agent assignment: \"%s\" -> \"%s\"", requestID, intent, agent)
     
     // Calculate final metrics
     totalDuration := time.Since(startTime)
+    cs.mu.Lock()
     cs.totalProcessingTime += totalDuration
-    avgProcessingTime := cs.totalProcessingTime / time.Duration(cs.requestCount)
+    requestCount := cs.requestCount
+    avgProcessingTime := cs.totalProcessingTime / time.Duration(requestCount)
+    cs.mu.Unlock()
     
     log.Printf("[REQUEST %d] STEP 7 - Classification complete", requestID)
     log.Printf("[REQUEST %d] METRICS - Total time: %v, API time: %v, Processing time: %v", 
         requestID, totalDuration, apiDuration, totalDuration-apiDuration)
     log.Printf("[REQUEST %d] METRICS - Request #%d, Average processing time: %v", 
-        requestID, cs.requestCount, avgProcessingTime)
+        requestID, requestCount, avgProcessingTime)
     
     log.Printf("[REQUEST %d] FINAL RESULT - Intent: \"%s\", Agent: \"%s\"", requestID, intent, agent)
     log.Printf("================================================================================")
     
-    return intent, agent, nil
+    return &models.ClassificationResult{
+        Intent:       intent,
+        Agent:        agent,
+        Confidence:   confidence,
+        Backend:      cs.Name(),
+        Alternatives: alternatives,
+        Intents:      intentScores,
+        Rationale:    answer.Rationale,
+        Usage:        usage,
+    }, nil
+}
+
+// zeroTemperatureTransport sends "temperature": 0 on chat completions that have none.
+// go-openai drops a zero temperature (omitempty), and the server would then use its default of 1.
+type zeroTemperatureTransport struct {
+    base http.RoundTripper
 }
 
-func (cs *ClassificationService) buildClassificationPrompt() string {
-    intentList := make([]string, len(cs.intents)-1) // Exclude "general" from the list
-    for i, intent := range cs.intents[:len(cs.intents)-1] {
-        intentList[i] = intent.Name
+func (t zeroTemperatureTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+    if req.Method != http.MethodPost || req.Body == nil || !strings.HasSuffix(req.URL.Path, "/chat/completions") {
+        return t.base.RoundTrip(req)
     }
-    
-    return fmt.Sprintf(`You are a customer service query classifier. 
-Classify the following customer message into exactly ONE of these specific intents: %s
 
-If the message doesn't clearly fit into any of these specific categories, respond with "general".
+    body, err := io.ReadAll(req.Body)
+    req.Body.Close()
+    if err != nil {
+        return nil, err
+    }
+    var fields map[string]json.RawMessage
+    if err := json.Unmarshal(body, &fields); err == nil {
+        if _, ok := fields["temperature"]; !ok {
+            fields["temperature"] = json.RawMessage("0")
+            if patched, err := json.Marshal(fields); err == nil {
+                body = patched
+            }
+        }
+    }
 
-Respond with only the intent name, nothing else.`, strings.Join(intentList, ", "))
+    patched := req.Clone(req.Context())
+    patched.Body = io.NopCloser(bytes.NewReader(body))
+    patched.ContentLength = int64(len(body))
+    patched.GetBody = func() (io.ReadCloser, error) {
+        return io.NopCloser(bytes.NewReader(body)), nil
+    }
+    return t.base.RoundTrip(patched)
 }
 
-func (cs *ClassificationService) getAgentForIntent(intent string) string {
-    for _, i := range cs.intents {
-        if i.Name == intent {
-            return i.Agent
+// scoreFromLogProbs derives a confidence from the token logprobs of the answer.
+// The confidence is the joint probability of all answer tokens. Alternatives come
+// from the other candidates for the first token, matched to intents by prefix.
+func (cs *ClassificationService) scoreFromLogProbs(logProbs *openai.LogProbs, intent string, intents []Intent) (float64, []models.IntentScore) {
+    if logProbs == nil || len(logProbs.Content) == 0 {
+        // Backend does not support logprobs, a recognized intent counts as certain
+        return 1.0, nil
+    }
+    
+    sum := 0.0
+    for _, token := range logProbs.Content {
+        sum += token.LogProb
+    }
+    confidence := math.Exp(sum)
+    
+    scores := make(map[string]float64)
+    for _, candidate := range logProbs.Content[0].TopLogProbs {
+        prefix := strings.ToLower(strings.TrimSpace(candidate.Token))
+        if prefix == "" || strings.HasPrefix(intent, prefix) {
+            continue // Same path as the chosen answer
+        }
+        
+        matches := []string{}
+        for _, i := range intents {
+            if strings.HasPrefix(i.Name, prefix) {
+                matches = append(matches, i.Name)
+            }
+        }
+        for _, match := range matches {
+            scores[match] += math.Exp(candidate.LogProb) / float64(len(matches))
         }
     }
-    return "general-agent" // Default fallback
+    
+    return confidence, rankIntents(intents, scores)
 }
 
-func (cs *ClassificationService) isValidIntent(intent string) bool {
-    for _, i := range cs.intents {
-        if i.Name == intent {
-            return true
+// SetStructuredOutput switches between function calling and plain-text answers.
+// Disable it for OpenAI-compatible servers without tool support.
+func (cs *ClassificationService) SetStructuredOutput(enabled bool) {
+    cs.structuredOutput = enabled
+}
+
+// SetMultiLabel asks the model for every applicable intent instead of exactly one
+func (cs *ClassificationService) SetMultiLabel(enabled bool) {
+    cs.multiLabel = enabled
+}
+
+// SetRetryPolicy sets the per-call timeout and retry behaviour for OpenAI requests
+func (cs *ClassificationService) SetRetryPolicy(policy RetryPolicy) {
+    cs.retryPolicy = policy
+}
+
+// SetPromptBuilder replaces the default prompt (intent descriptions, no examples)
+func (cs *ClassificationService) SetPromptBuilder(builder *PromptBuilder) {
+    cs.promptBuilder = builder
+}
+
+func (cs *ClassificationService) buildClassificationPrompt(intents []Intent, customerMessage string) (string, error) {
+    data := PromptData{
+        Task:         "Classify the following customer message into exactly ONE of these specific intents:",
+        Instructions: "Respond with only the intent name, nothing else.",
+    }
+    
+    if cs.multiLabel {
+        data.Task = "Classify the following customer message into ALL of these specific intents that apply:"
+    }
+    switch {
+    case cs.structuredOutput:
+        data.Fallback = `use "general"`
+        data.Instructions = "Call the classify_query function with the intent, your confidence from 0 to 1 and a one-sentence rationale."
+        if cs.multiLabel {
+            data.Instructions += "\nA message may raise several separate issues. Put the most important one in intent and list the others in additional_intents, each with its own confidence."
         }
+    case cs.multiLabel:
+        data.Instructions = "A message may raise several separate issues. List every matching intent, most important first, separated by commas.\nRespond with only the intent names, nothing else."
     }
-    return false
+    // Untranslated Spanish or French messages reach the model too
+    data.Task += "\nThe message may be in any language. Classify it by meaning and always answer with the intent names below, in English."
+    
+    return cs.promptBuilder.Build(intents, customerMessage, data)
 }
 
 func (cs *ClassificationService) GetAllIntents() []Intent {
-    return cs.intents
+    return cs.taxonomy.Intents()
 }
 
 func (cs *ClassificationService) GetStats() map[string]interface{} {
+    cs.mu.Lock()
+    defer cs.mu.Unlock()
+
     avgProcessingTime := time.Duration(0)
     if cs.requestCount > 0 {
         avgProcessingTime = cs.totalProcessingTime / time.Duration(cs.requestCount)
     }
     
     return map[string]interface{}{
+        "backend": cs.Name(),
+        "model": cs.model,
         "total_requests": cs.requestCount,
         "total_processing_time": cs.totalProcessingTime.String(),
         "average_processing_time": avgProcessingTime.String(),
     }
 }
 
+func (cs *ClassificationService) Name() string {
+    return "openai"
+}
+
 // Helper function to get all intent names for logging
 func (cs *ClassificationService) getIntentNames(intents []Intent) string {
     names := make([]string, len(intents))
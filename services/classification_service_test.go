@@ -0,0 +1,92 @@
+package services
+
+import (
+    "context"
+    "encoding/json"
+    "io"
+    "math"
+    "net/http"
+    "net/http/httptest"
+    "testing"
+
+    openai "github.com/sashabaranov/go-openai"
+)
+
+// fakeOpenAI answers every chat completion with content and 1000 prompt and 200 completion
+// tokens, and records the request bodies
+func fakeOpenAI(t *testing.T, content string) (*httptest.Server, *[]map[string]interface{}) {
+    requests := &[]map[string]interface{}{}
+    server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        body, _ := io.ReadAll(r.Body)
+        var fields map[string]interface{}
+        if err := json.Unmarshal(body, &fields); err != nil {
+            t.Errorf("request body %s: %v", body, err)
+        }
+        *requests = append(*requests, fields)
+
+        w.Header().Set("Content-Type", "application/json")
+        json.NewEncoder(w).Encode(openai.ChatCompletionResponse{
+            Choices: []openai.ChatCompletionChoice{{Message: openai.ChatCompletionMessage{Role: openai.ChatMessageRoleAssistant, Content: content}}},
+            Usage:   openai.Usage{PromptTokens: 1000, CompletionTokens: 200, TotalTokens: 1200},
+        })
+    }))
+    t.Cleanup(server.Close)
+    return server, requests
+}
+
+func TestZeroTemperatureIsSent(t *testing.T) {
+    server, requests := fakeOpenAI(t, "general")
+    config := openai.DefaultConfig("test-key")
+    config.BaseURL = server.URL
+    config.HTTPClient = &http.Client{Transport: zeroTemperatureTransport{base: http.DefaultTransport}}
+    client := openai.NewClientWithConfig(config)
+
+    for _, temperature := range []float32{0, 0.5} {
+        if _, err := client.CreateChatCompletion(context.Background(), openai.ChatCompletionRequest{
+            Model:       openai.GPT3Dot5Turbo,
+            Messages:    []openai.ChatCompletionMessage{{Role: openai.ChatMessageRoleUser, Content: "hi"}},
+            Temperature: temperature,
+        }); err != nil {
+            t.Fatal(err)
+        }
+    }
+
+    if len(*requests) != 2 || (*requests)[0]["temperature"] != 0.0 || (*requests)[1]["temperature"] != 0.5 {
+        t.Errorf("requests %v, want temperatures 0 and 0.5", *requests)
+    }
+}
+
+func TestClassificationReportsTokenUsage(t *testing.T) {
+    server, _ := fakeOpenAI(t, "billing_discrepancies")
+
+    tests := []struct {
+        name   string
+        config OpenAIConfig
+        cost   float64
+    }{
+        {"list price", OpenAIConfig{Model: "gpt-4o-mini-2024-07-18"}, (1000*0.15 + 200*0.60) / 1e6},
+        {"configured price", OpenAIConfig{Model: "gpt-4o-mini", PromptPrice: 1, CompletionPrice: 2}, (1000*1 + 200*2) / 1e6},
+        {"local model", OpenAIConfig{Model: "llama3"}, 0},
+    }
+
+    for _, tt := range tests {
+        tt.config.BaseURL = server.URL
+        service, err := NewClassificationService(tt.config, DefaultTaxonomy())
+        if err != nil {
+            t.Fatal(err)
+        }
+
+        result, err := service.ClassifyQuery(context.Background(), "I was charged twice")
+        if err != nil {
+            t.Fatalf("%s: %v", tt.name, err)
+        }
+        usage := result.Usage
+        if usage == nil || usage.Model != tt.config.Model || usage.PromptTokens != 1000 || usage.CompletionTokens != 200 || usage.TotalTokens != 1200 {
+            t.Errorf("%s: usage %+v, want 1000 + 200 tokens of %s", tt.name, usage, tt.config.Model)
+            continue
+        }
+        if math.Abs(usage.CostUSD-tt.cost) > 1e-12 {
+            t.Errorf("%s: cost $%g, want $%g", tt.name, usage.CostUSD, tt.cost)
+        }
+    }
+}
@@ -0,0 +1,413 @@
+package services
+
+import (
+    "context"
+    "fmt"
+    "hash/crc32"
+    "log"
+    "strings"
+    "sync/atomic"
+    "time"
+
+    "customer-query-router/models"
+)
+
+// Classifier is implemented by every classification backend.
+// Handlers only depend on this interface so backends can be swapped at startup.
+type Classifier interface {
+    ClassifyQuery(ctx context.Context, customerMessage string) (*models.ClassificationResult, error)
+    GetAllIntents() []Intent
+    GetStats() map[string]interface{}
+    Name() string
+}
+
+// ClassifierConfig selects and configures the classification backend
+type ClassifierConfig struct {
+    Taxonomy     *Taxonomy
+    Backend      string
+    StaticIntent string
+    RulesFile    string
+
+    // OpenAI backend, or any OpenAI-compatible server at OpenAI.BaseURL. StructuredOutput
+    // uses function calling with an enum of intents, disable it for servers without tool support
+    OpenAI           OpenAIConfig
+    StructuredOutput bool
+    RetryPolicy      RetryPolicy
+
+    // The OpenAI backend sits behind a circuit breaker that opens after BreakerThreshold
+    // consecutive failures and fails fast for BreakerCooldown
+    BreakerThreshold int
+    BreakerCooldown  time.Duration
+
+    // Token usage of the OpenAI backend is recorded in Usage, which also enforces the
+    // daily budgets; over budget the fallback chain answers instead. Nil disables accounting.
+    Usage *UsageTracker
+
+    // When the backend fails or answers below FallbackMinConfidence, the FallbackChain
+    // backends are tried in order ("static" answers with StaticIntent, i.e. "general")
+    FallbackChain         []string
+    FallbackMinConfidence float64
+
+    // Prompt: optional text/template file, plus few-shot examples picked from
+    // FewShotExamplesFile by similarity to the message, within PromptTokenBudget
+    PromptTemplateFile  string
+    FewShotExamplesFile string
+    MaxFewShotExamples  int
+    PromptTokenBudget   int
+
+    // Naive Bayes backend: the model is trained from BayesLabelsFile when BayesModelFile is missing
+    BayesModelFile  string
+    BayesLabelsFile string
+
+    // Results below ConfidenceThreshold are routed to LowConfidenceRoute ("general" or "human_triage")
+    ConfidenceThreshold float64
+    LowConfidenceRoute  string
+    MaxAlternatives     int
+
+    // Multi-label mode reports every intent scoring at least MultiLabelThreshold
+    MultiLabel          bool
+    MultiLabelThreshold float64
+
+    // Ensemble backend: EnsembleBackends are asked concurrently and combined with
+    // EnsembleStrategy ("vote" or "confidence"). Disagreements are flagged for review,
+    // and EnsembleStrictIntents are only trusted when every member agrees.
+    EnsembleBackends      []EnsembleBackend
+    EnsembleStrategy      string
+    EnsembleStrictIntents []string
+
+    // Personal data is replaced with tokens by Redactor before any backend or the cache
+    // sees or logs the message. Nil disables redaction.
+    Redactor *Redactor
+
+    // Language tags every result with the language of the message. With a Translator,
+    // messages in other languages are classified in English; callers keep the original.
+    // Both sit inside the redaction, so the translator never sees personal data.
+    Language   *LanguageDetector
+    Translator Translator
+
+    // Entities are extracted from the original, unredacted message. Nil disables extraction.
+    Entities *EntityExtractor
+
+    // Sentiment and urgency are scored on the original message and combined with the
+    // intent's base priority into a priority from 1 to 5. Nil disables scoring.
+    Sentiment *SentimentAnalyzer
+
+    // Results are cached for CacheTTL (0 disables the cache), keeping at most CacheMaxSize entries
+    CacheTTL     time.Duration
+    CacheMaxSize int
+}
+
+// NewClassifier builds the backend named in the config and applies the confidence policy
+func NewClassifier(config ClassifierConfig) (Classifier, error) {
+    if config.Taxonomy == nil {
+        config.Taxonomy = DefaultTaxonomy()
+    }
+
+    // Every OpenAI call, translations included, goes through one breaker and the budgets
+    openaiBreaker := newCircuitBreaker("openai", config.BreakerThreshold, config.BreakerCooldown)
+    if translator, ok := config.Translator.(*OpenAITranslator); ok {
+        translator.guard(openaiBreaker, config.Usage)
+    }
+
+    backend, err := newGuardedBackend(config, openaiBreaker)
+    if err != nil {
+        return nil, err
+    }
+    if len(config.FallbackChain) > 0 {
+        backend, err = newFallbackChain(backend, config, openaiBreaker)
+        if err != nil {
+            return nil, err
+        }
+    }
+    if config.MultiLabel {
+        backend = NewMultiIntentClassifier(backend, config.MultiLabelThreshold)
+    }
+
+    var classifier Classifier = NewConfidenceFilter(backend, config.ConfidenceThreshold, config.LowConfidenceRoute, config.MaxAlternatives)
+    if config.Language != nil {
+        classifier = NewLanguageClassifier(classifier, config.Language, config.Translator)
+    }
+    var cache *CachingClassifier
+    if config.CacheTTL > 0 {
+        version, err := promptVersion(config)
+        if err != nil {
+            return nil, err
+        }
+        cache = NewCachingClassifier(classifier, config.Taxonomy, version, config.CacheTTL, config.CacheMaxSize)
+        classifier = cache
+    }
+
+    // Everything that depends on the exact message stays outside the cache: the cache key
+    // ignores case and punctuation, which sentiment and urgency depend on, and entities and
+    // restored personal data belong to the customer who sent the message, not the first one
+    // who sent something similar. The cache only ever sees redacted text.
+    if config.Redactor != nil {
+        classifier = NewRedactingClassifier(classifier, config.Redactor)
+    }
+    if config.Entities != nil {
+        classifier = NewEntityExtractingClassifier(classifier, config.Entities)
+    }
+    if config.Sentiment != nil {
+        classifier = NewPriorityClassifier(classifier, config.Sentiment)
+    }
+    if cache != nil {
+        classifier = &purgeableClassifier{Classifier: classifier, cache: cache}
+    }
+    return classifier, nil
+}
+
+// purgeableClassifier lets callers purge the cache beneath the per-message decorators
+type purgeableClassifier struct {
+    Classifier
+    cache *CachingClassifier
+}
+
+func (pc *purgeableClassifier) PurgeCache() int {
+    return pc.cache.PurgeCache()
+}
+
+// promptVersion fingerprints every setting that changes what a message classifies to
+func promptVersion(config ClassifierConfig) (string, error) {
+    templateText := DefaultPromptTemplate
+    if config.PromptTemplateFile != "" {
+        loaded, err := LoadPromptTemplate(config.PromptTemplateFile)
+        if err != nil {
+            return "", err
+        }
+        templateText = loaded
+    }
+
+    settings := fmt.Sprintf("%v|%s|%v|%s|%s|%s|%g|%s|%s|%s|%d|%d|%t|%t|%g|%s|%d|%g|%t", config.EnsembleBackends,
+        config.EnsembleStrategy, config.EnsembleStrictIntents, config.OpenAI.BaseURL,
+        config.OpenAI.Model, config.Backend, config.OpenAI.Temperature, config.StaticIntent,
+        config.RulesFile, config.FewShotExamplesFile, config.MaxFewShotExamples, config.PromptTokenBudget,
+        config.StructuredOutput, config.MultiLabel, config.MultiLabelThreshold, config.LowConfidenceRoute,
+        config.MaxAlternatives, config.ConfidenceThreshold, config.Translator != nil)
+    return fmt.Sprintf("%08x", crc32.ChecksumIEEE([]byte(settings+templateText))), nil
+}
+
+// newGuardedBackend puts remote backends behind the provider's circuit breaker and meters their
+// usage. Budget rejections happen outside the breaker, they say nothing about the provider's health.
+func newGuardedBackend(config ClassifierConfig, openaiBreaker *circuitBreaker) (Classifier, error) {
+    backend, err := newBackend(config, openaiBreaker)
+    if err != nil || backend.Name() != "openai" {
+        return backend, err
+    }
+    backend = newBreakerClassifier(backend, openaiBreaker)
+    if config.Usage != nil {
+        backend = NewMeteredClassifier(backend, config.Usage)
+    }
+    return backend, nil
+}
+
+// newFallbackChain appends the configured fallbacks to primary, skipping repeated backends and "none"
+func newFallbackChain(primary Classifier, config ClassifierConfig, openaiBreaker *circuitBreaker) (Classifier, error) {
+    steps := []Classifier{primary}
+    seen := map[string]bool{primary.Name(): true}
+    for _, name := range config.FallbackChain {
+        if strings.EqualFold(name, "none") {
+            continue
+        }
+        stepConfig := config
+        stepConfig.Backend = name
+        step, err := newGuardedBackend(stepConfig, openaiBreaker)
+        if err != nil {
+            return nil, fmt.Errorf("fallback %s: %w", name, err)
+        }
+        if seen[step.Name()] {
+            log.Printf("[FALLBACK] %s is already in the chain, skipping it", step.Name())
+            continue
+        }
+        seen[step.Name()] = true
+        steps = append(steps, step)
+    }
+    if len(steps) == 1 {
+        return primary, nil
+    }
+    return NewFallbackChainClassifier(steps, config.FallbackMinConfidence), nil
+}
+
+func newBackend(config ClassifierConfig, openaiBreaker *circuitBreaker) (Classifier, error) {
+    backend := strings.ToLower(strings.TrimSpace(config.Backend))
+    if backend == "" {
+        backend = "openai"
+    }
+
+    log.Printf("[CLASSIFIER] Creating %q backend", backend)
+
+    switch backend {
+    case "openai":
+        // Local OpenAI-compatible servers usually need no key, api.openai.com always does
+        if config.OpenAI.APIKey == "" && config.OpenAI.BaseURL == "" {
+            return nil, fmt.Errorf("OPENAI_API_KEY is required for the openai backend unless OPENAI_BASE_URL is set")
+        }
+        service, err := NewClassificationService(config.OpenAI, config.Taxonomy)
+        if err != nil {
+            return nil, err
+        }
+        service.SetMultiLabel(config.MultiLabel)
+        service.SetStructuredOutput(config.StructuredOutput)
+        service.SetRetryPolicy(config.RetryPolicy)
+        builder, err := newPromptBuilder(config)
+        if err != nil {
+            return nil, err
+        }
+        service.SetPromptBuilder(builder)
+        return service, nil
+    case "static":
+        return NewStaticClassifier(config.StaticIntent, config.Taxonomy), nil
+    case "rules":
+        rules := DefaultRules()
+        if config.RulesFile != "" {
+            loaded, err := LoadRules(config.RulesFile)
+            if err != nil {
+                return nil, err
+            }
+            rules = loaded
+        }
+        classifier, err := NewRuleClassifier(rules, config.Taxonomy)
+        if err != nil {
+            return nil, err
+        }
+        warnUncoveredIntents(classifier, config.Taxonomy)
+        return classifier, nil
+    case "bayes":
+        model, err := LoadOrTrainBayesModel(config.BayesModelFile, config.BayesLabelsFile)
+        if err != nil {
+            return nil, err
+        }
+        classifier, err := NewBayesClassifier(model, config.Taxonomy)
+        if err != nil {
+            return nil, err
+        }
+        warnUncoveredIntents(classifier, config.Taxonomy)
+        return classifier, nil
+    case "ensemble":
+        members := []Classifier{}
+        weights := []float64{}
+        for _, member := range config.EnsembleBackends {
+            if strings.EqualFold(member.Name, "ensemble") {
+                return nil, fmt.Errorf("an ensemble cannot contain itself")
+            }
+            memberConfig := config
+            memberConfig.Backend = member.Name
+            classifier, err := newGuardedBackend(memberConfig, openaiBreaker)
+            if err != nil {
+                return nil, fmt.Errorf("ensemble member %s: %w", member.Name, err)
+            }
+            members = append(members, classifier)
+            weights = append(weights, member.Weight)
+        }
+        return NewEnsembleClassifier(members, weights, config.Taxonomy, config.EnsembleStrategy, config.EnsembleStrictIntents)
+    default:
+        return nil, fmt.Errorf("unknown classifier backend: %s", config.Backend)
+    }
+}
+
+// offlineBackend is a backend that only predicts the intents it has rules or training data for
+type offlineBackend interface {
+    Classifier
+    UncoveredIntents(intents []Intent) []string
+}
+
+// warnUncoveredIntents logs the enabled intents the backend cannot predict, now and after every
+// taxonomy reload, since intents added to the taxonomy get no rules or training data
+func warnUncoveredIntents(backend offlineBackend, taxonomy *Taxonomy) {
+    warn := func(intents []Intent) {
+        if uncovered := backend.UncoveredIntents(intents); len(uncovered) > 0 {
+            log.Printf("[CLASSIFIER] WARNING - The %s backend cannot predict %v", backend.Name(), uncovered)
+        }
+    }
+    warn(taxonomy.Intents())
+    taxonomy.OnReload(warn)
+}
+
+func newPromptBuilder(config ClassifierConfig) (*PromptBuilder, error) {
+    templateText := ""
+    if config.PromptTemplateFile != "" {
+        loaded, err := LoadPromptTemplate(config.PromptTemplateFile)
+        if err != nil {
+            return nil, err
+        }
+        templateText = loaded
+    }
+
+    var examples []LabeledExample
+    if config.FewShotExamplesFile != "" && config.MaxFewShotExamples > 0 {
+        loaded, err := LoadLabeledExamples(config.FewShotExamplesFile)
+        if err != nil {
+            return nil, err
+        }
+        examples = loaded
+    }
+
+    log.Printf("[CLASSIFIER] Prompt with up to %d of %d few-shot examples, %d token budget",
+        config.MaxFewShotExamples, len(examples), config.PromptTokenBudget)
+    return NewPromptBuilder(templateText, examples, config.MaxFewShotExamples, config.PromptTokenBudget)
+}
+
+func agentForIntent(intents []Intent, intent string) string {
+    for _, i := range intents {
+        if i.Name == intent {
+            return i.Agent
+        }
+    }
+    return "general-agent" // Default fallback
+}
+
+func isKnownIntent(intents []Intent, intent string) bool {
+    for _, i := range intents {
+        if i.Name == intent {
+            return true
+        }
+    }
+    return false
+}
+
+// StaticClassifier always answers with the same intent.
+// Useful for CI and air-gapped environments where no model is reachable.
+type StaticClassifier struct {
+    taxonomy     *Taxonomy
+    intent       string
+    requestCount int64
+}
+
+func NewStaticClassifier(intent string, taxonomy *Taxonomy) *StaticClassifier {
+    return &StaticClassifier{
+        taxonomy: taxonomy,
+        intent:   intent,
+    }
+}
+
+func (sc *StaticClassifier) ClassifyQuery(ctx context.Context, customerMessage string) (*models.ClassificationResult, error) {
+    atomic.AddInt64(&sc.requestCount, 1)
+
+    // The configured intent may be unknown or disabled by a taxonomy reload
+    intents := sc.taxonomy.Intents()
+    intent := sc.intent
+    if !isKnownIntent(intents, intent) {
+        intent = "general"
+    }
+
+    return &models.ClassificationResult{
+        Intent:     intent,
+        Agent:      agentForIntent(intents, intent),
+        Confidence: 1.0,
+        Backend:    sc.Name(),
+    }, nil
+}
+
+func (sc *StaticClassifier) GetAllIntents() []Intent {
+    return sc.taxonomy.Intents()
+}
+
+func (sc *StaticClassifier) GetStats() map[string]interface{} {
+    return map[string]interface{}{
+        "backend":        sc.Name(),
+        "total_requests": atomic.LoadInt64(&sc.requestCount),
+    }
+}
+
+func (sc *StaticClassifier) Name() string {
+    return "static"
+}
\ No newline at end of file
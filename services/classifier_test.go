@@ -0,0 +1,83 @@
+package services
+
+import (
+    "context"
+    "fmt"
+    "testing"
+    "time"
+
+    "customer-query-router/models"
+)
+
+// scriptedClassifier answers every message with the same intent and confidence, or fails
+type scriptedClassifier struct {
+    name       string
+    intent     string
+    confidence float64
+    err        error
+    calls      int
+}
+
+func (sc *scriptedClassifier) ClassifyQuery(ctx context.Context, customerMessage string) (*models.ClassificationResult, error) {
+    sc.calls++
+    if sc.err != nil {
+        return nil, sc.err
+    }
+    return &models.ClassificationResult{
+        Intent:     sc.intent,
+        Agent:      agentForIntent(DefaultTaxonomy().Intents(), sc.intent),
+        Confidence: sc.confidence,
+        Backend:    sc.name,
+        Usage:      &models.TokenUsage{TotalTokens: 10},
+    }, nil
+}
+
+func (sc *scriptedClassifier) GetAllIntents() []Intent {
+    return DefaultTaxonomy().Intents()
+}
+
+func (sc *scriptedClassifier) GetStats() map[string]interface{} {
+    return map[string]interface{}{"backend": sc.name, "total_requests": sc.calls}
+}
+
+func (sc *scriptedClassifier) Name() string {
+    return sc.name
+}
+
+// failing returns a classifier whose every call fails with message
+func failing(name, message string) *scriptedClassifier {
+    return &scriptedClassifier{name: name, err: fmt.Errorf("%s", message)}
+}
+
+func TestCachedResultsAreScoredPerMessage(t *testing.T) {
+    classifier, err := NewClassifier(ClassifierConfig{
+        Backend:      "static",
+        StaticIntent: "order_status_uncertainty",
+        Sentiment:    NewSentimentAnalyzer(),
+        CacheTTL:     time.Minute,
+    })
+    if err != nil {
+        t.Fatal(err)
+    }
+
+    calm, err := classifier.ClassifyQuery(context.Background(), "my order is late, what's the status")
+    if err != nil {
+        t.Fatal(err)
+    }
+    // Normalizes to the same cache key, but shouts
+    loud, err := classifier.ClassifyQuery(context.Background(), "MY ORDER IS LATE!!! WHAT'S THE STATUS")
+    if err != nil {
+        t.Fatal(err)
+    }
+
+    _, _, urgency := NewSentimentAnalyzer().Analyze("MY ORDER IS LATE!!! WHAT'S THE STATUS")
+    if loud.Urgency != urgency || loud.Urgency <= calm.Urgency {
+        t.Errorf("urgency %.2f for the loud message, want %.2f above %.2f", loud.Urgency, urgency, calm.Urgency)
+    }
+    if stats := classifier.GetStats()["cache"].(map[string]interface{}); stats["hits"] != int64(1) {
+        t.Errorf("cache stats %v, want the second message served from the cache", stats)
+    }
+    if _, ok := classifier.(CachePurger); !ok {
+        t.Error("the cache cannot be purged")
+    }
+}
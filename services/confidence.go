@@ -0,0 +1,99 @@
+package services
+
+import (
+    "context"
+    "log"
+    "sort"
+    "sync/atomic"
+
+    "customer-query-router/models"
+)
+
+const (
+    // HumanTriageAgent receives low-confidence queries when LowConfidenceRoute is "human_triage"
+    HumanTriageAgent = "human-triage"
+
+    DefaultMaxAlternatives = 3
+)
+
+// ConfidenceFilter wraps a backend and enforces the confidence threshold.
+// Results below the threshold are re-routed to "general" or to human triage,
+// and the list of alternatives is trimmed to the configured size.
+type ConfidenceFilter struct {
+    Classifier
+    threshold          float64
+    lowConfidenceRoute string
+    maxAlternatives    int
+    lowConfidenceCount int64
+}
+
+func NewConfidenceFilter(inner Classifier, threshold float64, lowConfidenceRoute string, maxAlternatives int) *ConfidenceFilter {
+    if lowConfidenceRoute != "human_triage" {
+        lowConfidenceRoute = "general"
+    }
+    if maxAlternatives < 0 {
+        maxAlternatives = 0
+    }
+
+    log.Printf("[CONFIDENCE FILTER] Threshold %.2f, low-confidence route %q, %d alternatives",
+        threshold, lowConfidenceRoute, maxAlternatives)
+
+    return &ConfidenceFilter{
+        Classifier:         inner,
+        threshold:          threshold,
+        lowConfidenceRoute: lowConfidenceRoute,
+        maxAlternatives:    maxAlternatives,
+    }
+}
+
+func (cf *ConfidenceFilter) ClassifyQuery(ctx context.Context, customerMessage string) (*models.ClassificationResult, error) {
+    result, err := cf.Classifier.ClassifyQuery(ctx, customerMessage)
+    if err != nil {
+        return nil, err
+    }
+
+    if len(result.Alternatives) > cf.maxAlternatives {
+        result.Alternatives = result.Alternatives[:cf.maxAlternatives]
+    }
+
+    if result.Confidence < cf.threshold && result.Intent != "general" {
+        atomic.AddInt64(&cf.lowConfidenceCount, 1)
+        log.Printf("[CONFIDENCE FILTER] %q scored %.2f (< %.2f), routing to %s",
+            result.Intent, result.Confidence, cf.threshold, cf.lowConfidenceRoute)
+
+        result.LowConfidence = true
+        result.OriginalIntent = result.Intent
+        result.Intents = nil
+        result.Intent = "general"
+        result.Agent = agentForIntent(cf.GetAllIntents(), "general")
+        if cf.lowConfidenceRoute == "human_triage" {
+            result.Agent = HumanTriageAgent
+        }
+    }
+
+    return result, nil
+}
+
+func (cf *ConfidenceFilter) GetStats() map[string]interface{} {
+    stats := cf.Classifier.GetStats()
+    stats["confidence_threshold"] = cf.threshold
+    stats["low_confidence_route"] = cf.lowConfidenceRoute
+    stats["low_confidence_count"] = atomic.LoadInt64(&cf.lowConfidenceCount)
+    return stats
+}
+
+// rankIntents orders scored intents from best to worst. Ties keep catalogue order.
+// Intents without a positive score are left out.
+func rankIntents(intents []Intent, scores map[string]float64) []models.IntentScore {
+    ranked := []models.IntentScore{}
+    for _, intent := range intents {
+        if score := scores[intent.Name]; score > 0 {
+            ranked = append(ranked, models.IntentScore{Intent: intent.Name, Score: score})
+        }
+    }
+
+    sort.SliceStable(ranked, func(i, j int) bool {
+        return ranked[i].Score > ranked[j].Score
+    })
+    return ranked
+}
\ No newline at end of file
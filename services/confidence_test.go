@@ -0,0 +1,106 @@
+package services
+
+import (
+    "context"
+    "testing"
+
+    "customer-query-router/models"
+)
+
+// resultClassifier answers every message with a copy of result
+type resultClassifier struct {
+    scriptedClassifier
+    result models.ClassificationResult
+}
+
+func (rc *resultClassifier) ClassifyQuery(ctx context.Context, customerMessage string) (*models.ClassificationResult, error) {
+    rc.calls++
+    result := rc.result
+    result.Alternatives = append([]models.IntentScore{}, rc.result.Alternatives...)
+    result.Intents = append([]models.IntentScore{}, rc.result.Intents...)
+    return &result, nil
+}
+
+func TestConfidenceFilterRoutesLowConfidence(t *testing.T) {
+    alternatives := []models.IntentScore{
+        {Intent: "refund_processing_issues", Score: 0.2},
+        {Intent: "delivery_problems", Score: 0.1},
+        {Intent: "order_status_uncertainty", Score: 0.05},
+    }
+    generalAgent := agentForIntent(DefaultTaxonomy().Intents(), "general")
+    billingAgent := agentForIntent(DefaultTaxonomy().Intents(), "billing_discrepancies")
+
+    tests := []struct {
+        name       string
+        intent     string
+        confidence float64
+        route      string
+        wantIntent string
+        wantAgent  string
+        low        bool
+    }{
+        {"confident", "billing_discrepancies", 0.9, "general", "billing_discrepancies", billingAgent, false},
+        {"at the threshold", "billing_discrepancies", 0.6, "general", "billing_discrepancies", billingAgent, false},
+        {"below the threshold", "billing_discrepancies", 0.59, "general", "general", generalAgent, true},
+        {"below the threshold to triage", "billing_discrepancies", 0.3, "human_triage", "general", HumanTriageAgent, true},
+        {"unknown route falls back to general", "billing_discrepancies", 0.3, "nowhere", "general", generalAgent, true},
+        {"general is never low confidence", "general", 0.1, "human_triage", "general", generalAgent, false},
+    }
+
+    for _, tt := range tests {
+        inner := &resultClassifier{result: models.ClassificationResult{
+            Intent:       tt.intent,
+            Agent:        agentForIntent(DefaultTaxonomy().Intents(), tt.intent),
+            Confidence:   tt.confidence,
+            Alternatives: alternatives,
+            Intents:      []models.IntentScore{{Intent: tt.intent, Score: tt.confidence}},
+        }}
+        filter := NewConfidenceFilter(inner, 0.6, tt.route, 2)
+
+        result, err := filter.ClassifyQuery(context.Background(), "hello")
+        if err != nil {
+            t.Fatalf("%s: %v", tt.name, err)
+        }
+        if result.Intent != tt.wantIntent || result.Agent != tt.wantAgent || result.LowConfidence != tt.low {
+            t.Errorf("%s: got %s for %s (low %v), want %s for %s (low %v)", tt.name,
+                result.Intent, result.Agent, result.LowConfidence, tt.wantIntent, tt.wantAgent, tt.low)
+        }
+        if tt.low && (result.OriginalIntent != tt.intent || result.Intents != nil) {
+            t.Errorf("%s: original intent %q and intents %+v kept", tt.name, result.OriginalIntent, result.Intents)
+        }
+        if len(result.Alternatives) != 2 || result.Alternatives[0].Intent != "refund_processing_issues" {
+            t.Errorf("%s: alternatives %+v, want the best two", tt.name, result.Alternatives)
+        }
+    }
+}
+
+func TestRankIntentsOrdersByScore(t *testing.T) {
+    intents := DefaultTaxonomy().Intents()
+    ranked := rankIntents(intents, map[string]float64{
+        "delivery_problems":        0.3,
+        "billing_discrepancies":    0.3,
+        "refund_processing_issues": 0.5,
+        "account_access_issues":    0,
+    })
+
+    var got []string
+    for _, score := range ranked {
+        got = append(got, score.Intent)
+    }
+    // Ties keep catalogue order, unscored intents are left out
+    want := []string{"refund_processing_issues"}
+    for _, intent := range intents {
+        if intent.Name == "billing_discrepancies" || intent.Name == "delivery_problems" {
+            want = append(want, intent.Name)
+        }
+    }
+    if len(got) != len(want) {
+        t.Fatalf("ranked %v, want %v", got, want)
+    }
+    for i := range want {
+        if got[i] != want[i] {
+            t.Errorf("ranked %v, want %v", got, want)
+            break
+        }
+    }
+}
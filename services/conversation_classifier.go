@@ -0,0 +1,173 @@
+package services
+
+import (
+    "context"
+    "fmt"
+    "regexp"
+    "strings"
+
+    "customer-query-router/models"
+)
+
+const (
+    SpeakerCustomer = "customer"
+    SpeakerAgent    = "agent"
+
+    ShiftAdditional = "additional"
+    ShiftSwitch     = "switch"
+
+    // DefaultShiftConfidence is how sure a turn must be to count as a new intent without a cue phrase
+    DefaultShiftConfidence = 0.5
+
+    // Turns with fewer words that carry meaning ("Okay, thanks") are not classified
+    minTurnTokens = 2
+)
+
+// Phrases customers use to raise another issue or to replace the one they started with.
+// Additional cues win, "actually I also want to cancel" keeps the first issue open.
+var (
+    additionalShiftCue = regexp.MustCompile(`(?i)\b(also|as well|another (thing|issue|question|problem)|one more thing|in addition|additionally|besides|while i have you)\b`)
+    switchShiftCue     = regexp.MustCompile(`(?i)\b(actually|instead|never ?mind|forget (about )?(it|that|the)|rather|changed my mind|scratch that)\b`)
+)
+
+// ConversationClassifier classifies every customer turn of a conversation and tracks
+// how the intent moves between turns, so a conversation that changes topic can be re-routed
+type ConversationClassifier struct {
+    classifier         Classifier
+    minShiftConfidence float64
+}
+
+func NewConversationClassifier(classifier Classifier, minShiftConfidence float64) *ConversationClassifier {
+    if minShiftConfidence <= 0 {
+        minShiftConfidence = DefaultShiftConfidence
+    }
+    return &ConversationClassifier{
+        classifier:         classifier,
+        minShiftConfidence: minShiftConfidence,
+    }
+}
+
+// ClassifyConversation returns the overall intent, the intent of every customer turn
+// and the shifts between them. Agent turns are kept for their index but not classified.
+func (cc *ConversationClassifier) ClassifyConversation(ctx context.Context, turns []models.ConversationTurn) (*models.ConversationClassification, error) {
+    conversation := &models.ConversationClassification{
+        Turns:       []models.TurnIntent{},
+        OpenIntents: []string{},
+    }
+    support := make(map[string]float64)  // Summed confidence per intent
+    strongest := make(map[string]float64) // Highest confidence per intent
+    raised := []string{}                  // Intents in the order they first appeared
+
+    for i, turn := range turns {
+        if !strings.EqualFold(turn.Speaker, SpeakerCustomer) {
+            continue
+        }
+        if len(Tokenize(turn.Text)) < minTurnTokens {
+            conversation.Turns = append(conversation.Turns, models.TurnIntent{Turn: i, Intent: "general", Skipped: true})
+            continue
+        }
+
+        result, err := cc.classifier.ClassifyQuery(ctx, turn.Text)
+        if err != nil {
+            return nil, fmt.Errorf("turn %d: %w", i, err)
+        }
+        conversation.Turns = append(conversation.Turns, models.TurnIntent{Turn: i, Intent: result.Intent, Confidence: result.Confidence})
+        if result.Priority > conversation.Priority {
+            conversation.Priority = result.Priority
+        }
+        if result.Language != "" {
+            conversation.Language = result.Language
+        }
+
+        // A multi-label result raises its secondary intents in the same turn
+        candidates := []models.IntentScore{{Intent: result.Intent, Score: result.Confidence}}
+        for _, extra := range result.Intents {
+            if extra.Intent != result.Intent {
+                candidates = append(candidates, extra)
+            }
+        }
+
+        for _, candidate := range candidates {
+            if candidate.Intent == "general" {
+                continue // Small talk and unclear turns continue the current intent
+            }
+            if support[candidate.Intent] == 0 {
+                raised = append(raised, candidate.Intent)
+            }
+            support[candidate.Intent] += candidate.Score
+            if candidate.Score > strongest[candidate.Intent] {
+                strongest[candidate.Intent] = candidate.Score
+            }
+
+            cc.applyTurn(conversation, i, turn.Text, candidate)
+        }
+    }
+
+    if len(raised) == 0 {
+        conversation.Intent = "general"
+        conversation.CurrentIntent = "general"
+        conversation.OpenIntents = []string{"general"}
+        return conversation, nil
+    }
+
+    // Ties go to the intent raised first
+    for _, intent := range raised {
+        if support[intent] > support[conversation.Intent] {
+            conversation.Intent = intent
+        }
+    }
+    conversation.Confidence = strongest[conversation.Intent]
+    return conversation, nil
+}
+
+// applyTurn moves the conversation to the candidate intent of turn i when it is a credible new intent
+func (cc *ConversationClassifier) applyTurn(conversation *models.ConversationClassification, i int, text string, candidate models.IntentScore) {
+    if conversation.CurrentIntent == "" {
+        conversation.CurrentIntent = candidate.Intent
+        conversation.OpenIntents = append(conversation.OpenIntents, candidate.Intent)
+        return
+    }
+    if candidate.Intent == conversation.CurrentIntent {
+        return
+    }
+    for _, open := range conversation.OpenIntents {
+        if open == candidate.Intent {
+            conversation.CurrentIntent = candidate.Intent // Back to an issue that is still open
+            return
+        }
+    }
+
+    kind, cue := ShiftAdditional, ""
+    if match := additionalShiftCue.FindString(text); match != "" {
+        cue = match
+    } else if match := switchShiftCue.FindString(text); match != "" {
+        kind, cue = ShiftSwitch, match
+    }
+    if cue == "" && candidate.Score < cc.minShiftConfidence {
+        return
+    }
+
+    conversation.Shifts = append(conversation.Shifts, models.IntentShift{
+        Turn:       i,
+        From:       conversation.CurrentIntent,
+        To:         candidate.Intent,
+        Kind:       kind,
+        Confidence: candidate.Score,
+        Cue:        cue,
+    })
+    if kind == ShiftSwitch {
+        conversation.OpenIntents = removeIntent(conversation.OpenIntents, conversation.CurrentIntent)
+    }
+    conversation.OpenIntents = append(conversation.OpenIntents, candidate.Intent)
+    conversation.CurrentIntent = candidate.Intent
+}
+
+func removeIntent(intents []string, intent string) []string {
+    kept := []string{}
+    for _, i := range intents {
+        if i != intent {
+            kept = append(kept, i)
+        }
+    }
+    return kept
+}
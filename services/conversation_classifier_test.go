@@ -0,0 +1,124 @@
+package services
+
+import (
+    "context"
+    "strings"
+    "testing"
+
+    "customer-query-router/models"
+)
+
+// turnClassifier answers each turn with the result scripted for its text, "general" otherwise
+type turnClassifier struct {
+    scriptedClassifier
+    results map[string]models.IntentScore
+}
+
+func (tc *turnClassifier) ClassifyQuery(ctx context.Context, customerMessage string) (*models.ClassificationResult, error) {
+    tc.calls++
+    scored, ok := tc.results[customerMessage]
+    if !ok {
+        scored = models.IntentScore{Intent: "general", Score: 0.9}
+    }
+    return &models.ClassificationResult{Intent: scored.Intent, Confidence: scored.Score, Backend: tc.name}, nil
+}
+
+func TestConversationIntentShifts(t *testing.T) {
+    classifier := &turnClassifier{
+        scriptedClassifier: scriptedClassifier{name: "turns"},
+        results: map[string]models.IntentScore{
+            "My parcel never arrived": {Intent: "delivery_problems", Score: 0.9},
+            "I also want to cancel my other order": {Intent: "order_cancellation_requests", Score: 0.3},
+            "Actually I also want to cancel my order": {Intent: "order_cancellation_requests", Score: 0.3},
+            "Actually, just refund my money instead": {Intent: "refund_processing_issues", Score: 0.3},
+            "Maybe I should cancel the order": {Intent: "order_cancellation_requests", Score: 0.3},
+            "Please cancel the whole order": {Intent: "order_cancellation_requests", Score: 0.8},
+            "So where is the parcel now": {Intent: "delivery_problems", Score: 0.7},
+        },
+    }
+    conversations := NewConversationClassifier(classifier, 0.5)
+
+    tests := []struct {
+        name    string
+        turns   []string // Customer turns, each answered by the agent
+        shifts  []string // "from>to kind cue"
+        current string
+        open    []string
+    }{
+        {"single intent", []string{"My parcel never arrived"}, nil, "delivery_problems", []string{"delivery_problems"}},
+        {"additional cue", []string{"My parcel never arrived", "I also want to cancel my other order"},
+            []string{"delivery_problems>order_cancellation_requests additional also"},
+            "order_cancellation_requests", []string{"delivery_problems", "order_cancellation_requests"}},
+        {"additional cue wins over switch cue", []string{"My parcel never arrived", "Actually I also want to cancel my order"},
+            []string{"delivery_problems>order_cancellation_requests additional also"},
+            "order_cancellation_requests", []string{"delivery_problems", "order_cancellation_requests"}},
+        {"switch cue closes the earlier intent", []string{"My parcel never arrived", "Actually, just refund my money instead"},
+            []string{"delivery_problems>refund_processing_issues switch Actually"},
+            "refund_processing_issues", []string{"refund_processing_issues"}},
+        {"weak turn without a cue is ignored", []string{"My parcel never arrived", "Maybe I should cancel the order"},
+            nil, "delivery_problems", []string{"delivery_problems"}},
+        {"confident turn without a cue", []string{"My parcel never arrived", "Please cancel the whole order"},
+            []string{"delivery_problems>order_cancellation_requests additional "},
+            "order_cancellation_requests", []string{"delivery_problems", "order_cancellation_requests"}},
+        {"return to an open intent", []string{"My parcel never arrived", "I also want to cancel my other order", "So where is the parcel now"},
+            []string{"delivery_problems>order_cancellation_requests additional also"},
+            "delivery_problems", []string{"delivery_problems", "order_cancellation_requests"}},
+        {"small talk continues the intent", []string{"My parcel never arrived", "Okay", "How is your day going"},
+            nil, "delivery_problems", []string{"delivery_problems"}},
+    }
+
+    for _, tt := range tests {
+        turns := []models.ConversationTurn{}
+        for _, text := range tt.turns {
+            turns = append(turns,
+                models.ConversationTurn{Speaker: SpeakerCustomer, Text: text},
+                models.ConversationTurn{Speaker: SpeakerAgent, Text: "I also want to help, actually"})
+        }
+
+        conversation, err := conversations.ClassifyConversation(context.Background(), turns)
+        if err != nil {
+            t.Fatalf("%s: %v", tt.name, err)
+        }
+
+        shifts := []string{}
+        for _, shift := range conversation.Shifts {
+            if shift.Turn%2 != 0 {
+                t.Errorf("%s: shift at agent turn %d", tt.name, shift.Turn)
+            }
+            shifts = append(shifts, shift.From+">"+shift.To+" "+shift.Kind+" "+shift.Cue)
+        }
+        if strings.Join(shifts, "; ") != strings.Join(tt.shifts, "; ") {
+            t.Errorf("%s: shifts %q, want %q", tt.name, shifts, tt.shifts)
+        }
+        if conversation.CurrentIntent != tt.current {
+            t.Errorf("%s: current intent %q, want %q", tt.name, conversation.CurrentIntent, tt.current)
+        }
+        if strings.Join(conversation.OpenIntents, ",") != strings.Join(tt.open, ",") {
+            t.Errorf("%s: open intents %v, want %v", tt.name, conversation.OpenIntents, tt.open)
+        }
+        if conversation.Intent != "delivery_problems" {
+            t.Errorf("%s: overall intent %q, want the best supported delivery_problems", tt.name, conversation.Intent)
+        }
+    }
+}
+
+func TestConversationSkipsShortAndAgentTurns(t *testing.T) {
+    classifier := &turnClassifier{scriptedClassifier: scriptedClassifier{name: "turns"}}
+    conversation, err := NewConversationClassifier(classifier, 0).ClassifyConversation(context.Background(), []models.ConversationTurn{
+        {Speaker: SpeakerAgent, Text: "How can I help you today?"},
+        {Speaker: "Customer", Text: "Thanks"},
+    })
+    if err != nil {
+        t.Fatal(err)
+    }
+
+    if classifier.calls != 0 {
+        t.Errorf("classified %d turns, want none", classifier.calls)
+    }
+    if len(conversation.Turns) != 1 || !conversation.Turns[0].Skipped || conversation.Turns[0].Turn != 1 {
+        t.Errorf("turns %+v, want only the customer turn, skipped", conversation.Turns)
+    }
+    if conversation.Intent != "general" || conversation.CurrentIntent != "general" {
+        t.Errorf("intent %q, current %q, want general", conversation.Intent, conversation.CurrentIntent)
+    }
+}
@@ -5,6 +5,8 @@ import (
     "fmt"
     "os"
     "strings"
+
+    "customer-query-router/models"
 )
 
 type ConversationService struct {
@@ -70,4 +72,41 @@ func (cs *ConversationService) GetFirstCustomerMessage(conversation string) stri
     }
     
     return "No customer message found"
-}
\ No newline at end of file
+}
+
+// GetCustomerMessages returns every customer line of a conversation in order
+func (cs *ConversationService) GetCustomerMessages(conversation string) []string {
+    lines := strings.Split(conversation, "\n")
+    messages := []string{}
+    
+    for _, line := range lines {
+        line = strings.TrimSpace(line)
+        if strings.HasPrefix(line, "Customer: ") {
+            messages = append(messages, strings.TrimPrefix(line, "Customer: "))
+        }
+    }
+    
+    return messages
+}
+
+// ParseTurns splits a transcript into turns. Lines start with "Customer:" or "Agent:",
+// other non-empty lines continue the previous turn.
+func (cs *ConversationService) ParseTurns(conversation string) []models.ConversationTurn {
+    turns := []models.ConversationTurn{}
+    
+    for _, line := range strings.Split(conversation, "\n") {
+        line = strings.Trim(strings.TrimSpace(line), `"`)
+        switch {
+        case line == "":
+            continue
+        case strings.HasPrefix(line, "Customer:"):
+            turns = append(turns, models.ConversationTurn{Speaker: SpeakerCustomer, Text: strings.TrimSpace(strings.TrimPrefix(line, "Customer:"))})
+        case strings.HasPrefix(line, "Agent:"):
+            turns = append(turns, models.ConversationTurn{Speaker: SpeakerAgent, Text: strings.TrimSpace(strings.TrimPrefix(line, "Agent:"))})
+        case len(turns) > 0:
+            turns[len(turns)-1].Text += " " + line
+        }
+    }
+    
+    return turns
+}
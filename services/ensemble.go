@@ -0,0 +1,260 @@
+package services
+
+import (
+    "context"
+    "fmt"
+    "log"
+    "sort"
+    "strconv"
+    "strings"
+    "sync"
+    "time"
+
+    "customer-query-router/models"
+)
+
+const (
+    // EnsembleVote gives every member one weighted vote for its top intent
+    EnsembleVote = "vote"
+    // EnsembleConfidence adds up every member's scores, weighted, across all intents it ranked
+    EnsembleConfidence = "confidence"
+
+    maxRecentDisagreements = 50
+)
+
+// DefaultStrictIntents are costly to get wrong, so the ensemble only trusts them when every member agrees
+var DefaultStrictIntents = []string{"refund_processing_issues", "order_cancellation_requests"}
+
+// EnsembleBackend names a backend taking part in the ensemble and the weight of its vote
+type EnsembleBackend struct {
+    Name   string
+    Weight float64
+}
+
+// ParseEnsembleBackends reads a list like "openai:2,bayes,rules:0.5". The weight defaults to 1.
+func ParseEnsembleBackends(spec string) ([]EnsembleBackend, error) {
+    backends := []EnsembleBackend{}
+    for _, item := range strings.Split(spec, ",") {
+        item = strings.TrimSpace(item)
+        if item == "" {
+            continue
+        }
+
+        backend := EnsembleBackend{Name: item, Weight: 1}
+        if name, weight, ok := strings.Cut(item, ":"); ok {
+            parsed, err := strconv.ParseFloat(strings.TrimSpace(weight), 64)
+            if err != nil || parsed <= 0 {
+                return nil, fmt.Errorf("invalid ensemble weight %q for %s", weight, name)
+            }
+            backend = EnsembleBackend{Name: strings.TrimSpace(name), Weight: parsed}
+        }
+        backends = append(backends, backend)
+    }
+    return backends, nil
+}
+
+type ensembleMember struct {
+    classifier Classifier
+    weight     float64
+}
+
+// EnsembleDisagreement records a message the members classified differently
+type EnsembleDisagreement struct {
+    Time    time.Time            `json:"time"`
+    Message string               `json:"message"`
+    Intent  string               `json:"intent"`
+    Votes   []models.BackendVote `json:"votes"`
+}
+
+// EnsembleClassifier asks several backends concurrently and combines their answers.
+// When members disagree the result is flagged for human review; a strict intent
+// that is not unanimous is not trusted at all and goes to human triage instead.
+// Members that fail are left out, the ensemble only fails when all of them do.
+type EnsembleClassifier struct {
+    members       []ensembleMember
+    taxonomy      *Taxonomy
+    strategy      string
+    strictIntents map[string]bool
+
+    mu                  sync.Mutex
+    requestCount        int64
+    disagreementCount   int64
+    strictOverrideCount int64
+    recent              []EnsembleDisagreement // Oldest first
+}
+
+func NewEnsembleClassifier(members []Classifier, weights []float64, taxonomy *Taxonomy, strategy string, strictIntents []string) (*EnsembleClassifier, error) {
+    if len(members) < 2 {
+        return nil, fmt.Errorf("ensemble needs at least two backends, got %d", len(members))
+    }
+    if strategy == "" {
+        strategy = EnsembleVote
+    }
+    if strategy != EnsembleVote && strategy != EnsembleConfidence {
+        return nil, fmt.Errorf("unknown ensemble strategy: %s", strategy)
+    }
+
+    ec := &EnsembleClassifier{
+        taxonomy:      taxonomy,
+        strategy:      strategy,
+        strictIntents: make(map[string]bool),
+    }
+    names := make([]string, len(members))
+    for i, member := range members {
+        ec.members = append(ec.members, ensembleMember{classifier: member, weight: weights[i]})
+        names[i] = fmt.Sprintf("%s:%g", member.Name(), weights[i])
+    }
+    for _, intent := range strictIntents {
+        ec.strictIntents[intent] = true
+    }
+
+    log.Printf("[ENSEMBLE] %s strategy over %s, strict intents: %s",
+        strategy, strings.Join(names, ", "), strings.Join(strictIntents, ", "))
+    return ec, nil
+}
+
+func (ec *EnsembleClassifier) ClassifyQuery(ctx context.Context, customerMessage string) (*models.ClassificationResult, error) {
+    results := make([]*models.ClassificationResult, len(ec.members))
+    errs := make([]error, len(ec.members))
+
+    var wg sync.WaitGroup
+    for i, member := range ec.members {
+        wg.Add(1)
+        go func(i int, member ensembleMember) {
+            defer wg.Done()
+            results[i], errs[i] = member.classifier.ClassifyQuery(ctx, customerMessage)
+        }(i, member)
+    }
+    wg.Wait()
+
+    intents := ec.taxonomy.Intents()
+    votes := make([]models.BackendVote, len(ec.members))
+    scores := make(map[string]float64)
+    tieBreak := make(map[string]float64) // Summed confidence separates intents with equal votes
+    totalWeight := 0.0
+    answered := map[string]bool{}
+    var lastErr error
+
+    for i, member := range ec.members {
+        votes[i] = models.BackendVote{Backend: member.classifier.Name(), Weight: member.weight}
+        if errs[i] != nil {
+            votes[i].Error = errs[i].Error()
+            lastErr = errs[i]
+            log.Printf("[ENSEMBLE] %s failed, leaving it out: %v", votes[i].Backend, errs[i])
+            continue
+        }
+
+        result := results[i]
+        votes[i].Backend = result.Backend // A breaker fallback may have answered
+        votes[i].Intent = result.Intent
+        votes[i].Confidence = result.Confidence
+        totalWeight += member.weight
+        answered[result.Intent] = true
+        tieBreak[result.Intent] += member.weight * result.Confidence
+
+        switch ec.strategy {
+        case EnsembleConfidence:
+            scores[result.Intent] += member.weight * result.Confidence
+            for _, alternative := range result.Alternatives {
+                scores[alternative.Intent] += member.weight * alternative.Score
+            }
+        default:
+            scores[result.Intent] += member.weight
+        }
+    }
+
+    if totalWeight == 0 {
+        return nil, fmt.Errorf("%w: every ensemble member failed: %v", ErrClassifierUnavailable, lastErr)
+    }
+
+    ranked := rankIntents(intents, scores)
+    sort.SliceStable(ranked, func(i, j int) bool {
+        if ranked[i].Score != ranked[j].Score {
+            return ranked[i].Score > ranked[j].Score
+        }
+        return tieBreak[ranked[i].Intent] > tieBreak[ranked[j].Intent]
+    })
+    intent := "general"
+    confidence := 0.0
+    if len(ranked) > 0 {
+        intent = ranked[0].Intent
+        confidence = ranked[0].Score / totalWeight
+        ranked = ranked[1:]
+    }
+    for i := range ranked {
+        ranked[i].Score /= totalWeight
+    }
+
+    result := &models.ClassificationResult{
+        Intent:       intent,
+        Agent:        agentForIntent(intents, intent),
+        Confidence:   confidence,
+        Backend:      ec.Name(),
+        Alternatives: ranked,
+        Votes:        votes,
+        NeedsReview:  len(answered) > 1,
+    }
+
+    ec.mu.Lock()
+    defer ec.mu.Unlock()
+    ec.requestCount++
+    if !result.NeedsReview {
+        return result, nil
+    }
+
+    ec.disagreementCount++
+    log.Printf("[ENSEMBLE] Members disagree on \"%s\", picked %s (%.2f)", truncateMessage(customerMessage, 60), intent, confidence)
+    if ec.strictIntents[intent] {
+        ec.strictOverrideCount++
+        log.Printf("[ENSEMBLE] %s requires agreement, sending to human triage", intent)
+        result.OriginalIntent = intent
+        result.Intent = "general"
+        result.Agent = HumanTriageAgent
+    }
+
+    ec.recent = append(ec.recent, EnsembleDisagreement{
+        Time:    time.Now(),
+        Message: truncateMessage(customerMessage, 200),
+        Intent:  intent,
+        Votes:   votes,
+    })
+    if len(ec.recent) > maxRecentDisagreements {
+        ec.recent = ec.recent[len(ec.recent)-maxRecentDisagreements:]
+    }
+    return result, nil
+}
+
+func (ec *EnsembleClassifier) GetAllIntents() []Intent {
+    return ec.taxonomy.Intents()
+}
+
+func (ec *EnsembleClassifier) GetStats() map[string]interface{} {
+    members := map[string]interface{}{}
+    for _, member := range ec.members {
+        members[member.classifier.Name()] = member.classifier.GetStats()
+    }
+
+    ec.mu.Lock()
+    defer ec.mu.Unlock()
+
+    disagreementRate := 0.0
+    if ec.requestCount > 0 {
+        disagreementRate = float64(ec.disagreementCount) / float64(ec.requestCount)
+    }
+    return map[string]interface{}{
+        "backend":        ec.Name(),
+        "total_requests": ec.requestCount,
+        "ensemble": map[string]interface{}{
+            "strategy":              ec.strategy,
+            "members":               members,
+            "disagreements":         ec.disagreementCount,
+            "disagreement_rate":     disagreementRate,
+            "strict_overrides":      ec.strictOverrideCount,
+            "recent_disagreements":  append([]EnsembleDisagreement{}, ec.recent...),
+        },
+    }
+}
+
+func (ec *EnsembleClassifier) Name() string {
+    return "ensemble"
+}
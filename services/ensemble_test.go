@@ -0,0 +1,157 @@
+package services
+
+import (
+    "context"
+    "errors"
+    "math"
+    "testing"
+)
+
+func TestEnsembleCombinesMembers(t *testing.T) {
+    tests := []struct {
+        name        string
+        strategy    string
+        members     []Classifier
+        weights     []float64
+        intent      string
+        confidence  float64
+        needsReview bool
+    }{
+        {
+            name:     "unanimous",
+            strategy: EnsembleVote,
+            members: []Classifier{
+                &scriptedClassifier{name: "openai", intent: "delivery_problems", confidence: 0.9},
+                &scriptedClassifier{name: "rules", intent: "delivery_problems", confidence: 0.5},
+            },
+            weights:    []float64{1, 1},
+            intent:     "delivery_problems",
+            confidence: 1,
+        },
+        {
+            name:     "weighted vote",
+            strategy: EnsembleVote,
+            members: []Classifier{
+                &scriptedClassifier{name: "openai", intent: "delivery_problems", confidence: 0.6},
+                &scriptedClassifier{name: "bayes", intent: "billing_discrepancies", confidence: 0.9},
+                &scriptedClassifier{name: "rules", intent: "billing_discrepancies", confidence: 0.9},
+            },
+            weights:     []float64{3, 1, 1},
+            intent:      "delivery_problems",
+            confidence:  0.6,
+            needsReview: true,
+        },
+        {
+            name:     "equal votes go to the more confident intent",
+            strategy: EnsembleVote,
+            members: []Classifier{
+                &scriptedClassifier{name: "openai", intent: "delivery_problems", confidence: 0.6},
+                &scriptedClassifier{name: "rules", intent: "billing_discrepancies", confidence: 0.9},
+            },
+            weights:     []float64{1, 1},
+            intent:      "billing_discrepancies",
+            confidence:  0.5,
+            needsReview: true,
+        },
+        {
+            name:     "confidence strategy",
+            strategy: EnsembleConfidence,
+            members: []Classifier{
+                &scriptedClassifier{name: "openai", intent: "delivery_problems", confidence: 0.4},
+                &scriptedClassifier{name: "rules", intent: "billing_discrepancies", confidence: 0.9},
+            },
+            weights:     []float64{2, 1},
+            intent:      "billing_discrepancies",
+            confidence:  0.3,
+            needsReview: true,
+        },
+        {
+            name:     "failed members are left out",
+            strategy: EnsembleVote,
+            members: []Classifier{
+                failing("openai", "timeout"),
+                &scriptedClassifier{name: "rules", intent: "billing_discrepancies", confidence: 0.9},
+            },
+            weights:    []float64{5, 1},
+            intent:     "billing_discrepancies",
+            confidence: 1,
+        },
+        {
+            name:     "strict intents need agreement",
+            strategy: EnsembleVote,
+            members: []Classifier{
+                &scriptedClassifier{name: "openai", intent: "refund_processing_issues", confidence: 0.9},
+                &scriptedClassifier{name: "rules", intent: "billing_discrepancies", confidence: 0.5},
+            },
+            weights:     []float64{2, 1},
+            intent:      "general",
+            confidence:  2.0 / 3,
+            needsReview: true,
+        },
+    }
+
+    for _, tt := range tests {
+        ensemble, err := NewEnsembleClassifier(tt.members, tt.weights, DefaultTaxonomy(), tt.strategy, DefaultStrictIntents)
+        if err != nil {
+            t.Fatalf("%s: %v", tt.name, err)
+        }
+        result, err := ensemble.ClassifyQuery(context.Background(), "hello")
+        if err != nil {
+            t.Fatalf("%s: %v", tt.name, err)
+        }
+        if result.Intent != tt.intent || math.Abs(result.Confidence-tt.confidence) > 1e-9 || result.NeedsReview != tt.needsReview {
+            t.Errorf("%s: got %s at %.3f (review %v), want %s at %.3f (review %v)", tt.name,
+                result.Intent, result.Confidence, result.NeedsReview, tt.intent, tt.confidence, tt.needsReview)
+        }
+        if len(result.Votes) != len(tt.members) {
+            t.Errorf("%s: %d votes for %d members", tt.name, len(result.Votes), len(tt.members))
+        }
+    }
+}
+
+func TestEnsembleFailsOnlyWhenEveryMemberFails(t *testing.T) {
+    ensemble, _ := NewEnsembleClassifier([]Classifier{failing("openai", "timeout"), failing("bayes", "no model")},
+        []float64{1, 1}, DefaultTaxonomy(), EnsembleVote, nil)
+
+    if _, err := ensemble.ClassifyQuery(context.Background(), "hello"); !errors.Is(err, ErrClassifierUnavailable) {
+        t.Errorf("got %v, want ErrClassifierUnavailable", err)
+    }
+}
+
+func TestEnsembleStrictOverrideGoesToHumanTriage(t *testing.T) {
+    ensemble, _ := NewEnsembleClassifier([]Classifier{
+        &scriptedClassifier{name: "openai", intent: "order_cancellation_requests", confidence: 0.9},
+        &scriptedClassifier{name: "rules", intent: "delivery_problems", confidence: 0.9},
+    }, []float64{2, 1}, DefaultTaxonomy(), EnsembleVote, DefaultStrictIntents)
+
+    result, _ := ensemble.ClassifyQuery(context.Background(), "hello")
+    if result.Agent != HumanTriageAgent || result.OriginalIntent != "order_cancellation_requests" {
+        t.Errorf("strict override routed to %s, original %s", result.Agent, result.OriginalIntent)
+    }
+    stats := ensemble.GetStats()["ensemble"].(map[string]interface{})
+    if stats["strict_overrides"] != int64(1) || len(stats["recent_disagreements"].([]EnsembleDisagreement)) != 1 {
+        t.Errorf("stats %+v", stats)
+    }
+}
+
+func TestParseEnsembleBackends(t *testing.T) {
+    backends, err := ParseEnsembleBackends("openai:2, bayes ,rules:0.5,")
+    if err != nil {
+        t.Fatal(err)
+    }
+    want := []EnsembleBackend{{"openai", 2}, {"bayes", 1}, {"rules", 0.5}}
+    if len(backends) != len(want) {
+        t.Fatalf("parsed %+v, want %+v", backends, want)
+    }
+    for i := range want {
+        if backends[i] != want[i] {
+            t.Errorf("backend %d: %+v, want %+v", i, backends[i], want[i])
+        }
+    }
+
+    for _, spec := range []string{"openai:0", "openai:-1", "openai:heavy"} {
+        if _, err := ParseEnsembleBackends(spec); err == nil {
+            t.Errorf("%q: accepted", spec)
+        }
+    }
+}
@@ -0,0 +1,164 @@
+package services
+
+import (
+    "bufio"
+    "context"
+    "fmt"
+    "os"
+    "regexp"
+    "sort"
+    "strings"
+
+    "customer-query-router/models"
+)
+
+// Entity types returned by the EntityExtractor
+const (
+    EntityOrderID = "order_id"
+    EntityProduct = "product"
+    EntityEmail   = "email"
+    EntityPhone   = "phone"
+    EntityAmount  = "amount"
+    EntityDate    = "date"
+)
+
+const (
+    monthPattern = `(?:jan(?:uary)?|feb(?:ruary)?|mar(?:ch)?|apr(?:il)?|may|june?|july?|aug(?:ust)?|sep(?:t(?:ember)?)?|oct(?:ober)?|nov(?:ember)?|dec(?:ember)?)`
+    dayPattern   = `\d{1,2}(?:st|nd|rd|th)?`
+)
+
+// DefaultProducts is the BrownBox catalogue as it appears in customer messages
+func DefaultProducts() []string {
+    return []string{
+        "juicer mixer grinder", "mixer grinder", "juicer", "mixer", "grinder",
+        "otg", "oven", "microwave", "induction cooktop", "cooktop", "kettle",
+        "coffee maker", "food processor", "sandwich maker", "toaster", "blender", "chimney",
+        "washing machine", "dishwasher", "refrigerator", "air conditioner", "water purifier",
+        "vacuum cleaner", "geyser", "fan", "monitor", "laptop", "tablet", "printer",
+        "camera", "speaker", "headphones", "smartwatch", "television", "tv",
+        "mobile phone", "smartphone", "power bank", "hard drive", "shoes", "shirt",
+    }
+}
+
+// LoadProducts reads a product catalogue, one name per line. Blank lines and # comments are skipped.
+func LoadProducts(filename string) ([]string, error) {
+    file, err := os.Open(filename)
+    if err != nil {
+        return nil, fmt.Errorf("error opening products file: %w", err)
+    }
+    defer file.Close()
+
+    products := []string{}
+    scanner := bufio.NewScanner(file)
+    for scanner.Scan() {
+        line := strings.TrimSpace(scanner.Text())
+        if line != "" && !strings.HasPrefix(line, "#") {
+            products = append(products, line)
+        }
+    }
+    if err := scanner.Err(); err != nil {
+        return nil, fmt.Errorf("error reading products file: %w", err)
+    }
+    return products, nil
+}
+
+type entityPattern struct {
+    kind string
+    re   *regexp.Regexp
+}
+
+// EntityExtractor pulls order IDs, products, contact details, amounts and dates
+// out of a customer message with local patterns, so no data leaves the process
+type EntityExtractor struct {
+    patterns []entityPattern
+}
+
+func NewEntityExtractor(products []string) (*EntityExtractor, error) {
+    // Longest names first, so "mixer grinder" wins over "mixer"
+    names := append([]string{}, products...)
+    sort.SliceStable(names, func(i, j int) bool {
+        return len(names[i]) > len(names[j])
+    })
+    alternatives := []string{}
+    for _, name := range names {
+        words := strings.Fields(strings.ToLower(name))
+        if len(words) == 0 {
+            continue
+        }
+        for i, word := range words {
+            words[i] = regexp.QuoteMeta(word)
+        }
+        alternatives = append(alternatives, strings.Join(words, `[\s/-]+`))
+    }
+
+    sources := []struct{ kind, pattern string }{
+        {EntityEmail, emailPattern},
+        {EntityPhone, phonePattern},
+        {EntityOrderID, orderIDPattern},
+        {EntityOrderID, orderNumberPattern},
+        {EntityAmount, `(?i)(?:[$₹€£]|\b(?:rs\.?|inr|usd|eur)\s?)\d[\d,]*(?:\.\d+)?|\b\d[\d,]*(?:\.\d+)?\s?(?:dollars|rupees|euros)\b`},
+        {EntityDate, `(?i)\b` + dayPattern + `\s+(?:of\s+)?` + monthPattern + `\b(?:,?\s+\d{4})?|\b` + monthPattern + `\s+` + dayPattern + `\b(?:,?\s+\d{4})?|\b\d{1,2}/\d{1,2}/\d{2,4}\b|\b\d{4}-\d{2}-\d{2}\b`},
+    }
+    if len(alternatives) > 0 {
+        sources = append(sources, struct{ kind, pattern string }{EntityProduct, `(?i)\b(?:` + strings.Join(alternatives, "|") + `)\b`})
+    }
+
+    extractor := &EntityExtractor{}
+    for _, source := range sources {
+        re, err := regexp.Compile(source.pattern)
+        if err != nil {
+            return nil, fmt.Errorf("invalid %s pattern: %w", source.kind, err)
+        }
+        extractor.patterns = append(extractor.patterns, entityPattern{kind: source.kind, re: re})
+    }
+    return extractor, nil
+}
+
+// Extract returns every entity in text once, grouped by type in a fixed order
+func (ee *EntityExtractor) Extract(text string) []models.Entity {
+    entities := []models.Entity{}
+    seen := make(map[string]bool)
+    for _, pattern := range ee.patterns {
+        for _, match := range pattern.re.FindAllStringSubmatch(text, -1) {
+            value := match[0]
+            if len(match) > 1 && match[1] != "" {
+                value = match[1]
+            }
+            value = strings.TrimSpace(value)
+            if pattern.kind == EntityProduct {
+                value = strings.ToLower(value)
+            }
+
+            key := pattern.kind + "|" + strings.ToLower(value)
+            if seen[key] {
+                continue
+            }
+            seen[key] = true
+            entities = append(entities, models.Entity{Type: pattern.kind, Value: value})
+        }
+    }
+    return entities
+}
+
+// EntityExtractingClassifier attaches the entities of the original message to the result.
+// It sits in front of the redaction, so the agent sees the real values.
+type EntityExtractingClassifier struct {
+    Classifier
+    extractor *EntityExtractor
+}
+
+func NewEntityExtractingClassifier(inner Classifier, extractor *EntityExtractor) *EntityExtractingClassifier {
+    return &EntityExtractingClassifier{
+        Classifier: inner,
+        extractor:  extractor,
+    }
+}
+
+func (ec *EntityExtractingClassifier) ClassifyQuery(ctx context.Context, customerMessage string) (*models.ClassificationResult, error) {
+    result, err := ec.Classifier.ClassifyQuery(ctx, customerMessage)
+    if err != nil {
+        return nil, err
+    }
+    result.Entities = ec.extractor.Extract(customerMessage)
+    return result, nil
+}
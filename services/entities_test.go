@@ -0,0 +1,65 @@
+package services
+
+import (
+    "os"
+    "path/filepath"
+    "strings"
+    "testing"
+
+    "customer-query-router/models"
+)
+
+func describeEntities(entities []models.Entity) string {
+    described := make([]string, len(entities))
+    for i, entity := range entities {
+        described[i] = entity.Type + "=" + entity.Value
+    }
+    return strings.Join(described, ", ")
+}
+
+func TestEntityExtractorFindsEntities(t *testing.T) {
+    extractor, err := NewEntityExtractor(DefaultProducts())
+    if err != nil {
+        t.Fatal(err)
+    }
+
+    tests := []struct {
+        message string
+        want    string
+    }{
+        {"My Juicer/Mixer/Grinder order BB123456 never came", "order_id=BB123456, product=juicer/mixer/grinder"},
+        {"my order number is 987654, I bought an OTG", "order_id=987654, product=otg"},
+        {"Write to john.doe@gmail.com or call 123-456-7890", "email=john.doe@gmail.com, phone=123-456-7890"},
+        {"I was charged $49.99 and then 2,500 rupees", "amount=$49.99, amount=2,500 rupees"},
+        {"It arrived on 5th March, 2024 instead of 2024-03-01", "date=5th March, 2024, date=2024-03-01"},
+        // Each entity is reported once, whatever its case
+        {"The TV, the tv and the television", "product=tv, product=television"},
+        {"Nothing to see here", ""},
+    }
+
+    for _, tt := range tests {
+        if got := describeEntities(extractor.Extract(tt.message)); got != tt.want {
+            t.Errorf("%q: got %q, want %q", tt.message, got, tt.want)
+        }
+    }
+}
+
+func TestLoadProductsSkipsCommentsAndBlankLines(t *testing.T) {
+    filename := filepath.Join(t.TempDir(), "products.txt")
+    if err := os.WriteFile(filename, []byte("# Kitchen\nair fryer\n\n  rice cooker  \n"), 0644); err != nil {
+        t.Fatal(err)
+    }
+
+    products, err := LoadProducts(filename)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if strings.Join(products, "|") != "air fryer|rice cooker" {
+        t.Fatalf("loaded %q", products)
+    }
+
+    extractor, _ := NewEntityExtractor(products)
+    if got := describeEntities(extractor.Extract("My Air-Fryer broke")); got != "product=air-fryer" {
+        t.Errorf("got %q, want the custom product", got)
+    }
+}
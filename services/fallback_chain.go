@@ -0,0 +1,148 @@
+package services
+
+import (
+    "context"
+    "fmt"
+    "log"
+    "strings"
+    "sync"
+
+    "customer-query-router/models"
+)
+
+// FallbackChainClassifier tries backends in order, for example openai -> bayes -> rules -> static.
+// A step that fails or answers below minConfidence hands over to the next one. When no step
+// is confident enough the most confident answer wins, so the customer always gets one while
+// any backend works. The result reports every attempt and which backend answered.
+type FallbackChainClassifier struct {
+    steps         []Classifier // steps[0] is the primary
+    minConfidence float64
+
+    mu        sync.Mutex
+    answered  map[string]int64
+    failures  map[string]int64
+    skipped   map[string]int64
+    exhausted int64
+}
+
+func NewFallbackChainClassifier(steps []Classifier, minConfidence float64) *FallbackChainClassifier {
+    names := make([]string, len(steps))
+    for i, step := range steps {
+        names[i] = step.Name()
+    }
+    log.Printf("[FALLBACK] Chain %s, minimum confidence %.2f", strings.Join(names, " -> "), minConfidence)
+
+    return &FallbackChainClassifier{
+        steps:         steps,
+        minConfidence: minConfidence,
+        answered:      make(map[string]int64),
+        failures:      make(map[string]int64),
+        skipped:       make(map[string]int64),
+    }
+}
+
+func (fc *FallbackChainClassifier) ClassifyQuery(ctx context.Context, customerMessage string) (*models.ClassificationResult, error) {
+    attempts := []models.BackendAttempt{}
+    var best *models.ClassificationResult
+    bestAttempt := -1
+    var lastErr error
+
+    for _, step := range fc.steps {
+        result, err := step.ClassifyQuery(ctx, customerMessage)
+        if err != nil {
+            if ctx.Err() != nil {
+                return nil, err // The caller went away, no point asking the next backend
+            }
+            lastErr = err
+            attempts = append(attempts, models.BackendAttempt{Backend: step.Name(), Error: err.Error()})
+            fc.count(fc.failures, step.Name())
+            log.Printf("[FALLBACK] %s failed, trying the next backend: %v", step.Name(), err)
+            continue
+        }
+
+        attempts = append(attempts, models.BackendAttempt{
+            Backend:    result.Backend,
+            Intent:     result.Intent,
+            Confidence: result.Confidence,
+        })
+        if best == nil || result.Confidence > best.Confidence {
+            best, bestAttempt = result, len(attempts)-1
+        }
+        if result.Confidence >= fc.minConfidence {
+            break
+        }
+        log.Printf("[FALLBACK] %s answered %s with %.2f (< %.2f), trying the next backend",
+            result.Backend, result.Intent, result.Confidence, fc.minConfidence)
+    }
+
+    if best == nil {
+        fc.mu.Lock()
+        fc.exhausted++
+        fc.mu.Unlock()
+        return nil, fmt.Errorf("%w: every backend in the fallback chain failed: %v", ErrClassifierUnavailable, lastErr)
+    }
+
+    // Confident answers end the chain, so every other answer was passed over
+    for i := range attempts {
+        if attempts[i].Error == "" && i != bestAttempt {
+            attempts[i].Skipped = true
+            fc.count(fc.skipped, attempts[i].Backend)
+        }
+    }
+    fc.count(fc.answered, best.Backend)
+
+    if len(attempts) > 1 {
+        best.Attempts = attempts
+    }
+    return best, nil
+}
+
+func (fc *FallbackChainClassifier) count(counter map[string]int64, backend string) {
+    fc.mu.Lock()
+    defer fc.mu.Unlock()
+    counter[backend]++
+}
+
+func (fc *FallbackChainClassifier) GetAllIntents() []Intent {
+    return fc.steps[0].GetAllIntents()
+}
+
+func (fc *FallbackChainClassifier) GetStats() map[string]interface{} {
+    stats := fc.steps[0].GetStats()
+
+    backends := map[string]interface{}{}
+    for _, step := range fc.steps[1:] {
+        backends[step.Name()] = step.GetStats()
+    }
+
+    fc.mu.Lock()
+    defer fc.mu.Unlock()
+
+    names := make([]string, len(fc.steps))
+    for i, step := range fc.steps {
+        names[i] = step.Name()
+    }
+    stats["fallback_chain"] = map[string]interface{}{
+        "chain":          names,
+        "min_confidence": fc.minConfidence,
+        "answered_by":    copyCounts(fc.answered),
+        "failures":       copyCounts(fc.failures),
+        "skipped":        copyCounts(fc.skipped),
+        "exhausted":      fc.exhausted,
+        "backends":       backends,
+    }
+    return stats
+}
+
+// Name is the primary's, answers from later steps report their own backend
+func (fc *FallbackChainClassifier) Name() string {
+    return fc.steps[0].Name()
+}
+
+func copyCounts(counts map[string]int64) map[string]int64 {
+    copied := make(map[string]int64, len(counts))
+    for key, value := range counts {
+        copied[key] = value
+    }
+    return copied
+}
@@ -0,0 +1,116 @@
+package services
+
+import (
+    "context"
+    "errors"
+    "strconv"
+    "strings"
+    "testing"
+)
+
+func TestFallbackChainTriesBackendsInOrder(t *testing.T) {
+    tests := []struct {
+        name          string
+        steps         []*scriptedClassifier
+        minConfidence float64
+        backend       string
+        calls         string // Calls per step
+        attempts      int
+    }{
+        {
+            name: "primary answers",
+            steps: []*scriptedClassifier{
+                {name: "openai", intent: "delivery_problems", confidence: 0.9},
+                {name: "rules", intent: "general"},
+            },
+            backend: "openai",
+            calls:   "10",
+        },
+        {
+            name: "primary fails",
+            steps: []*scriptedClassifier{
+                failing("openai", "circuit open"),
+                {name: "bayes", intent: "delivery_problems", confidence: 0.7},
+                {name: "rules", intent: "general"},
+            },
+            backend:  "bayes",
+            calls:    "110",
+            attempts: 2,
+        },
+        {
+            name: "low confidence moves on",
+            steps: []*scriptedClassifier{
+                {name: "openai", intent: "delivery_problems", confidence: 0.4},
+                {name: "bayes", intent: "billing_discrepancies", confidence: 0.8},
+            },
+            minConfidence: 0.6,
+            backend:       "bayes",
+            calls:         "11",
+            attempts:      2,
+        },
+        {
+            name: "nobody confident, the most confident wins",
+            steps: []*scriptedClassifier{
+                {name: "openai", intent: "delivery_problems", confidence: 0.5},
+                failing("bayes", "no model"),
+                {name: "rules", intent: "billing_discrepancies", confidence: 0.3},
+            },
+            minConfidence: 0.6,
+            backend:       "openai",
+            calls:         "111",
+            attempts:      3,
+        },
+    }
+
+    for _, tt := range tests {
+        steps := make([]Classifier, len(tt.steps))
+        for i, step := range tt.steps {
+            steps[i] = step
+        }
+        chain := NewFallbackChainClassifier(steps, tt.minConfidence)
+
+        result, err := chain.ClassifyQuery(context.Background(), "hello")
+        if err != nil {
+            t.Fatalf("%s: %v", tt.name, err)
+        }
+        calls := ""
+        for _, step := range tt.steps {
+            calls += strconv.Itoa(step.calls)
+        }
+        if result.Backend != tt.backend || calls != tt.calls {
+            t.Errorf("%s: answered by %s after calls %s, want %s after %s", tt.name, result.Backend, calls, tt.backend, tt.calls)
+        }
+        if len(result.Attempts) != tt.attempts {
+            t.Errorf("%s: %d attempts reported, want %d", tt.name, len(result.Attempts), tt.attempts)
+        }
+        for _, attempt := range result.Attempts {
+            if attempt.Skipped == (attempt.Backend == tt.backend) && attempt.Error == "" {
+                t.Errorf("%s: attempt %+v skipped wrongly", tt.name, attempt)
+            }
+        }
+    }
+}
+
+func TestFallbackChainFailsWhenEveryBackendFails(t *testing.T) {
+    chain := NewFallbackChainClassifier([]Classifier{failing("openai", "timeout"), failing("rules", "no rules")}, 0)
+
+    _, err := chain.ClassifyQuery(context.Background(), "hello")
+    if !errors.Is(err, ErrClassifierUnavailable) || !strings.Contains(err.Error(), "no rules") {
+        t.Errorf("got %v, want ErrClassifierUnavailable with the last error", err)
+    }
+    stats := chain.GetStats()["fallback_chain"].(map[string]interface{})
+    if stats["exhausted"] != int64(1) {
+        t.Errorf("exhausted %v, want 1", stats["exhausted"])
+    }
+}
+
+func TestFallbackChainStopsWhenTheCallerIsDone(t *testing.T) {
+    next := &scriptedClassifier{name: "rules", intent: "general"}
+    chain := NewFallbackChainClassifier([]Classifier{failing("openai", "context canceled"), next}, 0)
+    ctx, cancel := context.WithCancel(context.Background())
+    cancel()
+
+    if _, err := chain.ClassifyQuery(ctx, "hello"); err == nil || next.calls != 0 {
+        t.Errorf("asked the next backend for a cancelled caller: %v", err)
+    }
+}
@@ -0,0 +1,260 @@
+package services
+
+import (
+    "context"
+    "fmt"
+    "log"
+    "net/http"
+    "strings"
+    "sync"
+    "unicode"
+
+    "customer-query-router/models"
+    openai "github.com/sashabaranov/go-openai"
+)
+
+// DefaultLanguage is assumed when a message gives no evidence of another language
+const DefaultLanguage = "en"
+
+// Language routing modes
+const (
+    LanguageRoutingOff  = "off"
+    LanguageRoutingSoft = "soft" // Prefer agents who speak the language
+    LanguageRoutingHard = "hard" // Only agents who speak the language
+)
+
+// languageProfiles are frequent function words, they identify a language in a few words
+var languageProfiles = map[string]map[string]bool{
+    "en": wordSet("the", "and", "is", "are", "was", "my", "i", "you", "it", "to", "of", "have", "not", "with", "for", "this", "that", "order", "please", "can", "would", "hello", "hi"),
+    "es": wordSet("el", "la", "los", "las", "y", "es", "está", "mi", "yo", "usted", "que", "de", "por", "para", "con", "no", "pedido", "hola", "gracias", "quiero", "tengo", "cuenta", "pero", "muy", "cuándo", "dónde", "reembolso"),
+    "fr": wordSet("le", "la", "les", "et", "est", "mon", "ma", "mes", "je", "vous", "que", "de", "du", "pour", "avec", "ne", "pas", "commande", "bonjour", "merci", "veux", "j'ai", "compte", "mais", "très", "quand", "où", "remboursement"),
+}
+
+// languageMarks are characters (or punctuation) only one of the supported languages uses
+var languageMarks = map[rune]string{
+    'ñ': "es", '¿': "es", '¡': "es", 'á': "es", 'í': "es", 'ó': "es", 'ú': "es",
+    'ç': "fr", 'è': "fr", 'ê': "fr", 'à': "fr", 'â': "fr", 'î': "fr", 'ô': "fr", 'û': "fr", 'œ': "fr", 'ë': "fr",
+}
+
+func wordSet(words ...string) map[string]bool {
+    set := make(map[string]bool, len(words))
+    for _, word := range words {
+        set[word] = true
+    }
+    return set
+}
+
+// LanguageDetector identifies English, Spanish and French from function words and accents
+type LanguageDetector struct{}
+
+func NewLanguageDetector() *LanguageDetector {
+    return &LanguageDetector{}
+}
+
+// Detect returns an ISO 639-1 code and a confidence from 0 to 1
+func (ld *LanguageDetector) Detect(text string) (string, float64) {
+    text = redactionToken.ReplaceAllString(strings.ToLower(text), " ")
+
+    scores := make(map[string]float64)
+    for _, r := range text {
+        if language, ok := languageMarks[r]; ok {
+            scores[language] += 0.5
+        }
+    }
+    words := strings.FieldsFunc(text, func(r rune) bool {
+        return !unicode.IsLetter(r) && r != '\''
+    })
+    for _, word := range words {
+        for language, profile := range languageProfiles {
+            if profile[word] {
+                scores[language]++
+            }
+        }
+    }
+
+    best, total := DefaultLanguage, 0.0
+    for _, language := range []string{"en", "es", "fr"} { // Fixed order keeps ties stable
+        total += scores[language]
+        if scores[language] > scores[best] {
+            best = language
+        }
+    }
+    if total == 0 {
+        return DefaultLanguage, 0
+    }
+    return best, scores[best] / total
+}
+
+// Translator turns a message into the language the classifiers understand
+type Translator interface {
+    Translate(ctx context.Context, text, from, to string) (string, error)
+}
+
+// OpenAITranslator translates with the configured OpenAI-compatible chat model. NewClassifier
+// puts it behind the OpenAI backend's circuit breaker and budgets, and records its usage.
+type OpenAITranslator struct {
+    client      *openai.Client
+    model       string
+    price       ModelPrice
+    retryPolicy RetryPolicy
+    breaker     *circuitBreaker
+    usage       *UsageTracker // Nil disables accounting
+}
+
+func NewOpenAITranslator(config OpenAIConfig, retryPolicy RetryPolicy) *OpenAITranslator {
+    clientConfig := openai.DefaultConfig(config.APIKey)
+    if config.BaseURL != "" {
+        clientConfig.BaseURL = strings.TrimRight(config.BaseURL, "/")
+    }
+    clientConfig.OrgID = config.Organization
+    clientConfig.HTTPClient = &http.Client{Transport: zeroTemperatureTransport{base: http.DefaultTransport}}
+    if config.Model == "" {
+        config.Model = DefaultOpenAIModel
+    }
+
+    return &OpenAITranslator{
+        client:      openai.NewClientWithConfig(clientConfig),
+        model:       config.Model,
+        price:       priceForConfig(config),
+        retryPolicy: retryPolicy,
+        breaker:     newCircuitBreaker("openai", DefaultBreakerThreshold, DefaultBreakerCooldown),
+    }
+}
+
+// guard shares the breaker of the OpenAI backend and bills translations to usage
+func (ot *OpenAITranslator) guard(breaker *circuitBreaker, usage *UsageTracker) {
+    ot.breaker = breaker
+    ot.usage = usage
+}
+
+func (ot *OpenAITranslator) Translate(ctx context.Context, text, from, to string) (string, error) {
+    client := ClientFromContext(ctx)
+    if ot.usage != nil {
+        if err := ot.usage.CheckBudget(client); err != nil {
+            return "", fmt.Errorf("translation skipped: %w", err)
+        }
+    }
+
+    request := openai.ChatCompletionRequest{
+        Model: ot.model,
+        Messages: []openai.ChatCompletionMessage{
+            {
+                Role: openai.ChatMessageRoleSystem,
+                Content: fmt.Sprintf("Translate the customer message from %s to %s. Keep placeholders such as [ORDER_ID_1] unchanged. Respond with the translation only.",
+                    from, to),
+            },
+            {Role: openai.ChatMessageRoleUser, Content: text},
+        },
+        Temperature: 0, // Sent by zeroTemperatureTransport
+    }
+
+    var resp openai.ChatCompletionResponse
+    err := ot.breaker.Do(ctx, func() error {
+        return ot.retryPolicy.Do(ctx, func(callCtx context.Context) error {
+            var callErr error
+            resp, callErr = ot.client.CreateChatCompletion(callCtx, request)
+            return callErr
+        })
+    })
+    if err != nil {
+        return "", fmt.Errorf("translation failed: %w", err)
+    }
+    if ot.usage != nil {
+        ot.usage.Record(client, TranslationUsage, &models.TokenUsage{
+            Model:            ot.model,
+            PromptTokens:     resp.Usage.PromptTokens,
+            CompletionTokens: resp.Usage.CompletionTokens,
+            TotalTokens:      resp.Usage.TotalTokens,
+            CostUSD:          ot.price.Cost(resp.Usage.PromptTokens, resp.Usage.CompletionTokens),
+        })
+    }
+    if len(resp.Choices) == 0 {
+        return "", fmt.Errorf("translation failed: empty response")
+    }
+    return strings.TrimSpace(resp.Choices[0].Message.Content), nil
+}
+
+// LanguageClassifier records the language of every message. With a translator, messages
+// in other languages are classified in English; the result keeps the translation and
+// callers keep the original message.
+type LanguageClassifier struct {
+    Classifier
+    detector   *LanguageDetector
+    translator Translator // May be nil
+
+    mu           sync.Mutex
+    languages    map[string]int64
+    translations int64
+    failures     int64
+}
+
+func NewLanguageClassifier(inner Classifier, detector *LanguageDetector, translator Translator) *LanguageClassifier {
+    return &LanguageClassifier{
+        Classifier: inner,
+        detector:   detector,
+        translator: translator,
+        languages:  make(map[string]int64),
+    }
+}
+
+func (lc *LanguageClassifier) ClassifyQuery(ctx context.Context, customerMessage string) (*models.ClassificationResult, error) {
+    language, confidence := lc.detector.Detect(customerMessage)
+
+    lc.mu.Lock()
+    lc.languages[language]++
+    lc.mu.Unlock()
+
+    message, translation := customerMessage, ""
+    if language != DefaultLanguage && lc.translator != nil {
+        translated, err := lc.translator.Translate(ctx, customerMessage, language, DefaultLanguage)
+        lc.mu.Lock()
+        if err != nil {
+            lc.failures++
+        } else {
+            lc.translations++
+        }
+        lc.mu.Unlock()
+
+        if err != nil {
+            log.Printf("[LANGUAGE] WARNING - Classifying the %s original: %v", language, err)
+        } else {
+            message, translation = translated, translated
+        }
+    }
+
+    result, err := lc.Classifier.ClassifyQuery(ctx, message)
+    if err != nil {
+        return nil, err
+    }
+    result.Language = language
+    result.LanguageConfidence = confidence
+    result.Translation = translation
+    return result, nil
+}
+
+func (lc *LanguageClassifier) GetStats() map[string]interface{} {
+    stats := lc.Classifier.GetStats()
+
+    lc.mu.Lock()
+    defer lc.mu.Unlock()
+    stats["languages"] = map[string]interface{}{
+        "detected":             copyCounts(lc.languages),
+        "translation_enabled":  lc.translator != nil,
+        "translations":         lc.translations,
+        "translation_failures": lc.failures,
+    }
+    return stats
+}
+
+// speaks reports whether agent speaks language. Agents without languages speak English.
+func speaks(agent *models.Agent, language string) bool {
+    if len(agent.Languages) == 0 {
+        return language == DefaultLanguage
+    }
+    for _, spoken := range agent.Languages {
+        if spoken == language {
+            return true
+        }
+    }
+    return false
+}
@@ -0,0 +1,41 @@
+package services
+
+import (
+    "context"
+    "errors"
+    "testing"
+    "time"
+)
+
+func TestOpenAITranslatorIsMeteredAndGuarded(t *testing.T) {
+    server, requests := fakeOpenAI(t, "Where is my order?")
+    translator := NewOpenAITranslator(OpenAIConfig{BaseURL: server.URL, Model: "gpt-4o-mini"}, DefaultRetryPolicy())
+    tracker := NewUsageTracker(0, 0.0002)
+    breaker := newCircuitBreaker("openai", 1, time.Minute)
+    translator.guard(breaker, tracker)
+    web := WithClient(context.Background(), "web")
+
+    translated, err := translator.Translate(web, "¿Dónde está mi pedido?", "es", "en")
+    if err != nil || translated != "Where is my order?" {
+        t.Fatalf("translated %q, %v", translated, err)
+    }
+    day := tracker.Report()["days"].([]*DailyUsage)[0]
+    translation := day.ByIntent[TranslationUsage]
+    if translation == nil || translation.PromptTokens != 1000 || day.ByClient["web"].CostUSD != (1000*0.15+200*0.60)/1e6 {
+        t.Errorf("recorded %+v for translations, %+v for web", translation, day.ByClient["web"])
+    }
+
+    // The first call used up web's budget
+    if _, err := translator.Translate(web, "Hola", "es", "en"); !errors.Is(err, ErrBudgetExceeded) {
+        t.Errorf("over budget: %v, want ErrBudgetExceeded", err)
+    }
+
+    // The classifier's failures open the shared breaker
+    breaker.recordFailure()
+    if _, err := translator.Translate(context.Background(), "Hola", "es", "en"); !errors.Is(err, ErrClassifierUnavailable) {
+        t.Errorf("circuit open: %v, want ErrClassifierUnavailable", err)
+    }
+    if len(*requests) != 1 {
+        t.Errorf("%d translation requests sent, want only the first", len(*requests))
+    }
+}
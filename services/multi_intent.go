@@ -0,0 +1,68 @@
+package services
+
+import (
+    "context"
+
+    "customer-query-router/models"
+)
+
+const DefaultMultiLabelThreshold = 0.3
+
+// MultiIntentClassifier turns a single-label backend into a multi-label one.
+// Every intent scoring at least the threshold is reported once, the primary first.
+// Backends that already return Intents (OpenAI in multi-label mode) are held to the
+// same threshold, so a secondary intent the model barely believes in never becomes a sub-ticket.
+type MultiIntentClassifier struct {
+    Classifier
+    threshold float64
+}
+
+func NewMultiIntentClassifier(inner Classifier, threshold float64) *MultiIntentClassifier {
+    if threshold <= 0 {
+        threshold = DefaultMultiLabelThreshold
+    }
+    return &MultiIntentClassifier{
+        Classifier: inner,
+        threshold:  threshold,
+    }
+}
+
+func (mc *MultiIntentClassifier) ClassifyQuery(ctx context.Context, customerMessage string) (*models.ClassificationResult, error) {
+    result, err := mc.Classifier.ClassifyQuery(ctx, customerMessage)
+    if err != nil {
+        return nil, err
+    }
+
+    candidates := result.Alternatives
+    if len(result.Intents) > 0 {
+        candidates = result.Intents
+    }
+
+    // The primary always stays, whatever its score
+    intents := []models.IntentScore{{Intent: result.Intent, Score: result.Confidence}}
+    seen := map[string]bool{result.Intent: true}
+    for _, candidate := range candidates {
+        if candidate.Score >= mc.threshold && candidate.Intent != "general" && !seen[candidate.Intent] {
+            seen[candidate.Intent] = true
+            intents = append(intents, candidate)
+        }
+    }
+    result.Intents = intents
+
+    // An alternative equal to the primary intent says nothing new
+    alternatives := result.Alternatives[:0:0]
+    for _, alternative := range result.Alternatives {
+        if alternative.Intent != result.Intent {
+            alternatives = append(alternatives, alternative)
+        }
+    }
+    result.Alternatives = alternatives
+
+    return result, nil
+}
+
+func (mc *MultiIntentClassifier) GetStats() map[string]interface{} {
+    stats := mc.Classifier.GetStats()
+    stats["multi_label_threshold"] = mc.threshold
+    return stats
+}
\ No newline at end of file
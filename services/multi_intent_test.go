@@ -0,0 +1,99 @@
+package services
+
+import (
+    "context"
+    "testing"
+
+    "customer-query-router/models"
+)
+
+func intentNames(scores []models.IntentScore) []string {
+    names := []string{}
+    for _, score := range scores {
+        names = append(names, score.Intent)
+    }
+    return names
+}
+
+func sameNames(got, want []string) bool {
+    if len(got) != len(want) {
+        return false
+    }
+    for i := range want {
+        if got[i] != want[i] {
+            return false
+        }
+    }
+    return true
+}
+
+func TestMultiIntentClassifierKeepsAlternativesAboveTheThreshold(t *testing.T) {
+    tests := []struct {
+        name         string
+        alternatives []models.IntentScore
+        want         []string
+    }{
+        {"single intent", nil, []string{"billing_discrepancies"}},
+        {"above and below the threshold", []models.IntentScore{
+            {Intent: "refund_processing_issues", Score: 0.4},
+            {Intent: "delivery_problems", Score: 0.3},
+            {Intent: "order_status_uncertainty", Score: 0.29},
+        }, []string{"billing_discrepancies", "refund_processing_issues", "delivery_problems"}},
+        {"general is never a secondary intent", []models.IntentScore{
+            {Intent: "general", Score: 0.5},
+        }, []string{"billing_discrepancies"}},
+    }
+
+    for _, tt := range tests {
+        inner := &resultClassifier{result: models.ClassificationResult{
+            Intent:       "billing_discrepancies",
+            Confidence:   0.6,
+            Alternatives: tt.alternatives,
+        }}
+        result, err := NewMultiIntentClassifier(inner, 0.3).ClassifyQuery(context.Background(), "hello")
+        if err != nil {
+            t.Fatalf("%s: %v", tt.name, err)
+        }
+        if got := intentNames(result.Intents); !sameNames(got, tt.want) {
+            t.Errorf("%s: intents %v, want %v", tt.name, got, tt.want)
+        }
+        if result.Intents[0].Score != 0.6 {
+            t.Errorf("%s: primary scored %.2f, want its confidence", tt.name, result.Intents[0].Score)
+        }
+    }
+}
+
+func TestMultiIntentClassifierDefaultsTheThreshold(t *testing.T) {
+    if mc := NewMultiIntentClassifier(&scriptedClassifier{}, 0); mc.threshold != DefaultMultiLabelThreshold {
+        t.Errorf("threshold %.2f, want %.2f", mc.threshold, DefaultMultiLabelThreshold)
+    }
+}
+
+func TestMultiIntentClassifierFiltersBackendIntents(t *testing.T) {
+    inner := &resultClassifier{result: models.ClassificationResult{
+        Intent:     "billing_discrepancies",
+        Confidence: 0.8,
+        // As returned by OpenAI in multi-label mode
+        Intents: []models.IntentScore{
+            {Intent: "billing_discrepancies", Score: 0.8},
+            {Intent: "refund_processing_issues", Score: 0.5},
+            {Intent: "refund_processing_issues", Score: 0.4},
+            {Intent: "delivery_problems", Score: 0.05},
+        },
+        Alternatives: []models.IntentScore{
+            {Intent: "billing_discrepancies", Score: 0.8},
+            {Intent: "refund_processing_issues", Score: 0.1},
+        },
+    }}
+
+    result, err := NewMultiIntentClassifier(inner, 0.3).ClassifyQuery(context.Background(), "hello")
+    if err != nil {
+        t.Fatal(err)
+    }
+    if got, want := intentNames(result.Intents), []string{"billing_discrepancies", "refund_processing_issues"}; !sameNames(got, want) {
+        t.Errorf("intents %v, want %v", got, want)
+    }
+    if got, want := intentNames(result.Alternatives), []string{"refund_processing_issues"}; !sameNames(got, want) {
+        t.Errorf("alternatives %v, want %v without the primary", got, want)
+    }
+}
@@ -0,0 +1,191 @@
+package services
+
+import (
+    "fmt"
+    "os"
+    "sort"
+    "strings"
+    "text/template"
+)
+
+const (
+    DefaultMaxFewShotExamples = 6
+    DefaultPromptTokenBudget  = 1200
+
+    // Examples are capped per intent so one crowded intent cannot fill the prompt
+    maxExamplesPerIntent = 2
+    maxExampleLength     = 240
+)
+
+// DefaultPromptTemplate is used when no template file is configured
+const DefaultPromptTemplate = `You are a customer service query classifier.
+{{.Task}}
+{{range .Intents}}
+- {{.Name}}{{if .Description}}: {{.Description}}{{end}}{{end}}
+
+If the message doesn't clearly fit into any of these specific categories, {{.Fallback}}.
+{{if .Examples}}
+Examples:
+{{range .Examples}}
+Message: "{{.Text}}"
+Intent: {{.Intent}}
+{{end}}{{end}}
+{{.Instructions}}`
+
+// PromptData is what a prompt template can refer to
+type PromptData struct {
+    Task         string
+    Intents      []Intent // Enabled intents, without "general"
+    Fallback     string
+    Examples     []LabeledExample
+    Instructions string
+}
+
+// PromptBuilder renders the classification prompt with intent descriptions
+// and the labeled examples most similar to the customer message
+type PromptBuilder struct {
+    template    *template.Template
+    examples    []LabeledExample
+    index       *TFIDFIndex
+    maxExamples int
+    tokenBudget int
+}
+
+func NewPromptBuilder(templateText string, examples []LabeledExample, maxExamples, tokenBudget int) (*PromptBuilder, error) {
+    if templateText == "" {
+        templateText = DefaultPromptTemplate
+    }
+    tmpl, err := template.New("prompt").Parse(templateText)
+    if err != nil {
+        return nil, fmt.Errorf("invalid prompt template: %w", err)
+    }
+
+    // Keep examples short, the opening customer line carries the intent
+    trimmed := make([]LabeledExample, len(examples))
+    texts := make([]string, len(examples))
+    for i, example := range examples {
+        trimmed[i] = LabeledExample{Intent: example.Intent, Text: truncateAtWord(example.Text, maxExampleLength)}
+        texts[i] = trimmed[i].Text
+    }
+
+    return &PromptBuilder{
+        template:    tmpl,
+        examples:    trimmed,
+        index:       NewTFIDFIndex(texts),
+        maxExamples: maxExamples,
+        tokenBudget: tokenBudget,
+    }, nil
+}
+
+// LoadPromptTemplate reads a text/template file, see PromptData for the fields
+func LoadPromptTemplate(filename string) (string, error) {
+    data, err := os.ReadFile(filename)
+    if err != nil {
+        return "", fmt.Errorf("error reading prompt template: %w", err)
+    }
+    return string(data), nil
+}
+
+// Build renders the prompt for one message. Examples are added in order of
+// similarity until maxExamples or the token budget is reached.
+func (pb *PromptBuilder) Build(intents []Intent, customerMessage string, data PromptData) (string, error) {
+    data.Intents = []Intent{}
+    for _, intent := range intents {
+        if intent.Name != "general" { // "general" is described separately as the fallback
+            data.Intents = append(data.Intents, intent)
+        }
+    }
+    if data.Fallback == "" {
+        data.Fallback = `respond with "general"`
+    }
+
+    prompt, err := pb.render(data)
+    if err != nil {
+        return "", err
+    }
+
+    for _, example := range pb.selectExamples(intents, customerMessage) {
+        candidate := data
+        candidate.Examples = append(append([]LabeledExample{}, data.Examples...), example)
+        rendered, err := pb.render(candidate)
+        if err != nil {
+            return "", err
+        }
+        if estimateTokens(rendered) > pb.tokenBudget {
+            break
+        }
+        data, prompt = candidate, rendered
+    }
+
+    return prompt, nil
+}
+
+// selectExamples ranks taxonomy and corpus examples of enabled intents by similarity to the message
+func (pb *PromptBuilder) selectExamples(intents []Intent, customerMessage string) []LabeledExample {
+    if pb.maxExamples <= 0 {
+        return nil
+    }
+
+    type candidate struct {
+        example    LabeledExample
+        similarity float64
+    }
+    candidates := []candidate{}
+
+    query := pb.index.Vectorize(customerMessage)
+    for _, intent := range intents {
+        for _, text := range intent.Examples {
+            candidates = append(candidates, candidate{
+                example:    LabeledExample{Intent: intent.Name, Text: text},
+                similarity: cosine(query, pb.index.Vectorize(text)),
+            })
+        }
+    }
+    for i, similarity := range pb.index.Similarities(customerMessage) {
+        if isKnownIntent(intents, pb.examples[i].Intent) {
+            candidates = append(candidates, candidate{example: pb.examples[i], similarity: similarity})
+        }
+    }
+
+    sort.SliceStable(candidates, func(i, j int) bool {
+        return candidates[i].similarity > candidates[j].similarity
+    })
+
+    selected := []LabeledExample{}
+    perIntent := make(map[string]int)
+    for _, c := range candidates {
+        if len(selected) >= pb.maxExamples {
+            break
+        }
+        if c.similarity <= 0 || perIntent[c.example.Intent] >= maxExamplesPerIntent {
+            continue
+        }
+        perIntent[c.example.Intent]++
+        selected = append(selected, c.example)
+    }
+    return selected
+}
+
+func (pb *PromptBuilder) render(data PromptData) (string, error) {
+    var builder strings.Builder
+    if err := pb.template.Execute(&builder, data); err != nil {
+        return "", fmt.Errorf("error rendering prompt: %w", err)
+    }
+    return builder.String(), nil
+}
+
+// estimateTokens approximates the token count of English text (about 4 characters per token)
+func estimateTokens(text string) int {
+    return (len(text) + 3) / 4
+}
+
+func truncateAtWord(text string, maxLength int) string {
+    if len(text) <= maxLength {
+        return text
+    }
+    cut := strings.LastIndex(text[:maxLength], " ")
+    if cut <= 0 {
+        cut = maxLength
+    }
+    return text[:cut] + "..."
+}
\ No newline at end of file
@@ -0,0 +1,126 @@
+package services
+
+import (
+    "strings"
+    "testing"
+)
+
+func TestTFIDFIndexRanksSimilarDocuments(t *testing.T) {
+    index := NewTFIDFIndex([]string{
+        "I was charged twice for my order",
+        "my parcel never arrived",
+        "the parcel arrived damaged",
+    })
+
+    similarities := index.Similarities("parcel never arrived at my door")
+    if !(similarities[1] > similarities[2] && similarities[2] > similarities[0]) {
+        t.Errorf("similarities %v, want the lost parcel first and the charge last", similarities)
+    }
+    if same := index.Similarities("my parcel never arrived")[1]; same < 0.999 || same > 1.001 {
+        t.Errorf("identical text scored %.3f, want 1", same)
+    }
+    if none := index.Similarities("bonjour"); none[0] != 0 || none[1] != 0 || none[2] != 0 {
+        t.Errorf("unknown words scored %v", none)
+    }
+}
+
+func testPromptExamples() []LabeledExample {
+    return []LabeledExample{
+        {Intent: "billing_discrepancies", Text: "I was charged twice on my card"},
+        {Intent: "billing_discrepancies", Text: "there is a double charge on my card statement"},
+        {Intent: "billing_discrepancies", Text: "my card was charged an extra fee"},
+        {Intent: "refund_processing_issues", Text: "my refund to the card has not arrived"},
+        {Intent: "delivery_problems", Text: "the courier lost my parcel"},
+    }
+}
+
+func TestPromptBuilderSelectsSimilarExamples(t *testing.T) {
+    intents := DefaultTaxonomy().Intents()
+    withoutRefunds := []Intent{}
+    for _, intent := range intents {
+        if intent.Name != "refund_processing_issues" {
+            withoutRefunds = append(withoutRefunds, intent)
+        }
+    }
+
+    tests := []struct {
+        name        string
+        intents     []Intent
+        maxExamples int
+        message     string
+        want        map[string]int // Examples per intent
+    }{
+        {"at most two per intent", intents, 6, "charged twice on my card", map[string]int{"billing_discrepancies": 2, "refund_processing_issues": 1}},
+        {"capped in total", intents, 1, "charged twice on my card", map[string]int{"billing_discrepancies": 1}},
+        {"disabled intents are skipped", withoutRefunds, 6, "my card refund", map[string]int{"billing_discrepancies": 2}},
+        {"unrelated examples are left out", intents, 6, "hello", map[string]int{}},
+        {"disabled", intents, 0, "charged twice on my card", map[string]int{}},
+    }
+
+    for _, tt := range tests {
+        builder, err := NewPromptBuilder("", testPromptExamples(), tt.maxExamples, DefaultPromptTokenBudget)
+        if err != nil {
+            t.Fatal(err)
+        }
+        got := make(map[string]int)
+        for _, example := range builder.selectExamples(tt.intents, tt.message) {
+            got[example.Intent]++
+        }
+        if len(got) != len(tt.want) {
+            t.Errorf("%s: selected %v, want %v", tt.name, got, tt.want)
+            continue
+        }
+        for intent, n := range tt.want {
+            if got[intent] != n {
+                t.Errorf("%s: selected %v, want %v", tt.name, got, tt.want)
+                break
+            }
+        }
+    }
+}
+
+func TestPromptBuilderStaysWithinTheTokenBudget(t *testing.T) {
+    intents := DefaultTaxonomy().Intents()
+    unlimited, _ := NewPromptBuilder("", testPromptExamples(), 6, 100000)
+    full, err := unlimited.Build(intents, "charged twice on my card", PromptData{Task: "Classify the message."})
+    if err != nil {
+        t.Fatal(err)
+    }
+    if strings.Contains(full, "- general") || !strings.Contains(full, `respond with "general"`) {
+        t.Error("general is listed as an intent instead of the fallback")
+    }
+
+    // Room for the prompt without examples and one more
+    bare, _ := NewPromptBuilder("", nil, 0, 100000)
+    withoutExamples, _ := bare.Build(intents, "charged twice on my card", PromptData{Task: "Classify the message."})
+    budget := estimateTokens(withoutExamples) + 30
+    limited, _ := NewPromptBuilder("", testPromptExamples(), 6, budget)
+    prompt, err := limited.Build(intents, "charged twice on my card", PromptData{Task: "Classify the message."})
+    if err != nil {
+        t.Fatal(err)
+    }
+
+    if estimateTokens(prompt) > budget {
+        t.Errorf("prompt of %d tokens exceeds the budget of %d", estimateTokens(prompt), budget)
+    }
+    if n := strings.Count(prompt, "Message: "); n != 1 || strings.Count(full, "Message: ") != 3 {
+        t.Errorf("%d examples within the budget and %d without, want 1 and 3", n, strings.Count(full, "Message: "))
+    }
+}
+
+func TestTruncateAtWord(t *testing.T) {
+    tests := []struct {
+        text string
+        max  int
+        want string
+    }{
+        {"short text", 20, "short text"},
+        {"cut between these words", 12, "cut between..."},
+        {"unbreakable", 5, "unbre..."},
+    }
+    for _, tt := range tests {
+        if got := truncateAtWord(tt.text, tt.max); got != tt.want {
+            t.Errorf("truncateAtWord(%q, %d) = %q, want %q", tt.text, tt.max, got, tt.want)
+        }
+    }
+}
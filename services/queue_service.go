@@ -0,0 +1,331 @@
+package services
+
+import (
+    "errors"
+    "fmt"
+    "log"
+    "sort"
+    "sync"
+    "time"
+
+    "customer-query-router/models"
+)
+
+// Queued query statuses
+const (
+    QueueQueued     = "queued"
+    QueueDispatched = "dispatched"
+
+    DefaultQueueMaxSize = 100
+    // DefaultQueueAging raises a waiting query's priority by one level this often
+    DefaultQueueAging = 2 * time.Minute
+    // DefaultHandleTime estimates waits for intents without completed assignments yet
+    DefaultHandleTime = 5 * time.Minute
+
+    // Dispatched queries are kept this long so callers can look up their assignment
+    dispatchedQueryRetention = time.Hour
+)
+
+var ErrQueueFull = errors.New("waiting queue is full")
+
+// QueueService holds queries no agent could take, one queue per intent, and hands them out
+// as soon as a matching agent frees capacity. Higher priority goes first; aging raises the
+// priority of waiting queries so low priority ones are not starved.
+type QueueService struct {
+    agentService *AgentService
+    assignments  *AssignmentService
+    sla          *SLAMonitor
+    maxSize      int           // Per intent
+    aging        time.Duration // 0 disables aging
+
+    mu       sync.Mutex
+    queues   map[string][]*queuedQuery // Intent -> waiting queries in arrival order
+    byTicket map[string]*queuedQuery   // Waiting and recently dispatched queries
+    wake     chan struct{}
+    now      func() time.Time
+}
+
+type queuedQuery struct {
+    query    models.QueuedQuery
+    criteria AgentCriteria
+    anyAgent bool // Fall back to agents outside the intent's specialists
+    breached bool // Breach already logged
+}
+
+func NewQueueService(agentService *AgentService, assignments *AssignmentService, sla *SLAMonitor, maxSize int, aging time.Duration) *QueueService {
+    log.Printf("[QUEUE] Up to %d queries wait per intent, priority +1 every %v waited (0 never)", maxSize, aging)
+
+    qs := &QueueService{
+        agentService: agentService,
+        assignments:  assignments,
+        sla:          sla,
+        maxSize:      maxSize,
+        aging:        aging,
+        queues:       make(map[string][]*queuedQuery),
+        byTicket:     make(map[string]*queuedQuery),
+        wake:         make(chan struct{}, 1),
+        now:          time.Now,
+    }
+    agentService.OnCapacityFreed(qs.Notify)
+    return qs
+}
+
+// Enqueue puts a ticket no agent could take at the back of its intent's queue. With anyAgent
+// it may go to agents outside the intent's specialists. Queries nobody online could ever take
+// are refused rather than left waiting forever.
+func (qs *QueueService) Enqueue(ticketID, parentTicketID string, criteria AgentCriteria, anyAgent bool) (*models.QueuedQuery, error) {
+    if qs.agentService.ServingCapacity(criteria, !anyAgent) == 0 {
+        return nil, fmt.Errorf("no online agent can take %s", criteria.Intent)
+    }
+
+    qs.mu.Lock()
+    defer qs.mu.Unlock()
+
+    if len(qs.queues[criteria.Intent]) >= qs.maxSize {
+        return nil, fmt.Errorf("%w: %d queries wait for %s", ErrQueueFull, qs.maxSize, criteria.Intent)
+    }
+
+    entry := &queuedQuery{
+        query: models.QueuedQuery{
+            TicketID:       ticketID,
+            ParentTicketID: parentTicketID,
+            Intent:         criteria.Intent,
+            Priority:       criteria.Priority,
+            Language:       criteria.Language,
+            Status:         QueueQueued,
+            EnqueuedAt:     qs.now(),
+        },
+        criteria: criteria,
+        anyAgent: anyAgent,
+    }
+    qs.queues[criteria.Intent] = append(qs.queues[criteria.Intent], entry)
+    qs.byTicket[ticketID] = entry
+
+    // Capacity may have been freed since the caller failed to reserve it
+    qs.Notify()
+
+    view := qs.view(entry)
+    log.Printf("[QUEUE] %s waits for %s at position %d, about %ds", ticketID, criteria.Intent, view.Position, view.EstimatedWait)
+    return view, nil
+}
+
+// Get returns a waiting or recently dispatched query by ticket ID
+func (qs *QueueService) Get(ticketID string) (*models.QueuedQuery, bool) {
+    qs.mu.Lock()
+    defer qs.mu.Unlock()
+
+    entry, ok := qs.byTicket[ticketID]
+    if !ok {
+        return nil, false
+    }
+    return qs.view(entry), true
+}
+
+// List returns the waiting queries of intent, or of every intent when it is empty, in dispatch order
+func (qs *QueueService) List(intent string) []*models.QueuedQuery {
+    qs.mu.Lock()
+    defer qs.mu.Unlock()
+
+    list := []*models.QueuedQuery{}
+    for _, entry := range qs.waiting() {
+        if intent == "" || entry.query.Intent == intent {
+            list = append(list, qs.view(entry))
+        }
+    }
+    return list
+}
+
+// WaitingIntents returns the intents of the queries still waiting under ticketID or its sub-tickets
+func (qs *QueueService) WaitingIntents(ticketID string) map[string]bool {
+    qs.mu.Lock()
+    defer qs.mu.Unlock()
+
+    intents := make(map[string]bool)
+    for _, entry := range qs.waiting() {
+        if entry.query.TicketID == ticketID || entry.query.ParentTicketID == ticketID {
+            intents[entry.query.Intent] = true
+        }
+    }
+    return intents
+}
+
+// Stats reports the queue depth and the longest wait per intent
+func (qs *QueueService) Stats() map[string]interface{} {
+    qs.mu.Lock()
+    defer qs.mu.Unlock()
+
+    now := qs.now()
+    depth := make(map[string]int)
+    oldest := make(map[string]int)
+    total := 0
+    for intent, queue := range qs.queues {
+        if len(queue) == 0 {
+            continue
+        }
+        depth[intent] = len(queue)
+        oldest[intent] = int(now.Sub(queue[0].query.EnqueuedAt).Seconds())
+        total += len(queue)
+    }
+    return map[string]interface{}{
+        "total":               total,
+        "depth":               depth,
+        "oldest_wait_seconds": oldest,
+        "max_size":            qs.maxSize,
+    }
+}
+
+// Notify asks the dispatcher to try the queues again. It never blocks.
+func (qs *QueueService) Notify() {
+    select {
+    case qs.wake <- struct{}{}:
+    default: // A dispatch is already pending
+    }
+}
+
+// Run dispatches whenever capacity is freed, and every interval to catch anything else,
+// until stop is closed
+func (qs *QueueService) Run(interval time.Duration, stop <-chan struct{}) {
+    ticker := time.NewTicker(interval)
+    defer ticker.Stop()
+
+    for {
+        select {
+        case <-stop:
+            return
+        case <-qs.wake:
+        case <-ticker.C:
+        }
+        qs.Dispatch()
+    }
+}
+
+// Dispatch assigns waiting queries to agents with free capacity, highest effective priority
+// first, and returns how many it assigned. A query that cannot be placed does not hold up
+// later ones that can, e.g. ones in another language. Breaches of waiting queries are logged.
+func (qs *QueueService) Dispatch() int {
+    qs.mu.Lock()
+    defer qs.mu.Unlock()
+
+    dispatched := 0
+    for _, entry := range qs.waiting() {
+        agent, err := qs.agentService.ReserveAgent(entry.criteria)
+        if err != nil && entry.anyAgent {
+            agent, err = qs.agentService.ReserveAnyAgent(entry.criteria)
+        }
+        if err != nil {
+            continue
+        }
+
+        now := qs.now()
+        qs.sla.RecordAssigned(entry.query.Intent, now.Sub(entry.query.EnqueuedAt))
+        entry.query.Status = QueueDispatched
+        entry.query.DispatchedAt = &now
+        entry.query.AgentID = agent.ID
+        entry.query.AssignmentID = qs.assignments.Open(entry.query.TicketID, entry.query.ParentTicketID, agent, entry.criteria).ID
+        qs.remove(entry)
+        dispatched++
+        log.Printf("[QUEUE] %s dispatched to %s as %s after %v", entry.query.TicketID, agent.ID,
+            entry.query.AssignmentID, now.Sub(entry.query.EnqueuedAt).Round(time.Second))
+    }
+
+    for _, entry := range qs.waiting() {
+        waited := qs.now().Sub(entry.query.EnqueuedAt)
+        if !entry.breached && qs.sla.Status(entry.query.Intent, waited) == SLABreached {
+            entry.breached = true
+            log.Printf("[SLA] WARNING - %s breached the %v %s target, still queued", entry.query.TicketID,
+                qs.sla.Target(entry.query.Intent), entry.query.Intent)
+        }
+    }
+
+    for ticketID, entry := range qs.byTicket {
+        if entry.query.Status == QueueDispatched && qs.now().Sub(*entry.query.DispatchedAt) >= dispatchedQueryRetention {
+            delete(qs.byTicket, ticketID)
+        }
+    }
+    return dispatched
+}
+
+// waiting returns every waiting query in dispatch order. Callers hold mu.
+func (qs *QueueService) waiting() []*queuedQuery {
+    waiting := []*queuedQuery{}
+    for _, queue := range qs.queues {
+        waiting = append(waiting, queue...)
+    }
+    qs.order(waiting)
+    return waiting
+}
+
+// order sorts queries by effective priority, then oldest first. Callers hold mu.
+func (qs *QueueService) order(queries []*queuedQuery) {
+    now := qs.now()
+    sort.Slice(queries, func(i, j int) bool {
+        a, b := queries[i], queries[j]
+        if pa, pb := qs.effectivePriority(a, now), qs.effectivePriority(b, now); pa != pb {
+            return pa > pb
+        }
+        if !a.query.EnqueuedAt.Equal(b.query.EnqueuedAt) {
+            return a.query.EnqueuedAt.Before(b.query.EnqueuedAt)
+        }
+        return a.query.TicketID < b.query.TicketID
+    })
+}
+
+// effectivePriority is the query's priority plus one level for every aging interval waited
+func (qs *QueueService) effectivePriority(entry *queuedQuery, now time.Time) int {
+    priority := entry.query.Priority
+    if priority == 0 {
+        priority = DefaultPriority
+    }
+    if qs.aging > 0 {
+        priority += int(now.Sub(entry.query.EnqueuedAt) / qs.aging)
+    }
+    return priority
+}
+
+// remove takes a query out of its intent's queue. Callers hold mu.
+func (qs *QueueService) remove(entry *queuedQuery) {
+    queue := qs.queues[entry.query.Intent]
+    for i, queued := range queue {
+        if queued == entry {
+            qs.queues[entry.query.Intent] = append(queue[:i:i], queue[i+1:]...)
+            break
+        }
+    }
+    if len(qs.queues[entry.query.Intent]) == 0 {
+        delete(qs.queues, entry.query.Intent)
+    }
+}
+
+// view copies a query with its current position and estimated wait. The wait assumes every
+// slot of the agents who can take it frees up once per average handle time. Callers hold mu.
+func (qs *QueueService) view(entry *queuedQuery) *models.QueuedQuery {
+    copied := entry.query
+    copied.SLADeadline = copied.EnqueuedAt.Add(qs.sla.Target(copied.Intent))
+    if copied.Status != QueueQueued {
+        copied.SLAStatus = SLAMet
+        if qs.sla.Status(copied.Intent, copied.DispatchedAt.Sub(copied.EnqueuedAt)) == SLABreached {
+            copied.SLAStatus = SLABreached
+        }
+        return &copied
+    }
+
+    now := qs.now()
+    copied.EffectivePriority = qs.effectivePriority(entry, now)
+    copied.SLAStatus = qs.sla.Status(copied.Intent, now.Sub(copied.EnqueuedAt))
+    queue := append([]*queuedQuery(nil), qs.queues[copied.Intent]...)
+    qs.order(queue)
+    for i, queued := range queue {
+        if queued == entry {
+            copied.Position = i + 1
+        }
+    }
+    handleTime := qs.assignments.AverageHandleTime(copied.Intent)
+    if handleTime <= 0 {
+        handleTime = DefaultHandleTime
+    }
+    if capacity := qs.agentService.ServingCapacity(entry.criteria, !entry.anyAgent); capacity > 0 {
+        wait := handleTime * time.Duration(copied.Position) / time.Duration(capacity)
+        copied.EstimatedWait = int(wait.Round(time.Second).Seconds())
+    }
+    return &copied
+}
@@ -0,0 +1,146 @@
+package services
+
+import (
+    "testing"
+    "time"
+
+    "customer-query-router/models"
+)
+
+func testSLAMonitor() *SLAMonitor {
+    return NewSLAMonitor(DefaultTaxonomy(), 10*time.Minute, DefaultSLAAtRisk)
+}
+
+// testWarrantyAgents has a single warranty specialist, who can take two queries
+func testWarrantyAgents() *AgentService {
+    return testAgentService(&models.Agent{ID: "warranty", Specialties: []string{"warranty_terms_inquiries"}, MaxCapacity: 2, IsOnline: true})
+}
+
+func TestQueueDispatchesWhenCapacityIsFreed(t *testing.T) {
+    agents := testWarrantyAgents()
+    assignments := NewAssignmentService(agents, 0)
+    queue := NewQueueService(agents, assignments, testSLAMonitor(), 2, 0)
+    criteria := AgentCriteria{Intent: "warranty_terms_inquiries"}
+
+    // Fill the only warranty specialist
+    var open []string
+    for i := 0; i < 2; i++ {
+        agent, err := agents.ReserveAgent(criteria)
+        if err != nil {
+            t.Fatal(err)
+        }
+        open = append(open, assignments.Open(NewTicketID(), "", agent, criteria).ID)
+    }
+
+    first, err := queue.Enqueue("TKT-Q1", "", criteria, false)
+    if err != nil || first.Position != 1 {
+        t.Fatalf("first enqueue: %+v, %v", first, err)
+    }
+    second, err := queue.Enqueue("TKT-Q2", "", criteria, false)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if second.Position != 2 || second.EstimatedWait <= first.EstimatedWait {
+        t.Errorf("second waits at %d for %ds, first for %ds", second.Position, second.EstimatedWait, first.EstimatedWait)
+    }
+    if _, err := queue.Enqueue("TKT-Q3", "", criteria, false); err == nil {
+        t.Error("enqueued past the maximum size")
+    }
+    if n := queue.Dispatch(); n != 0 {
+        t.Fatalf("dispatched %d without free capacity", n)
+    }
+
+    if _, err := assignments.Abandon(open[0]); err != nil {
+        t.Fatal(err)
+    }
+    select {
+    case <-queue.wake:
+    default:
+        t.Error("freeing capacity did not wake the dispatcher")
+    }
+    if n := queue.Dispatch(); n != 1 {
+        t.Fatalf("dispatched %d, want 1", n)
+    }
+
+    dispatched, _ := queue.Get("TKT-Q1")
+    if dispatched.Status != QueueDispatched || dispatched.AssignmentID == "" {
+        t.Errorf("oldest query not dispatched first: %+v", dispatched)
+    }
+    if waiting, _ := queue.Get("TKT-Q2"); waiting.Position != 1 {
+        t.Errorf("TKT-Q2 at position %d, want 1", waiting.Position)
+    }
+}
+
+func TestQueueRefusesQueriesNobodyCanTake(t *testing.T) {
+    agents := testAgentService(
+        &models.Agent{ID: "installer", Specialties: []string{"installation_support_requests"}, MaxCapacity: 2},
+        &models.Agent{ID: "warranty", Specialties: []string{"warranty_terms_inquiries"}, MaxCapacity: 2, IsOnline: true},
+    )
+    queue := NewQueueService(agents, NewAssignmentService(agents, 0), testSLAMonitor(), DefaultQueueMaxSize, 0)
+
+    // The installation specialist is offline
+    if _, err := queue.Enqueue("TKT-Q1", "", AgentCriteria{Intent: "installation_support_requests"}, false); err == nil {
+        t.Error("queued a query no online agent can take")
+    }
+}
+
+func TestQueueDispatchesByPriorityWithAging(t *testing.T) {
+    agents := testWarrantyAgents()
+    assignments := NewAssignmentService(agents, 0)
+    sla := testSLAMonitor()
+    queue := NewQueueService(agents, assignments, sla, DefaultQueueMaxSize, time.Minute)
+    now := time.Now()
+    queue.now = func() time.Time { return now }
+
+    // Fill the warranty specialist
+    low := AgentCriteria{Intent: "warranty_terms_inquiries", Priority: 2}
+    var open []string
+    for i := 0; i < 2; i++ {
+        agent, err := agents.ReserveAgent(low)
+        if err != nil {
+            t.Fatal(err)
+        }
+        open = append(open, assignments.Open(NewTicketID(), "", agent, low).ID)
+    }
+
+    if _, err := queue.Enqueue("TKT-LOW", "", low, false); err != nil {
+        t.Fatal(err)
+    }
+    now = now.Add(90 * time.Second)
+    high := low
+    high.Priority = 4
+    if _, err := queue.Enqueue("TKT-HIGH", "", high, false); err != nil {
+        t.Fatal(err)
+    }
+
+    // 3 after aging one level still ranks below 4
+    if waiting, _ := queue.Get("TKT-HIGH"); waiting.Position != 1 {
+        t.Errorf("higher priority at position %d, want 1", waiting.Position)
+    }
+
+    // Waiting 3 minutes lifts the low priority query to 5
+    now = now.Add(90 * time.Second)
+    waiting, _ := queue.Get("TKT-LOW")
+    if waiting.Position != 1 || waiting.EffectivePriority != 5 {
+        t.Errorf("aged query at position %d with priority %d, want 1 and 5", waiting.Position, waiting.EffectivePriority)
+    }
+    if waiting.SLAStatus != SLAOnTrack {
+        t.Errorf("SLA %s after 3 of 10 minutes, want %s", waiting.SLAStatus, SLAOnTrack)
+    }
+
+    if _, err := assignments.Abandon(open[0]); err != nil {
+        t.Fatal(err)
+    }
+    if n := queue.Dispatch(); n != 1 {
+        t.Fatalf("dispatched %d, want 1", n)
+    }
+    if dispatched, _ := queue.Get("TKT-LOW"); dispatched.Status != QueueDispatched {
+        t.Errorf("aged query not dispatched first")
+    }
+
+    // The other one breaches its target while still waiting
+    now = now.Add(9 * time.Minute)
+    if waiting, _ := queue.Get("TKT-HIGH"); waiting.SLAStatus != SLABreached {
+        t.Errorf("SLA %s after 10.5 minutes, want %s", waiting.SLAStatus, SLABreached)
+    }
+}
@@ -0,0 +1,221 @@
+package services
+
+import (
+    "context"
+    "encoding/json"
+    "fmt"
+    "log"
+    "os"
+    "regexp"
+    "strings"
+    "sync"
+
+    "customer-query-router/models"
+)
+
+// RedactionPattern replaces matches with a numbered token such as [EMAIL_1].
+// When the pattern has a capture group only the first group is replaced,
+// so context like "order number" can be matched without being redacted.
+type RedactionPattern struct {
+    Name    string `json:"name"`
+    Pattern string `json:"pattern"`
+
+    re       *regexp.Regexp
+    validate func(match string) bool
+}
+
+// Patterns shared with the EntityExtractor
+const (
+    emailPattern       = `[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}`
+    phonePattern       = `(?:\+\d{1,3}[\s.-]?)?(?:\(\d{3}\)|\b\d{3})[\s.-]\d{3}[\s.-]\d{4}\b`
+    orderIDPattern     = `\b[A-Z]{2,3}\d{5,}\b|#\d{5,}\b`
+    orderNumberPattern = `(?i)\border\s+(?:number|no\.?|id)\s*(?:is\s*)?:?\s*(\d{5,})\b` // Only the digits are captured
+)
+
+// defaultRedactionPatterns run in order, card numbers before the shorter digit patterns
+func defaultRedactionPatterns() []RedactionPattern {
+    return []RedactionPattern{
+        {Name: "EMAIL", Pattern: emailPattern},
+        {Name: "CARD", Pattern: `\b(?:\d[ -]?){12,18}\d\b`, validate: luhnValid},
+        {Name: "PHONE", Pattern: phonePattern},
+        {Name: "ADDRESS", Pattern: `\b\d{1,5}(?:\s+[A-Z][a-z]+){1,3}\s+(?:Street|St|Avenue|Ave|Road|Rd|Lane|Ln|Drive|Dr|Boulevard|Blvd|Court|Ct|Way|Place|Pl)\b`},
+        {Name: "ORDER_ID", Pattern: orderIDPattern},
+        {Name: "ORDER_ID", Pattern: orderNumberPattern},
+    }
+}
+
+// LoadRedactionPatterns reads extra patterns from a JSON array of {"name", "pattern"}
+func LoadRedactionPatterns(filename string) ([]RedactionPattern, error) {
+    data, err := os.ReadFile(filename)
+    if err != nil {
+        return nil, fmt.Errorf("error reading redaction patterns: %w", err)
+    }
+
+    var patterns []RedactionPattern
+    if err := json.Unmarshal(data, &patterns); err != nil {
+        return nil, fmt.Errorf("error parsing redaction patterns: %w", err)
+    }
+    return patterns, nil
+}
+
+// Redactor removes personal data from messages before they leave the process or reach the logs
+type Redactor struct {
+    patterns []RedactionPattern
+}
+
+// NewRedactor compiles the built-in patterns followed by the custom ones
+func NewRedactor(custom []RedactionPattern) (*Redactor, error) {
+    patterns := append(defaultRedactionPatterns(), custom...)
+    for i := range patterns {
+        name := strings.ToUpper(strings.TrimSpace(patterns[i].Name))
+        if name == "" {
+            return nil, fmt.Errorf("redaction pattern %d has no name", i)
+        }
+        re, err := regexp.Compile(patterns[i].Pattern)
+        if err != nil {
+            return nil, fmt.Errorf("invalid redaction pattern %s: %w", name, err)
+        }
+        patterns[i].Name = name
+        patterns[i].re = re
+    }
+
+    log.Printf("[REDACTION] %d patterns (%d custom)", len(patterns), len(custom))
+    return &Redactor{patterns: patterns}, nil
+}
+
+// Redact replaces personal data with tokens. The vault maps tokens back to the original values.
+func (r *Redactor) Redact(text string) (string, *RedactionVault) {
+    vault := &RedactionVault{tokens: make(map[string]string)}
+    for _, pattern := range r.patterns {
+        text = pattern.replace(text, vault)
+    }
+    return text, vault
+}
+
+func (p RedactionPattern) replace(text string, vault *RedactionVault) string {
+    var builder strings.Builder
+    last := 0
+    for _, match := range p.re.FindAllStringSubmatchIndex(text, -1) {
+        start, end := match[0], match[1]
+        if len(match) >= 4 && match[2] >= 0 {
+            start, end = match[2], match[3]
+        }
+        value := text[start:end]
+        if p.validate != nil && !p.validate(value) {
+            continue
+        }
+        builder.WriteString(text[last:start])
+        builder.WriteString(vault.tokenFor(p.Name, value))
+        last = end
+    }
+    builder.WriteString(text[last:])
+    return builder.String()
+}
+
+// RedactionVault remembers what every token of one message stands for
+type RedactionVault struct {
+    tokens   map[string]string // Value -> token, repeated values share a token
+    entities []models.RedactedEntity
+}
+
+func (v *RedactionVault) tokenFor(kind, value string) string {
+    if token, ok := v.tokens[value]; ok {
+        return token
+    }
+
+    count := 1
+    for _, entity := range v.entities {
+        if entity.Type == kind {
+            count++
+        }
+    }
+    token := fmt.Sprintf("[%s_%d]", kind, count)
+    v.tokens[value] = token
+    v.entities = append(v.entities, models.RedactedEntity{Type: kind, Token: token, Value: value})
+    return token
+}
+
+// Restore puts the original values back into text that contains tokens
+func (v *RedactionVault) Restore(text string) string {
+    for _, entity := range v.entities {
+        text = strings.ReplaceAll(text, entity.Token, entity.Value)
+    }
+    return text
+}
+
+// Entities returns the redacted values in the order they were found
+func (v *RedactionVault) Entities() []models.RedactedEntity {
+    return append([]models.RedactedEntity{}, v.entities...)
+}
+
+// luhnValid tells card numbers apart from other long digit runs
+func luhnValid(number string) bool {
+    sum, digits := 0, 0
+    for i := len(number) - 1; i >= 0; i-- {
+        c := number[i]
+        if c < '0' || c > '9' {
+            continue
+        }
+        d := int(c - '0')
+        if digits%2 == 1 {
+            d *= 2
+            if d > 9 {
+                d -= 9
+            }
+        }
+        sum += d
+        digits++
+    }
+    return digits >= 13 && sum%10 == 0
+}
+
+// RedactingClassifier classifies a redacted copy of the message, so the backends
+// behind it (and their logs) never see personal data. The tokens are attached
+// to the result and restored in the rationale and translation.
+type RedactingClassifier struct {
+    Classifier
+    redactor *Redactor
+
+    mu         sync.Mutex
+    redactions map[string]int64
+}
+
+func NewRedactingClassifier(inner Classifier, redactor *Redactor) *RedactingClassifier {
+    return &RedactingClassifier{
+        Classifier: inner,
+        redactor:   redactor,
+        redactions: make(map[string]int64),
+    }
+}
+
+func (rc *RedactingClassifier) ClassifyQuery(ctx context.Context, customerMessage string) (*models.ClassificationResult, error) {
+    redacted, vault := rc.redactor.Redact(customerMessage)
+    entities := vault.Entities()
+    if len(entities) > 0 {
+        rc.mu.Lock()
+        for _, entity := range entities {
+            rc.redactions[entity.Type]++
+        }
+        rc.mu.Unlock()
+        log.Printf("[REDACTION] Redacted %d values before classification", len(entities))
+    }
+
+    result, err := rc.Classifier.ClassifyQuery(ctx, redacted)
+    if err != nil {
+        return nil, err
+    }
+
+    result.Rationale = vault.Restore(result.Rationale)
+    result.Translation = vault.Restore(result.Translation)
+    result.Redactions = entities
+    return result, nil
+}
+
+func (rc *RedactingClassifier) GetStats() map[string]interface{} {
+    stats := rc.Classifier.GetStats()
+
+    rc.mu.Lock()
+    defer rc.mu.Unlock()
+    stats["redactions"] = copyCounts(rc.redactions)
+    return stats
+}
@@ -0,0 +1,139 @@
+package services
+
+import (
+    "context"
+    "strings"
+    "testing"
+    "time"
+
+    "customer-query-router/models"
+)
+
+func TestRedactorReplacesPersonalData(t *testing.T) {
+    redactor, err := NewRedactor([]RedactionPattern{{Name: "loyalty", Pattern: `\bLOY-\d{4}\b`}})
+    if err != nil {
+        t.Fatal(err)
+    }
+
+    tests := []struct {
+        message  string
+        redacted string
+    }{
+        {"Mail me at jane.doe@email.com please", "Mail me at [EMAIL_1] please"},
+        {"Card 4111 1111 1111 1111 was charged", "Card [CARD_1] was charged"},
+        // Fails the Luhn check, so it is not a card
+        {"Tracking 1234 5678 9012 3456", "Tracking 1234 5678 9012 3456"},
+        {"Call +1 123-456-7890 or 555.123.4567", "Call [PHONE_1] or [PHONE_2]"},
+        {"I live at 1234 Main Street", "I live at [ADDRESS_1]"},
+        {"Order BB123456 and #987654", "Order [ORDER_ID_1] and [ORDER_ID_2]"},
+        // Only the captured digits are redacted, the context stays
+        {"my order number is 987654", "my order number is [ORDER_ID_1]"},
+        {"Same a@b.com twice: a@b.com", "Same [EMAIL_1] twice: [EMAIL_1]"},
+        {"Member LOY-1234", "Member [LOYALTY_1]"},
+        {"Nothing personal here", "Nothing personal here"},
+    }
+
+    for _, tt := range tests {
+        redacted, vault := redactor.Redact(tt.message)
+        if redacted != tt.redacted {
+            t.Errorf("%q: redacted to %q, want %q", tt.message, redacted, tt.redacted)
+        }
+        if restored := vault.Restore(redacted); restored != tt.message {
+            t.Errorf("%q: restored to %q", tt.message, restored)
+        }
+    }
+}
+
+func TestNewRedactorRejectsInvalidPatterns(t *testing.T) {
+    for _, pattern := range []RedactionPattern{{Name: "", Pattern: `\d+`}, {Name: "BROKEN", Pattern: `(`}} {
+        if _, err := NewRedactor([]RedactionPattern{pattern}); err == nil {
+            t.Errorf("accepted %+v", pattern)
+        }
+    }
+}
+
+// recordingClassifier remembers the messages it was asked to classify
+type recordingClassifier struct {
+    scriptedClassifier
+    messages []string
+}
+
+func (rc *recordingClassifier) ClassifyQuery(ctx context.Context, customerMessage string) (*models.ClassificationResult, error) {
+    rc.messages = append(rc.messages, customerMessage)
+    result, err := rc.scriptedClassifier.ClassifyQuery(ctx, customerMessage)
+    if err == nil {
+        result.Rationale = "Customer " + strings.Fields(customerMessage)[2] + " reports a double charge"
+        result.Translation = "I am " + strings.Fields(customerMessage)[2] + " and I was charged twice"
+    }
+    return result, err
+}
+
+func TestRedactingClassifierHidesDataFromTheBackend(t *testing.T) {
+    redactor, _ := NewRedactor(nil)
+    inner := &recordingClassifier{scriptedClassifier: scriptedClassifier{name: "openai", intent: "billing_discrepancies", confidence: 0.9}}
+    classifier := NewRedactingClassifier(inner, redactor)
+
+    result, err := classifier.ClassifyQuery(context.Background(), "I am jane@email.com and was charged twice")
+    if err != nil {
+        t.Fatal(err)
+    }
+    if strings.Contains(inner.messages[0], "jane@email.com") {
+        t.Errorf("backend saw %q", inner.messages[0])
+    }
+    if result.Rationale != "Customer jane@email.com reports a double charge" {
+        t.Errorf("rationale not restored: %q", result.Rationale)
+    }
+    if result.Translation != "I am jane@email.com and I was charged twice" {
+        t.Errorf("translation not restored: %q", result.Translation)
+    }
+    if len(result.Redactions) != 1 || result.Redactions[0].Token != "[EMAIL_1]" {
+        t.Errorf("redactions %+v", result.Redactions)
+    }
+    if counts := classifier.GetStats()["redactions"].(map[string]int64); counts["EMAIL"] != 1 {
+        t.Errorf("redaction stats %+v", counts)
+    }
+}
+
+func TestCachedResultsKeepEachCustomersData(t *testing.T) {
+    redactor, _ := NewRedactor(nil)
+    extractor, err := NewEntityExtractor(nil)
+    if err != nil {
+        t.Fatal(err)
+    }
+    classifier, err := NewClassifier(ClassifierConfig{
+        Backend:      "static",
+        StaticIntent: "account_access_issues",
+        Redactor:     redactor,
+        Entities:     extractor,
+        CacheTTL:     time.Minute,
+    })
+    if err != nil {
+        t.Fatal(err)
+    }
+
+    // Both normalize to the same text, which used to be the cache key
+    for _, email := range []string{"Jane.Doe@Email.com", "jane.doe@email.com"} {
+        result, err := classifier.ClassifyQuery(context.Background(), "I cannot log in as "+email)
+        if err != nil {
+            t.Fatal(err)
+        }
+        if !hasEntity(result.Entities, email) {
+            t.Errorf("%s: entities %+v", email, result.Entities)
+        }
+        if len(result.Redactions) != 1 || result.Redactions[0].Value != email {
+            t.Errorf("%s: redactions %+v", email, result.Redactions)
+        }
+    }
+    if stats := classifier.GetStats()["cache"].(map[string]interface{}); stats["hits"] != int64(1) {
+        t.Errorf("cache stats %v, want both messages to share the redacted entry", stats)
+    }
+}
+
+func hasEntity(entities []models.Entity, value string) bool {
+    for _, entity := range entities {
+        if entity.Value == value {
+            return true
+        }
+    }
+    return false
+}
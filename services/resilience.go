@@ -0,0 +1,278 @@
+package services
+
+import (
+    "context"
+    "errors"
+    "fmt"
+    "log"
+    "math/rand"
+    "net"
+    "net/http"
+    "sync"
+    "time"
+
+    "customer-query-router/models"
+    openai "github.com/sashabaranov/go-openai"
+)
+
+const (
+    DefaultCallTimeout      = 10 * time.Second
+    DefaultMaxRetries       = 2
+    DefaultRetryBaseDelay   = 250 * time.Millisecond
+    DefaultBreakerThreshold = 5
+    DefaultBreakerCooldown  = 30 * time.Second
+)
+
+// ErrClassifierUnavailable is returned when a provider is unhealthy and nothing else could answer
+var ErrClassifierUnavailable = errors.New("classifier unavailable")
+
+// RetryPolicy bounds each provider call and retries transient failures
+// with exponential backoff and full jitter
+type RetryPolicy struct {
+    Timeout    time.Duration // Per attempt, and never beyond the caller's deadline
+    MaxRetries int
+    BaseDelay  time.Duration
+    MaxDelay   time.Duration
+}
+
+func DefaultRetryPolicy() RetryPolicy {
+    return RetryPolicy{
+        Timeout:    DefaultCallTimeout,
+        MaxRetries: DefaultMaxRetries,
+        BaseDelay:  DefaultRetryBaseDelay,
+        MaxDelay:   5 * time.Second,
+    }
+}
+
+// Do runs call until it succeeds, fails with a non-retryable error, runs out of
+// retries, or the parent context is done
+func (rp RetryPolicy) Do(ctx context.Context, call func(ctx context.Context) error) error {
+    var err error
+    for attempt := 0; ; attempt++ {
+        attemptCtx := ctx
+        cancel := context.CancelFunc(func() {})
+        if rp.Timeout > 0 {
+            attemptCtx, cancel = context.WithTimeout(ctx, rp.Timeout)
+        }
+        err = call(attemptCtx)
+        cancel()
+
+        if err == nil || ctx.Err() != nil || attempt >= rp.MaxRetries || !isRetryableError(err) {
+            return err
+        }
+
+        delay := rp.backoff(attempt)
+        log.Printf("[RETRY] Attempt %d failed (%v), retrying in %v", attempt+1, err, delay)
+        select {
+        case <-ctx.Done():
+            return err
+        case <-time.After(delay):
+        }
+    }
+}
+
+// backoff is a random delay up to BaseDelay * 2^attempt, capped at MaxDelay
+func (rp RetryPolicy) backoff(attempt int) time.Duration {
+    ceiling := rp.BaseDelay << attempt
+    if rp.MaxDelay > 0 && (ceiling > rp.MaxDelay || ceiling <= 0) {
+        ceiling = rp.MaxDelay
+    }
+    if ceiling <= 0 {
+        return 0
+    }
+    return time.Duration(rand.Int63n(int64(ceiling)))
+}
+
+// isRetryableError reports rate limits, server errors, timeouts and network failures
+func isRetryableError(err error) bool {
+    var apiErr *openai.APIError
+    if errors.As(err, &apiErr) {
+        return isRetryableStatus(apiErr.HTTPStatusCode)
+    }
+    var requestErr *openai.RequestError
+    if errors.As(err, &requestErr) {
+        return isRetryableStatus(requestErr.HTTPStatusCode)
+    }
+    if errors.Is(err, context.DeadlineExceeded) {
+        return true
+    }
+    var netErr net.Error
+    return errors.As(err, &netErr)
+}
+
+func isRetryableStatus(status int) bool {
+    return status == http.StatusTooManyRequests || status == http.StatusRequestTimeout || status >= 500
+}
+
+type breakerState string
+
+const (
+    breakerClosed   breakerState = "closed"
+    breakerOpen     breakerState = "open"
+    breakerHalfOpen breakerState = "half_open"
+)
+
+// circuitBreaker stops calls to an unhealthy provider. After threshold consecutive
+// failures it opens and fails fast with ErrClassifierUnavailable for the cooldown, then
+// lets a single trial call through (half-open) to test recovery. Every caller of a
+// provider shares its breaker, so classification and translation see the same outage.
+type circuitBreaker struct {
+    name      string
+    threshold int
+    cooldown  time.Duration
+
+    mu                  sync.Mutex
+    state               breakerState
+    consecutiveFailures int
+    openedAt            time.Time
+    trialInFlight       bool
+    trips               int64
+    rejected            int64
+    now                 func() time.Time
+}
+
+func newCircuitBreaker(name string, threshold int, cooldown time.Duration) *circuitBreaker {
+    if threshold <= 0 {
+        threshold = DefaultBreakerThreshold
+    }
+    return &circuitBreaker{
+        name:      name,
+        threshold: threshold,
+        cooldown:  cooldown,
+        state:     breakerClosed,
+        now:       time.Now,
+    }
+}
+
+// Do makes a call to the provider unless the circuit is open, and records how it went
+func (cb *circuitBreaker) Do(ctx context.Context, call func() error) error {
+    if !cb.allow() {
+        return fmt.Errorf("%w: circuit open for %s", ErrClassifierUnavailable, cb.name)
+    }
+
+    if err := call(); err != nil {
+        if ctx.Err() != nil {
+            // The caller went away, that says nothing about the provider
+            cb.releaseTrial()
+            return err
+        }
+        cb.recordFailure()
+        return fmt.Errorf("%w: %v", ErrClassifierUnavailable, err)
+    }
+    cb.recordSuccess()
+    return nil
+}
+
+// CircuitBreakerClassifier puts a backend behind its provider's circuit breaker.
+// A FallbackChainClassifier in front of it decides who answers instead.
+type CircuitBreakerClassifier struct {
+    Classifier
+    *circuitBreaker
+}
+
+func NewCircuitBreakerClassifier(inner Classifier, threshold int, cooldown time.Duration) *CircuitBreakerClassifier {
+    return newBreakerClassifier(inner, newCircuitBreaker(inner.Name(), threshold, cooldown))
+}
+
+func newBreakerClassifier(inner Classifier, breaker *circuitBreaker) *CircuitBreakerClassifier {
+    log.Printf("[BREAKER] Guarding %s: opens after %d failures for %v", inner.Name(), breaker.threshold, breaker.cooldown)
+
+    return &CircuitBreakerClassifier{
+        Classifier:     inner,
+        circuitBreaker: breaker,
+    }
+}
+
+func (cb *CircuitBreakerClassifier) ClassifyQuery(ctx context.Context, customerMessage string) (*models.ClassificationResult, error) {
+    var result *models.ClassificationResult
+    err := cb.Do(ctx, func() error {
+        var callErr error
+        result, callErr = cb.Classifier.ClassifyQuery(ctx, customerMessage)
+        return callErr
+    })
+    if err != nil {
+        return nil, err
+    }
+    return result, nil
+}
+
+// allow reports whether the guarded provider may be called
+func (cb *circuitBreaker) allow() bool {
+    cb.mu.Lock()
+    defer cb.mu.Unlock()
+
+    switch cb.state {
+    case breakerOpen:
+        if cb.now().Sub(cb.openedAt) < cb.cooldown {
+            cb.rejected++
+            return false
+        }
+        cb.state = breakerHalfOpen
+        cb.trialInFlight = true
+        log.Printf("[BREAKER] Half-open, sending a trial request to %s", cb.name)
+        return true
+    case breakerHalfOpen:
+        if cb.trialInFlight {
+            cb.rejected++
+            return false
+        }
+        cb.trialInFlight = true
+        return true
+    default:
+        return true
+    }
+}
+func (cb *circuitBreaker) releaseTrial() {
+    cb.mu.Lock()
+    defer cb.mu.Unlock()
+    cb.trialInFlight = false
+}
+
+func (cb *circuitBreaker) recordSuccess() {
+    cb.mu.Lock()
+    defer cb.mu.Unlock()
+
+    if cb.state != breakerClosed {
+        log.Printf("[BREAKER] %s recovered, closing", cb.name)
+    }
+    cb.state = breakerClosed
+    cb.consecutiveFailures = 0
+    cb.trialInFlight = false
+}
+
+func (cb *circuitBreaker) recordFailure() {
+    cb.mu.Lock()
+    defer cb.mu.Unlock()
+
+    cb.consecutiveFailures++
+    cb.trialInFlight = false
+    if cb.state == breakerHalfOpen || cb.consecutiveFailures >= cb.threshold {
+        if cb.state != breakerOpen {
+            cb.trips++
+            log.Printf("[BREAKER] Opening after %d consecutive failures of %s", cb.consecutiveFailures, cb.name)
+        }
+        cb.state = breakerOpen
+        cb.openedAt = cb.now()
+    }
+}
+
+func (cb *CircuitBreakerClassifier) GetStats() map[string]interface{} {
+    stats := cb.Classifier.GetStats()
+
+    cb.mu.Lock()
+    defer cb.mu.Unlock()
+
+    breaker := map[string]interface{}{
+        "state":                cb.state,
+        "consecutive_failures": cb.consecutiveFailures,
+        "threshold":            cb.threshold,
+        "cooldown":             cb.cooldown.String(),
+        "trips":                cb.trips,
+        "rejected_requests":    cb.rejected,
+    }
+    if cb.state == breakerOpen {
+        breaker["opened_at"] = cb.openedAt.Format(time.RFC3339)
+    }
+    stats["circuit_breaker"] = breaker
+    return stats
+}
\ No newline at end of file
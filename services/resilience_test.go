@@ -0,0 +1,125 @@
+package services
+
+import (
+    "context"
+    "errors"
+    "net/http"
+    "testing"
+    "time"
+
+    openai "github.com/sashabaranov/go-openai"
+)
+
+func TestCircuitBreakerOpensAndRecovers(t *testing.T) {
+    inner := failing("openai", "server error")
+    breaker := NewCircuitBreakerClassifier(inner, 2, time.Minute)
+    now := time.Now()
+    breaker.now = func() time.Time { return now }
+
+    steps := []struct {
+        name    string
+        advance time.Duration
+        healthy bool
+        calls   int // Calls that reached the provider so far
+        state   breakerState
+    }{
+        {"first failure", 0, false, 1, breakerClosed},
+        {"threshold reached", 0, false, 2, breakerOpen},
+        {"open fails fast", 30 * time.Second, false, 2, breakerOpen},
+        {"trial after cooldown fails", 31 * time.Second, false, 3, breakerOpen},
+        {"cooldown restarts", 59 * time.Second, true, 3, breakerOpen},
+        {"trial succeeds", time.Second, true, 4, breakerClosed},
+        {"closed again", 0, true, 5, breakerClosed},
+    }
+
+    for _, step := range steps {
+        now = now.Add(step.advance)
+        inner.err = nil
+        if !step.healthy {
+            inner.err = errors.New("server error")
+        }
+
+        _, err := breaker.ClassifyQuery(context.Background(), "hello")
+        if (err == nil) != (step.healthy && step.state == breakerClosed) {
+            t.Errorf("%s: error %v", step.name, err)
+        }
+        if err != nil && !errors.Is(err, ErrClassifierUnavailable) {
+            t.Errorf("%s: %v is not ErrClassifierUnavailable", step.name, err)
+        }
+        if inner.calls != step.calls || breaker.state != step.state {
+            t.Errorf("%s: %d calls in state %s, want %d in %s", step.name, inner.calls, breaker.state, step.calls, step.state)
+        }
+    }
+    if breaker.trips != 2 {
+        t.Errorf("tripped %d times, want 2", breaker.trips)
+    }
+}
+
+func TestCircuitBreakerIgnoresCallerCancellation(t *testing.T) {
+    inner := failing("openai", "context canceled")
+    breaker := NewCircuitBreakerClassifier(inner, 1, time.Minute)
+    ctx, cancel := context.WithCancel(context.Background())
+    cancel()
+
+    breaker.ClassifyQuery(ctx, "hello")
+    if breaker.state != breakerClosed {
+        t.Errorf("a cancelled caller opened the breaker")
+    }
+}
+
+func TestRetryPolicyRetriesTransientErrors(t *testing.T) {
+    policy := RetryPolicy{MaxRetries: 2, BaseDelay: time.Millisecond}
+
+    tests := []struct {
+        name     string
+        err      error
+        attempts int
+    }{
+        {"rate limited", &openai.APIError{HTTPStatusCode: http.StatusTooManyRequests}, 3},
+        {"server error", &openai.RequestError{HTTPStatusCode: http.StatusBadGateway}, 3},
+        {"timeout", context.DeadlineExceeded, 3},
+        {"bad request", &openai.APIError{HTTPStatusCode: http.StatusBadRequest}, 1},
+        {"unknown error", errors.New("invalid response"), 1},
+        {"success", nil, 1},
+    }
+
+    for _, tt := range tests {
+        attempts := 0
+        err := policy.Do(context.Background(), func(ctx context.Context) error {
+            attempts++
+            return tt.err
+        })
+        if attempts != tt.attempts || !errors.Is(err, tt.err) {
+            t.Errorf("%s: %d attempts returning %v, want %d", tt.name, attempts, err, tt.attempts)
+        }
+    }
+}
+
+func TestRetryPolicyStopsWhenTheCallerIsDone(t *testing.T) {
+    policy := RetryPolicy{MaxRetries: 5, BaseDelay: time.Hour, MaxDelay: time.Hour}
+    ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+    defer cancel()
+
+    attempts := 0
+    start := time.Now()
+    policy.Do(ctx, func(ctx context.Context) error {
+        attempts++
+        return &openai.APIError{HTTPStatusCode: http.StatusServiceUnavailable}
+    })
+    if attempts != 1 || time.Since(start) > time.Second {
+        t.Errorf("%d attempts over %v after the deadline", attempts, time.Since(start))
+    }
+}
+
+func TestRetryBackoffStaysUnderTheCap(t *testing.T) {
+    policy := RetryPolicy{BaseDelay: 100 * time.Millisecond, MaxDelay: time.Second}
+    for attempt := 0; attempt < 10; attempt++ {
+        ceiling := policy.BaseDelay << attempt
+        if ceiling > policy.MaxDelay {
+            ceiling = policy.MaxDelay
+        }
+        if delay := policy.backoff(attempt); delay < 0 || delay >= ceiling {
+            t.Errorf("attempt %d: delay %v outside [0, %v)", attempt, delay, ceiling)
+        }
+    }
+}
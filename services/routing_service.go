@@ -0,0 +1,486 @@
+package services
+
+import (
+    "context"
+    "errors"
+    "fmt"
+    "log"
+
+    "customer-query-router/models"
+)
+
+// ErrHumanTriage marks a message the classifier left to human triage, so no agent is assigned
+var ErrHumanTriage = errors.New("needs human triage")
+
+// RoutingService is the single classify-then-assign pipeline.
+// The intent picked by the classifier is matched against agent specialties,
+// so every decision names an agent that actually exists in AgentService.
+type RoutingService struct {
+    classifier   Classifier
+    agentService *AgentService
+    assignments  *AssignmentService
+    queue        *QueueService // Nil when queries nobody can take are rejected
+    sla          *SLAMonitor
+
+    anyAgentFallback bool               // Give queries to non-specialists when no specialist is free
+    sentiment        *SentimentAnalyzer // Scores queries routed with a known intent, nil for DefaultPriority
+}
+
+func NewRoutingService(classifier Classifier, agentService *AgentService, assignments *AssignmentService, queue *QueueService, sla *SLAMonitor) *RoutingService {
+    if uncovered := agentService.UncoveredIntents(classifier.GetAllIntents()); len(uncovered) > 0 {
+        log.Printf("[ROUTING] WARNING - No agent specialises in %v, these go to any free agent", uncovered)
+    }
+
+    return &RoutingService{
+        classifier:   classifier,
+        agentService: agentService,
+        assignments:  assignments,
+        queue:        queue,
+        sla:          sla,
+    }
+}
+
+// SetAnyAgentFallback lets queries go to agents outside the intent's specialists when no
+// specialist is free. Otherwise they wait for a specialist.
+func (rs *RoutingService) SetAnyAgentFallback(enabled bool) {
+    rs.anyAgentFallback = enabled
+}
+
+// SetSentimentAnalyzer lets the tone and urgency of queries routed with a known intent and
+// no explicit priority raise their priority. Without one they get DefaultPriority.
+func (rs *RoutingService) SetSentimentAnalyzer(analyzer *SentimentAnalyzer) {
+    rs.sentiment = analyzer
+}
+
+// RouteMessage classifies a customer message and assigns it to an available agent.
+// A message with several intents becomes a parent ticket with one linked sub-ticket per
+// intent, as split queries do. VIP customers get one priority level more.
+// Messages the classifier sends to human triage, e.g. with LOW_CONFIDENCE_ROUTE=human_triage,
+// are rejected with ErrHumanTriage instead of being given to any agent.
+func (rs *RoutingService) RouteMessage(ctx context.Context, customerMessage string, vip bool) (*models.RoutingDecision, error) {
+    classification, err := rs.classifier.ClassifyQuery(ctx, customerMessage)
+    if err != nil {
+        return nil, fmt.Errorf("classification failed: %w", err)
+    }
+
+    decision := &models.RoutingDecision{
+        TicketID:       NewTicketID(),
+        Intent:         classification.Intent,
+        Confidence:     classification.Confidence,
+        Priority:       classification.Priority,
+        Sentiment:      classification.Sentiment,
+        Language:       classification.Language,
+        Entities:       classification.Entities,
+        Classification: classification,
+    }
+
+    if vip {
+        decision.Priority = VIPPriority(classification.Priority, true)
+    }
+
+    if classification.Agent == HumanTriageAgent {
+        rs.sla.RecordRejected(decision.Intent)
+        decision.Reason = "left to human triage"
+        if classification.LowConfidence {
+            decision.Reason += fmt.Sprintf(", low confidence in %s", classification.OriginalIntent)
+        }
+        log.Printf("[ROUTING] %s not assigned: %s", decision.TicketID, decision.Reason)
+        return decision, fmt.Errorf("%w: %s", ErrHumanTriage, decision.Reason)
+    }
+
+    criteriaFor := func(intent string) AgentCriteria {
+        return AgentCriteria{
+            Intent:   intent,
+            Priority: decision.Priority,
+            Language: classification.Language,
+        }
+    }
+    if len(classification.Intents) > 1 {
+        return decision, rs.routeSplitMessage(decision, classification, criteriaFor)
+    }
+
+    agent, err := rs.routeTicket(decision, criteriaFor(classification.Intent))
+    if err != nil || agent == nil {
+        return decision, err
+    }
+    if classification.LowConfidence {
+        decision.Reason += fmt.Sprintf("; low confidence in %s", classification.OriginalIntent)
+    }
+    if vip {
+        decision.Reason += fmt.Sprintf("; VIP, priority %d", decision.Priority)
+    } else if classification.Priority > 0 {
+        decision.Reason += "; " + DescribePriority(classification)
+    }
+    if decision.Priority >= HighPriority && agent.Senior {
+        decision.Reason += ", senior agent preferred"
+    }
+
+    log.Printf("[ROUTING] %s -> %s: %s", decision.TicketID, agent.ID, decision.Reason)
+    return decision, nil
+}
+
+// routeSplitMessage routes every intent of a multi-label classification as a sub-ticket of decision
+func (rs *RoutingService) routeSplitMessage(decision *models.RoutingDecision, classification *models.ClassificationResult, criteriaFor func(intent string) AgentCriteria) error {
+    intents := []string{}
+    confidence := make(map[string]float64)
+    for _, scored := range classification.Intents {
+        intents = append(intents, scored.Intent)
+        confidence[scored.Intent] = scored.Score
+    }
+
+    err := rs.routeSplit(decision, intents, criteriaFor)
+    for i := range decision.SubTickets {
+        subTicket := &decision.SubTickets[i]
+        subTicket.Confidence = confidence[subTicket.Intent]
+        if subTicket.Intent == decision.Intent {
+            subTicket.Confidence = decision.Confidence
+        }
+    }
+    log.Printf("[ROUTING] %s %s", decision.TicketID, decision.Reason)
+    return err
+}
+
+// RouteQuery routes a query whose intent the caller already knows, without classifying it,
+// the same way as a classified message. Split queries get a parent ticket with one linked
+// sub-ticket per intent. The returned response is set even when routing fails.
+func (rs *RoutingService) RouteQuery(query models.Query) (*models.RoutingResponse, error) {
+    if query.Intent == "" && len(query.Intents) > 0 {
+        query.Intent = query.Intents[0]
+    }
+    criteriaFor := func(intent string) AgentCriteria {
+        return AgentCriteria{
+            Intent:   intent,
+            Priority: QueryPriority(query, intent, rs.classifier.GetAllIntents(), rs.sentiment),
+            Language: query.Language,
+        }
+    }
+
+    decision := &models.RoutingDecision{TicketID: NewTicketID(), Intent: query.Intent}
+    var err error
+    if query.Split && len(query.Intents) > 0 {
+        err = rs.routeSplit(decision, query.Intents, criteriaFor)
+    } else if agent, routingErr := rs.routeTicket(decision, criteriaFor(query.Intent)); agent != nil {
+        log.Printf("[ROUTING] %s -> %s: %s", decision.TicketID, agent.ID, decision.Reason)
+    } else {
+        err = routingErr
+    }
+    return routingResponse(decision), err
+}
+
+// routeSplit routes every intent as a sub-ticket of parent, its own intent first. The agent of
+// the first assigned sub-ticket owns the parent, which is queued when all of them are.
+// It fails when no sub-ticket was assigned or queued.
+func (rs *RoutingService) routeSplit(parent *models.RoutingDecision, intents []string, criteriaFor func(intent string) AgentCriteria) error {
+    split := []string{parent.Intent}
+    seen := map[string]bool{parent.Intent: true}
+    for _, intent := range intents {
+        if !seen[intent] {
+            seen[intent] = true
+            split = append(split, intent)
+        }
+    }
+
+    assigned, queued := 0, 0
+    for _, intent := range split {
+        subTicket := models.RoutingDecision{TicketID: NewTicketID(), ParentTicketID: parent.TicketID, Intent: intent}
+        agent, err := rs.routeTicket(&subTicket, criteriaFor(intent))
+        switch {
+        case err != nil:
+            subTicket.Error = err.Error()
+        case agent == nil:
+            queued++
+        default:
+            assigned++
+            if parent.AgentID == "" {
+                parent.AgentID, parent.AgentName = agent.ID, agent.Name
+            }
+            log.Printf("[ROUTING] %s -> %s: %s", subTicket.TicketID, agent.ID, subTicket.Reason)
+        }
+        if subTicket.Priority > parent.Priority {
+            parent.Priority = subTicket.Priority
+        }
+        parent.SubTickets = append(parent.SubTickets, subTicket)
+    }
+
+    parent.Reason = fmt.Sprintf("split into %d sub-tickets, %d assigned and %d queued", len(split), assigned, queued)
+    if assigned == 0 && queued == 0 {
+        return fmt.Errorf("no available agent for any intent of %s", parent.TicketID)
+    }
+    if assigned == 0 {
+        parent.Status = QueueQueued
+    }
+    return nil
+}
+
+// routeTicket reserves an agent for a new ticket and opens its assignment, or queues the
+// ticket when nobody can take it and returns no agent. The error says why it was neither.
+func (rs *RoutingService) routeTicket(decision *models.RoutingDecision, criteria AgentCriteria) (*models.Agent, error) {
+    decision.Priority = criteria.Priority
+    decision.Language = criteria.Language
+
+    // Find and reserve in one step, so concurrent requests cannot overbook the agent
+    agent, reason, err := rs.pickAgent(criteria)
+    if err != nil {
+        _, err = rs.enqueue(decision, criteria, err)
+        return nil, err
+    }
+    decision.AssignmentID = rs.assignments.Open(decision.TicketID, decision.ParentTicketID, agent, criteria).ID
+    rs.sla.RecordAssigned(criteria.Intent, 0)
+
+    decision.AgentID = agent.ID
+    decision.AgentName = agent.Name
+    decision.Reason = reason + rs.languageReason(agent, criteria.Language)
+    return agent, nil
+}
+
+// enqueue puts a decision nobody could take in the waiting queue, or returns routingErr
+// when there is no queue or it refuses the query
+func (rs *RoutingService) enqueue(decision *models.RoutingDecision, criteria AgentCriteria, routingErr error) (*models.RoutingDecision, error) {
+    if rs.queue == nil {
+        rs.sla.RecordRejected(criteria.Intent)
+        return decision, routingErr
+    }
+    queued, err := rs.queue.Enqueue(decision.TicketID, decision.ParentTicketID, criteria, rs.allowsAnyAgent(criteria))
+    if err != nil {
+        rs.sla.RecordRejected(criteria.Intent)
+        return decision, fmt.Errorf("%v, not queued: %w", routingErr, err)
+    }
+
+    decision.Status = queued.Status
+    decision.QueuePosition = queued.Position
+    decision.EstimatedWait = queued.EstimatedWait
+    decision.Reason = fmt.Sprintf("%v; queued at position %d", routingErr, queued.Position)
+    return decision, nil
+}
+
+// pickAgent reserves a specialist for the criteria, or any other agent when none is free
+// and that is allowed, and explains the choice
+func (rs *RoutingService) pickAgent(criteria AgentCriteria) (*models.Agent, string, error) {
+    agent, err := rs.agentService.ReserveAgent(criteria)
+    if err == nil {
+        return agent, fmt.Sprintf("%s specialises in %s and has capacity (%d/%d)",
+            agent.Name, criteria.Intent, agent.CurrentLoad, agent.MaxCapacity), nil
+    }
+
+    // No free specialist: let the intent's routing strategy pick among all agents
+    if rs.allowsAnyAgent(criteria) {
+        agent, err = rs.agentService.ReserveAnyAgent(criteria)
+    }
+    if err != nil {
+        if rs.agentService.LanguageRouting() == LanguageRoutingHard && criteria.Language != "" {
+            return nil, "", fmt.Errorf("no available agent for intent %s speaking %s", criteria.Intent, criteria.Language)
+        }
+        return nil, "", fmt.Errorf("no available agent for intent: %s", criteria.Intent)
+    }
+    return agent, fmt.Sprintf("no available specialist for %s, assigned to %s (%d/%d) by %s",
+        criteria.Intent, agent.Name, agent.CurrentLoad, agent.MaxCapacity, rs.agentService.RoutingStrategyFor(criteria.Intent)), nil
+}
+
+// allowsAnyAgent tells whether a query may go to agents outside the intent's specialists:
+// when the fallback is enabled, or when no online specialist could ever take it
+func (rs *RoutingService) allowsAnyAgent(criteria AgentCriteria) bool {
+    return rs.anyAgentFallback || rs.agentService.ServingCapacity(criteria, true) == 0
+}
+
+// languageReason notes whether the agent speaks the customer's language
+func (rs *RoutingService) languageReason(agent *models.Agent, language string) string {
+    if language == "" || rs.agentService.LanguageRouting() == LanguageRoutingOff {
+        return ""
+    }
+    if speaks(agent, language) {
+        return fmt.Sprintf("; speaks %s", language)
+    }
+    return fmt.Sprintf("; no %s-speaking agent free", language)
+}
+
+// RouteConversation checks a classified conversation against the agent currently handling it.
+// The current assignment (assignmentID) names the agent and ticket; conversations routed
+// elsewhere pass agentID and ticketID instead, and new conversations none of them.
+// Every open intent the agent does not specialise in gets its own ticket linked to ticketID,
+// unless an open assignment of that ticket or its sub-tickets already handles it.
+// When the agent covers none of the open intents, for example after the customer switched
+// topic, the current assignment is transferred to the first new one.
+// Intents no free agent can take wait in the queue under their own ticket, and are only
+// unassigned when there is no queue or it refuses them. New tickets count as assigned
+// immediately for the SLA and unassigned intents as rejected; the transferred ticket is not
+// counted again.
+func (rs *RoutingService) RouteConversation(conversation *models.ConversationClassification, assignmentID, agentID, ticketID string) (*models.ConversationRouting, error) {
+    if assignmentID != "" {
+        assignment, err := rs.assignments.Get(assignmentID)
+        if err != nil {
+            return nil, err
+        }
+        if assignment.Status != AssignmentPending && assignment.Status != AssignmentAccepted {
+            return nil, fmt.Errorf("%w: %s is %s", ErrAssignmentState, assignmentID, assignment.Status)
+        }
+        agentID = assignment.AgentID
+        if ticketID == "" {
+            // New intents link to the conversation's ticket, not to another sub-ticket
+            ticketID = assignment.TicketID
+            if assignment.ParentTicketID != "" {
+                ticketID = assignment.ParentTicketID
+            }
+        }
+    }
+
+    var current *models.Agent
+    if agentID != "" {
+        agent, exists := rs.agentService.GetAgent(agentID)
+        if !exists {
+            return nil, fmt.Errorf("unknown agent: %s", agentID)
+        }
+        current = agent
+    }
+
+    // Intents routed or queued by an earlier call for this conversation keep their ticket
+    ticketed := map[string]bool{}
+    if ticketID != "" {
+        ticketed = rs.assignments.OpenIntents(ticketID)
+        if rs.queue != nil {
+            for intent := range rs.queue.WaitingIntents(ticketID) {
+                ticketed[intent] = true
+            }
+        }
+    }
+
+    routing := &models.ConversationRouting{Classification: conversation}
+    covered := false
+    waiting := []AgentCriteria{} // Intents no free agent could take
+    waitingErrs := []error{}
+    for _, intent := range conversation.OpenIntents {
+        if current != nil && (intent == "general" || specialises(current, intent)) {
+            covered = true
+            continue
+        }
+        if ticketed[intent] {
+            continue
+        }
+
+        criteria := AgentCriteria{
+            Intent:   intent,
+            Priority: conversation.Priority,
+            Language: conversation.Language,
+        }
+        if current != nil {
+            criteria.ExcludeAgentID = current.ID // They cannot take it, or it would be covered
+        }
+        agent, reason, err := rs.pickAgent(criteria)
+        if err != nil {
+            waiting = append(waiting, criteria)
+            waitingErrs = append(waitingErrs, err)
+            continue
+        }
+        reason += rs.languageReason(agent, conversation.Language)
+        if shift := shiftTo(conversation, intent); shift != nil {
+            reason += fmt.Sprintf("; %s intent raised at turn %d", shift.Kind, shift.Turn)
+            if shift.Cue != "" {
+                reason += fmt.Sprintf(" (%q)", shift.Cue)
+            }
+        }
+
+        decision := models.RoutingDecision{
+            TicketID:       NewTicketID(),
+            ParentTicketID: ticketID,
+            Intent:         intent,
+            Confidence:     intentConfidence(conversation, intent),
+            AgentID:        agent.ID,
+            AgentName:      agent.Name,
+            Priority:       conversation.Priority,
+            Language:       conversation.Language,
+            Reason:         reason,
+        }
+        decision.AssignmentID = rs.assignments.Open(decision.TicketID, ticketID, agent, criteria).ID
+        if ticketID == "" {
+            ticketID = decision.TicketID // Later intents link to the first ticket
+        }
+        routing.Assignments = append(routing.Assignments, decision)
+        log.Printf("[ROUTING] %s -> %s: %s", decision.TicketID, agent.ID, decision.Reason)
+    }
+
+    if current != nil && len(routing.Assignments) > 0 {
+        routing.Reroute = true
+        if !covered && assignmentID != "" {
+            if err := rs.assignments.TransferTo(assignmentID, routing.Assignments[0].AssignmentID); err != nil {
+                // E.g. the assignment closed meanwhile: give back the agents reserved above
+                for _, decision := range routing.Assignments {
+                    rs.assignments.Abandon(decision.AssignmentID)
+                }
+                return nil, err
+            }
+            routing.TransferredFrom = current.ID
+            log.Printf("[ROUTING] Conversation transferred away from %s", current.ID)
+        }
+    }
+
+    // Recorded only now, so a rolled back re-route counts nothing
+    for _, decision := range routing.Assignments {
+        rs.sla.RecordAssigned(decision.Intent, 0)
+    }
+
+    // Queued last for the same reason; enqueue counts the intents it cannot queue as rejected
+    for i, criteria := range waiting {
+        decision := models.RoutingDecision{
+            TicketID:       NewTicketID(),
+            ParentTicketID: ticketID,
+            Intent:         criteria.Intent,
+            Confidence:     intentConfidence(conversation, criteria.Intent),
+            Priority:       criteria.Priority,
+            Language:       criteria.Language,
+        }
+        if _, err := rs.enqueue(&decision, criteria, waitingErrs[i]); err != nil {
+            log.Printf("[ROUTING] Conversation intent %s unassigned: %v", criteria.Intent, err)
+            routing.Unassigned = append(routing.Unassigned, criteria.Intent)
+            continue
+        }
+        if ticketID == "" {
+            ticketID = decision.TicketID
+        }
+        routing.Queued = append(routing.Queued, decision)
+    }
+    if current != nil && len(routing.Queued) > 0 {
+        routing.Reroute = true
+    }
+    return routing, nil
+}
+
+// routingResponse is the view of a decision returned for queries routed with a known intent
+func routingResponse(decision *models.RoutingDecision) *models.RoutingResponse {
+    response := &models.RoutingResponse{
+        TicketID:       decision.TicketID,
+        ParentTicketID: decision.ParentTicketID,
+        AssignmentID:   decision.AssignmentID,
+        AgentID:        decision.AgentID,
+        Intent:         decision.Intent,
+        Language:       decision.Language,
+        Priority:       decision.Priority,
+        Status:         decision.Status,
+        QueuePosition:  decision.QueuePosition,
+        EstimatedWait:  decision.EstimatedWait,
+        Error:          decision.Error,
+    }
+    for i := range decision.SubTickets {
+        response.SubTickets = append(response.SubTickets, *routingResponse(&decision.SubTickets[i]))
+    }
+    return response
+}
+
+// shiftTo returns the latest shift that raised intent, or nil when the conversation opened with it
+func shiftTo(conversation *models.ConversationClassification, intent string) *models.IntentShift {
+    for i := len(conversation.Shifts) - 1; i >= 0; i-- {
+        if conversation.Shifts[i].To == intent {
+            return &conversation.Shifts[i]
+        }
+    }
+    return nil
+}
+
+// intentConfidence is the highest confidence of any turn classified as intent
+func intentConfidence(conversation *models.ConversationClassification, intent string) float64 {
+    confidence := 0.0
+    for _, turn := range conversation.Turns {
+        if turn.Intent == intent && turn.Confidence > confidence {
+            confidence = turn.Confidence
+        }
+    }
+    return confidence
+}
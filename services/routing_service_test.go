@@ -0,0 +1,301 @@
+package services
+
+import (
+    "context"
+    "errors"
+    "testing"
+
+    "customer-query-router/models"
+)
+
+func TestRouteConversationTwiceOpensNoDuplicateTickets(t *testing.T) {
+    agents := NewAgentService()
+    assignments := NewAssignmentService(agents, 0)
+    routing := NewRoutingService(&scriptedClassifier{name: "rules"}, agents, assignments, nil, testSLAMonitor())
+
+    // Billing went to a billing agent, then the customer also asked to cancel
+    criteria := AgentCriteria{Intent: "billing_discrepancies"}
+    agent, err := agents.ReserveAgent(criteria)
+    if err != nil {
+        t.Fatal(err)
+    }
+    current := assignments.Open("TKT-C1", "", agent, criteria)
+    conversation := &models.ConversationClassification{
+        Intent:      "billing_discrepancies",
+        OpenIntents: []string{"billing_discrepancies", "order_cancellation_requests"},
+    }
+
+    first, err := routing.RouteConversation(conversation, current.ID, "", "")
+    if err != nil {
+        t.Fatal(err)
+    }
+    if len(first.Assignments) != 1 || first.Assignments[0].ParentTicketID != "TKT-C1" {
+        t.Fatalf("first routing opened %+v, want one sub-ticket of TKT-C1", first.Assignments)
+    }
+    open := len(assignments.List(AssignmentPending, ""))
+    loads := make(map[string]int)
+    for _, a := range agents.GetAllAgents() {
+        loads[a.ID] = a.CurrentLoad
+    }
+
+    for _, call := range []struct{ name, assignmentID, agentID, ticketID string }{
+        {"same assignment", current.ID, "", ""},
+        {"same agent and ticket", "", agent.ID, "TKT-C1"},
+        {"from the sub-ticket", first.Assignments[0].AssignmentID, "", ""},
+    } {
+        again, err := routing.RouteConversation(conversation, call.assignmentID, call.agentID, call.ticketID)
+        if err != nil {
+            t.Fatalf("%s: %v", call.name, err)
+        }
+        if len(again.Assignments) != 0 || again.Reroute {
+            t.Errorf("%s: opened %+v again", call.name, again.Assignments)
+        }
+    }
+
+    if n := len(assignments.List(AssignmentPending, "")); n != open {
+        t.Errorf("%d open assignments after routing again, want %d", n, open)
+    }
+    for _, a := range agents.GetAllAgents() {
+        if a.CurrentLoad != loads[a.ID] {
+            t.Errorf("%s load went from %d to %d", a.ID, loads[a.ID], a.CurrentLoad)
+        }
+    }
+}
+
+func TestRouteConversationRecordsSLA(t *testing.T) {
+    agents := testAgentService(
+        &models.Agent{ID: "billing", Specialties: []string{"billing_discrepancies"}, MaxCapacity: 2, IsOnline: true},
+        &models.Agent{ID: "cancellations", Specialties: []string{"order_cancellation_requests"}, MaxCapacity: 1, IsOnline: true},
+    )
+    assignments := NewAssignmentService(agents, 0)
+    sla := testSLAMonitor()
+    routing := NewRoutingService(&scriptedClassifier{name: "rules"}, agents, assignments, nil, sla)
+
+    criteria := AgentCriteria{Intent: "billing_discrepancies"}
+    agent, err := agents.ReserveAgent(criteria)
+    if err != nil {
+        t.Fatal(err)
+    }
+    current := assignments.Open("TKT-C1", "", agent, criteria)
+    // Once the cancellation agent is full, nobody but the current agent could take a delivery problem
+    conversation := &models.ConversationClassification{
+        Intent:      "billing_discrepancies",
+        OpenIntents: []string{"billing_discrepancies", "order_cancellation_requests", "delivery_problems"},
+    }
+
+    for i := 0; i < 2; i++ {
+        if _, err := routing.RouteConversation(conversation, current.ID, "", ""); err != nil {
+            t.Fatal(err)
+        }
+    }
+
+    intents := sla.Stats()["intents"].(map[string]interface{})
+    if len(intents) != 2 {
+        t.Fatalf("SLA counted %v, want the new ticket and the unassigned intent", intents)
+    }
+    if stats := intents["order_cancellation_requests"].(map[string]interface{}); stats["assigned"] != int64(1) {
+        t.Errorf("cancellation counted %v, want one assignment", stats)
+    }
+    // The unassigned intent gets no ticket, so every attempt counts
+    if stats := intents["delivery_problems"].(map[string]interface{}); stats["rejected"] != int64(2) {
+        t.Errorf("delivery problem counted %v, want two rejections", stats)
+    }
+}
+
+func TestRouteQueryFallsBackLikeRoutedMessages(t *testing.T) {
+    tests := []struct {
+        name     string
+        anyAgent bool
+        query    models.Query
+        agentID  string
+        status   string
+    }{
+        {"free specialist", false, models.Query{Intent: "billing_discrepancies"}, "billing", ""},
+        {"busy specialist waits", false, models.Query{Intent: "warranty_terms_inquiries"}, "", QueueQueued},
+        {"busy specialist with the fallback", true, models.Query{Intent: "warranty_terms_inquiries"}, "billing", ""},
+        {"intent nobody specialises in", false, models.Query{Intent: "general"}, "billing", ""},
+    }
+
+    for _, tt := range tests {
+        agents := testAgentService(
+            &models.Agent{ID: "billing", Specialties: []string{"billing_discrepancies"}, MaxCapacity: 2, IsOnline: true},
+            &models.Agent{ID: "warranty", Specialties: []string{"warranty_terms_inquiries"}, MaxCapacity: 1, CurrentLoad: 1, IsOnline: true},
+        )
+        assignments := NewAssignmentService(agents, 0)
+        queue := NewQueueService(agents, assignments, testSLAMonitor(), 5, 0)
+        routing := NewRoutingService(&scriptedClassifier{name: "rules"}, agents, assignments, queue, testSLAMonitor())
+        routing.SetAnyAgentFallback(tt.anyAgent)
+
+        response, err := routing.RouteQuery(tt.query)
+        if err != nil {
+            t.Fatalf("%s: %v", tt.name, err)
+        }
+        if response.AgentID != tt.agentID || response.Status != tt.status {
+            t.Errorf("%s: agent %q, status %q, want %q, %q", tt.name, response.AgentID, response.Status, tt.agentID, tt.status)
+        }
+        if (response.AssignmentID != "") != (tt.agentID != "") {
+            t.Errorf("%s: assignment %q for agent %q", tt.name, response.AssignmentID, response.AgentID)
+        }
+    }
+}
+
+func TestRouteQuerySplitsIntoSubTickets(t *testing.T) {
+    agents := testAgentService(
+        &models.Agent{ID: "billing", Specialties: []string{"billing_discrepancies"}, MaxCapacity: 2, IsOnline: true},
+        &models.Agent{ID: "warranty", Specialties: []string{"warranty_terms_inquiries"}, MaxCapacity: 1, CurrentLoad: 1, IsOnline: true},
+    )
+    assignments := NewAssignmentService(agents, 0)
+    queue := NewQueueService(agents, assignments, testSLAMonitor(), 5, 0)
+    routing := NewRoutingService(&scriptedClassifier{name: "rules"}, agents, assignments, queue, testSLAMonitor())
+
+    response, err := routing.RouteQuery(models.Query{
+        Intents: []string{"warranty_terms_inquiries", "billing_discrepancies", "warranty_terms_inquiries"},
+        Split:   true,
+    })
+    if err != nil {
+        t.Fatal(err)
+    }
+
+    if response.Intent != "warranty_terms_inquiries" || len(response.SubTickets) != 2 {
+        t.Fatalf("parent %+v, want warranty with two sub-tickets", response)
+    }
+    warranty, billing := response.SubTickets[0], response.SubTickets[1]
+    if warranty.Status != QueueQueued || warranty.ParentTicketID != response.TicketID {
+        t.Errorf("warranty sub-ticket %+v, want it queued under %s", warranty, response.TicketID)
+    }
+    if billing.AgentID != "billing" || billing.ParentTicketID != response.TicketID {
+        t.Errorf("billing sub-ticket %+v, want it assigned under %s", billing, response.TicketID)
+    }
+    if response.AgentID != "billing" || response.Status != "" {
+        t.Errorf("parent agent %q, status %q, want billing owning it", response.AgentID, response.Status)
+    }
+}
+
+func TestRouteConversationQueuesIntentsNobodyCanTake(t *testing.T) {
+    agents := testAgentService(
+        &models.Agent{ID: "billing", Specialties: []string{"billing_discrepancies"}, MaxCapacity: 2, IsOnline: true},
+        &models.Agent{ID: "warranty", Specialties: []string{"warranty_terms_inquiries"}, MaxCapacity: 1, CurrentLoad: 1, IsOnline: true},
+    )
+    assignments := NewAssignmentService(agents, 0)
+    sla := testSLAMonitor()
+    queue := NewQueueService(agents, assignments, sla, 5, 0)
+    routing := NewRoutingService(&scriptedClassifier{name: "rules"}, agents, assignments, queue, sla)
+
+    criteria := AgentCriteria{Intent: "billing_discrepancies"}
+    agent, err := agents.ReserveAgent(criteria)
+    if err != nil {
+        t.Fatal(err)
+    }
+    current := assignments.Open("TKT-C1", "", agent, criteria)
+    conversation := &models.ConversationClassification{
+        Intent:      "billing_discrepancies",
+        OpenIntents: []string{"billing_discrepancies", "warranty_terms_inquiries"},
+    }
+
+    for i := 0; i < 2; i++ {
+        routed, err := routing.RouteConversation(conversation, current.ID, "", "")
+        if err != nil {
+            t.Fatal(err)
+        }
+        if len(routed.Assignments) != 0 || len(routed.Unassigned) != 0 {
+            t.Errorf("call %d: assigned %+v, unassigned %v, want only the queue", i, routed.Assignments, routed.Unassigned)
+        }
+        if i == 0 && (len(routed.Queued) != 1 || routed.Queued[0].ParentTicketID != "TKT-C1" || !routed.Reroute) {
+            t.Errorf("first call queued %+v, want the warranty question under TKT-C1", routed.Queued)
+        }
+        if i == 1 && len(routed.Queued) != 0 {
+            t.Errorf("second call queued %+v again", routed.Queued)
+        }
+    }
+
+    waiting := queue.List("warranty_terms_inquiries")
+    if len(waiting) != 1 {
+        t.Fatalf("%d warranty queries wait, want 1", len(waiting))
+    }
+    if _, err := assignments.Get(current.ID); err != nil || assignments.OpenIntents("TKT-C1")["billing_discrepancies"] != true {
+        t.Errorf("the billing assignment was not kept: %v", err)
+    }
+    if stats := sla.Stats()["intents"].(map[string]interface{}); len(stats) != 0 {
+        t.Errorf("SLA counted %v, want nothing until the query is dispatched", stats)
+    }
+}
+
+func TestRouteMessageOpensASubTicketPerIntent(t *testing.T) {
+    agents := testAgentService(
+        &models.Agent{ID: "billing", Specialties: []string{"billing_discrepancies"}, MaxCapacity: 2, IsOnline: true},
+        &models.Agent{ID: "logistics", Specialties: []string{"delivery_problems"}, MaxCapacity: 2, IsOnline: true},
+    )
+    classifier := &resultClassifier{
+        scriptedClassifier: scriptedClassifier{name: "rules"},
+        result: models.ClassificationResult{
+            Intent:     "billing_discrepancies",
+            Confidence: 0.9,
+            Intents: []models.IntentScore{
+                {Intent: "billing_discrepancies", Score: 0.9},
+                {Intent: "delivery_problems", Score: 0.6},
+            },
+        },
+    }
+    assignments := NewAssignmentService(agents, 0)
+    routing := NewRoutingService(classifier, agents, assignments, nil, testSLAMonitor())
+
+    decision, err := routing.RouteMessage(context.Background(), "I was double charged and the package never arrived", false)
+    if err != nil {
+        t.Fatal(err)
+    }
+
+    if len(decision.SubTickets) != 2 || decision.AgentID != "billing" || decision.AssignmentID != "" {
+        t.Fatalf("decision %+v, want a parent owned by billing with two sub-tickets", decision)
+    }
+    for i, want := range []struct {
+        intent, agentID string
+        confidence      float64
+    }{
+        {"billing_discrepancies", "billing", 0.9},
+        {"delivery_problems", "logistics", 0.6},
+    } {
+        subTicket := decision.SubTickets[i]
+        if subTicket.Intent != want.intent || subTicket.AgentID != want.agentID || subTicket.Confidence != want.confidence {
+            t.Errorf("sub-ticket %d is %s for %s at %.1f, want %s for %s at %.1f", i, subTicket.Intent, subTicket.AgentID,
+                subTicket.Confidence, want.intent, want.agentID, want.confidence)
+        }
+        if subTicket.ParentTicketID != decision.TicketID || subTicket.AssignmentID == "" {
+            t.Errorf("sub-ticket %d: parent %q, assignment %q", i, subTicket.ParentTicketID, subTicket.AssignmentID)
+        }
+    }
+    if n := len(assignments.List(AssignmentPending, "")); n != 2 {
+        t.Errorf("%d assignments opened, want 2", n)
+    }
+}
+
+func TestRouteMessageLeavesHumanTriageUnassigned(t *testing.T) {
+    agents := testAgentService(&models.Agent{ID: "general", Specialties: []string{"general"}, MaxCapacity: 2, IsOnline: true})
+    sla := testSLAMonitor()
+
+    for _, tt := range []struct {
+        name   string
+        agent  string
+        triage bool
+    }{
+        {"low confidence to general", "general-agent", false},
+        {"low confidence to human triage", HumanTriageAgent, true},
+    } {
+        classifier := &resultClassifier{
+            scriptedClassifier: scriptedClassifier{name: "rules"},
+            result: models.ClassificationResult{Intent: "general", Agent: tt.agent, LowConfidence: true, OriginalIntent: "delivery_problems"},
+        }
+        routing := NewRoutingService(classifier, agents, NewAssignmentService(agents, 0), nil, sla)
+
+        decision, err := routing.RouteMessage(context.Background(), "where is it", false)
+        if tt.triage != errors.Is(err, ErrHumanTriage) {
+            t.Errorf("%s: error %v", tt.name, err)
+        }
+        if assigned := decision.AssignmentID != ""; assigned == tt.triage {
+            t.Errorf("%s: assignment %q", tt.name, decision.AssignmentID)
+        }
+    }
+
+    if stats := sla.Stats()["intents"].(map[string]interface{})["general"].(map[string]interface{}); stats["assigned"] != int64(1) || stats["rejected"] != int64(1) {
+        t.Errorf("SLA counted %v, want one assignment and one rejection", stats)
+    }
+}
@@ -0,0 +1,236 @@
+package services
+
+import (
+    "fmt"
+    "math/rand"
+    "sort"
+    "strings"
+    "sync"
+    "time"
+
+    "customer-query-router/models"
+)
+
+// Routing strategy names
+const (
+    StrategyLeastUtilization = "least_utilization"
+    StrategyRoundRobin       = "round_robin"
+    StrategyWeightedRandom   = "weighted_random"
+    StrategySkillMatch       = "skill_match"
+
+    // DefaultSkillLevel is assumed for a specialty without an explicit skill level
+    DefaultSkillLevel = 3
+)
+
+// RoutingStrategy picks one agent from the candidates that can take the work. Candidates
+// are never empty and always sorted by ID, so ties are broken by the lowest ID.
+// Pick is called once per reservation and may advance the strategy's state; Peek answers
+// lookups and must leave that state alone.
+type RoutingStrategy interface {
+    Name() string
+    Pick(candidates []*models.Agent, criteria AgentCriteria) *models.Agent
+    Peek(candidates []*models.Agent, criteria AgentCriteria) *models.Agent
+}
+
+// NewRoutingStrategy builds a strategy by name. seed drives weighted_random; 0 seeds from the clock.
+func NewRoutingStrategy(name string, seed int64) (RoutingStrategy, error) {
+    switch name {
+    case "", StrategyLeastUtilization:
+        return LeastUtilizationStrategy{}, nil
+    case StrategyRoundRobin:
+        return NewRoundRobinStrategy(), nil
+    case StrategyWeightedRandom:
+        if seed == 0 {
+            seed = time.Now().UnixNano()
+        }
+        return NewWeightedRandomStrategy(rand.NewSource(seed)), nil
+    case StrategySkillMatch:
+        return SkillMatchStrategy{}, nil
+    default:
+        return nil, fmt.Errorf("unknown routing strategy %q (want %s, %s, %s or %s)", name,
+            StrategyLeastUtilization, StrategyRoundRobin, StrategyWeightedRandom, StrategySkillMatch)
+    }
+}
+
+// ParseStrategyOverrides reads per-intent strategies such as
+// "billing_discrepancies:skill_match,delivery_problems:round_robin"
+func ParseStrategyOverrides(spec string) (map[string]string, error) {
+    overrides := make(map[string]string)
+    for _, item := range strings.Split(spec, ",") {
+        item = strings.TrimSpace(item)
+        if item == "" {
+            continue
+        }
+        intent, strategy, ok := strings.Cut(item, ":")
+        intent, strategy = strings.TrimSpace(intent), strings.TrimSpace(strategy)
+        if !ok || intent == "" || strategy == "" {
+            return nil, fmt.Errorf("invalid strategy override %q, want intent:strategy", item)
+        }
+        overrides[intent] = strategy
+    }
+    return overrides, nil
+}
+
+// ValidateStrategyOverrides checks that every overridden intent is in the taxonomy, so a
+// misspelt intent fails at startup instead of silently never matching
+func ValidateStrategyOverrides(overrides map[string]string, taxonomy *Taxonomy) error {
+    intents := taxonomy.AllIntents()
+    var unknown []string
+    for intent := range overrides {
+        if !isKnownIntent(intents, intent) {
+            unknown = append(unknown, intent)
+        }
+    }
+    if len(unknown) > 0 {
+        sort.Strings(unknown)
+        return fmt.Errorf("unknown intents in strategy overrides: %s", strings.Join(unknown, ", "))
+    }
+    return nil
+}
+
+func utilization(agent *models.Agent) float64 {
+    return float64(agent.CurrentLoad) / float64(agent.MaxCapacity)
+}
+
+// LeastUtilizationStrategy picks the agent with the lowest share of their capacity in use
+type LeastUtilizationStrategy struct{}
+
+func (LeastUtilizationStrategy) Name() string {
+    return StrategyLeastUtilization
+}
+
+func (LeastUtilizationStrategy) Pick(candidates []*models.Agent, criteria AgentCriteria) *models.Agent {
+    best := candidates[0]
+    for _, agent := range candidates[1:] {
+        if utilization(agent) < utilization(best) {
+            best = agent
+        }
+    }
+    return best
+}
+
+func (lu LeastUtilizationStrategy) Peek(candidates []*models.Agent, criteria AgentCriteria) *models.Agent {
+    return lu.Pick(candidates, criteria)
+}
+
+// RoundRobinStrategy takes turns in ID order, separately for every intent. It continues
+// after the agent picked last, so agents joining or leaving do not reset the rotation.
+type RoundRobinStrategy struct {
+    mu   sync.Mutex
+    last map[string]string // Intent -> ID of the agent picked last
+}
+
+func NewRoundRobinStrategy() *RoundRobinStrategy {
+    return &RoundRobinStrategy{last: make(map[string]string)}
+}
+
+func (rr *RoundRobinStrategy) Name() string {
+    return StrategyRoundRobin
+}
+
+func (rr *RoundRobinStrategy) Pick(candidates []*models.Agent, criteria AgentCriteria) *models.Agent {
+    rr.mu.Lock()
+    defer rr.mu.Unlock()
+
+    next := rr.next(candidates, criteria.Intent)
+    rr.last[criteria.Intent] = next.ID
+    return next
+}
+
+// Peek returns whose turn it is without taking it
+func (rr *RoundRobinStrategy) Peek(candidates []*models.Agent, criteria AgentCriteria) *models.Agent {
+    rr.mu.Lock()
+    defer rr.mu.Unlock()
+
+    return rr.next(candidates, criteria.Intent)
+}
+
+// next is the first candidate after the one picked last for intent. Callers hold mu.
+func (rr *RoundRobinStrategy) next(candidates []*models.Agent, intent string) *models.Agent {
+    for _, agent := range candidates {
+        if agent.ID > rr.last[intent] {
+            return agent
+        }
+    }
+    return candidates[0]
+}
+
+// WeightedRandomStrategy picks at random, weighted by spare capacity, so busy agents
+// still get work but less of it. The same seed gives the same sequence of picks.
+type WeightedRandomStrategy struct {
+    mu  sync.Mutex
+    rng *rand.Rand
+}
+
+func NewWeightedRandomStrategy(source rand.Source) *WeightedRandomStrategy {
+    return &WeightedRandomStrategy{rng: rand.New(source)}
+}
+
+func (wr *WeightedRandomStrategy) Name() string {
+    return StrategyWeightedRandom
+}
+
+func (wr *WeightedRandomStrategy) Pick(candidates []*models.Agent, criteria AgentCriteria) *models.Agent {
+    total := 0
+    for _, agent := range candidates {
+        total += agent.MaxCapacity - agent.CurrentLoad
+    }
+
+    wr.mu.Lock()
+    roll := wr.rng.Intn(total)
+    wr.mu.Unlock()
+
+    for _, agent := range candidates {
+        roll -= agent.MaxCapacity - agent.CurrentLoad
+        if roll < 0 {
+            return agent
+        }
+    }
+    return candidates[len(candidates)-1]
+}
+
+// Peek returns the most likely pick, the agent with the most spare capacity, without
+// drawing from the random source
+func (wr *WeightedRandomStrategy) Peek(candidates []*models.Agent, criteria AgentCriteria) *models.Agent {
+    best := candidates[0]
+    for _, agent := range candidates[1:] {
+        if agent.MaxCapacity-agent.CurrentLoad > best.MaxCapacity-best.CurrentLoad {
+            best = agent
+        }
+    }
+    return best
+}
+
+// SkillMatchStrategy picks the agent with the highest skill level for the intent,
+// then the least utilized
+type SkillMatchStrategy struct{}
+
+func (SkillMatchStrategy) Name() string {
+    return StrategySkillMatch
+}
+
+func (SkillMatchStrategy) Pick(candidates []*models.Agent, criteria AgentCriteria) *models.Agent {
+    best := candidates[0]
+    for _, agent := range candidates[1:] {
+        skill, bestSkill := skillLevel(agent, criteria.Intent), skillLevel(best, criteria.Intent)
+        if skill > bestSkill || skill == bestSkill && utilization(agent) < utilization(best) {
+            best = agent
+        }
+    }
+    return best
+}
+
+func (sm SkillMatchStrategy) Peek(candidates []*models.Agent, criteria AgentCriteria) *models.Agent {
+    return sm.Pick(candidates, criteria)
+}
+
+// skillLevel is the agent's proficiency for intent from 0 (none) to 5
+func skillLevel(agent *models.Agent, intent string) int {
+    if level, ok := agent.SkillLevels[intent]; ok {
+        return level
+    }
+    if specialises(agent, intent) {
+        return DefaultSkillLevel
+    }
+    return 0
+}
@@ -0,0 +1,163 @@
+package services
+
+import (
+    "math/rand"
+    "strings"
+    "testing"
+
+    "customer-query-router/models"
+)
+
+func strategyCandidates() []*models.Agent {
+    return []*models.Agent{
+        {ID: "a", MaxCapacity: 4, CurrentLoad: 2, SkillLevels: map[string]int{"billing": 3}},
+        {ID: "b", MaxCapacity: 2, CurrentLoad: 1, SkillLevels: map[string]int{"billing": 5}},
+        {ID: "c", MaxCapacity: 5, CurrentLoad: 0, Specialties: []string{"billing"}},
+    }
+}
+
+func TestLeastUtilizationBreaksTiesByID(t *testing.T) {
+    candidates := strategyCandidates()
+    candidates[2].CurrentLoad = 5 // a and b are both half full
+
+    if got := (LeastUtilizationStrategy{}).Pick(candidates, AgentCriteria{}); got.ID != "a" {
+        t.Errorf("picked %s, want a", got.ID)
+    }
+}
+
+func TestRoundRobinRotatesPerIntent(t *testing.T) {
+    rr := NewRoundRobinStrategy()
+    candidates := strategyCandidates()
+
+    var got []string
+    for i := 0; i < 4; i++ {
+        got = append(got, rr.Pick(candidates, AgentCriteria{Intent: "billing"}).ID)
+    }
+    if want := "abca"; strings.Join(got, "") != want {
+        t.Errorf("billing rotation %s, want %s", strings.Join(got, ""), want)
+    }
+    if first := rr.Pick(candidates, AgentCriteria{Intent: "refunds"}).ID; first != "a" {
+        t.Errorf("refunds started at %s, want its own rotation from a", first)
+    }
+    // b leaving does not reset the rotation, which continues after a
+    if next := rr.Pick([]*models.Agent{candidates[0], candidates[2]}, AgentCriteria{Intent: "billing"}).ID; next != "c" {
+        t.Errorf("picked %s after a with b gone, want c", next)
+    }
+}
+
+func TestWeightedRandomIsRepeatableAndWeighted(t *testing.T) {
+    first := NewWeightedRandomStrategy(rand.NewSource(42))
+    second := NewWeightedRandomStrategy(rand.NewSource(42))
+    candidates := strategyCandidates()
+
+    counts := make(map[string]int)
+    for i := 0; i < 800; i++ {
+        picked := first.Pick(candidates, AgentCriteria{})
+        if again := second.Pick(candidates, AgentCriteria{}); again.ID != picked.ID {
+            t.Fatalf("pick %d: %s and %s with the same seed", i, picked.ID, again.ID)
+        }
+        counts[picked.ID]++
+    }
+    // Spare capacity is 2, 1 and 5 out of 8
+    if !(counts["c"] > counts["a"] && counts["a"] > counts["b"] && counts["b"] > 0) {
+        t.Errorf("picks %v do not follow spare capacity", counts)
+    }
+}
+
+func TestSkillMatchPrefersHighestSkill(t *testing.T) {
+    candidates := strategyCandidates()
+    if got := (SkillMatchStrategy{}).Pick(candidates, AgentCriteria{Intent: "billing"}); got.ID != "b" {
+        t.Errorf("picked %s, want b with skill 5", got.ID)
+    }
+
+    // Without skill levels, specialists rank at DefaultSkillLevel and the least utilized wins
+    candidates[1].SkillLevels = nil
+    if got := (SkillMatchStrategy{}).Pick(candidates, AgentCriteria{Intent: "billing"}); got.ID != "c" {
+        t.Errorf("picked %s, want c", got.ID)
+    }
+}
+
+func TestPeekLeavesStrategyStateAlone(t *testing.T) {
+    candidates := strategyCandidates()
+    criteria := AgentCriteria{Intent: "billing"}
+
+    rr := NewRoundRobinStrategy()
+    rr.Pick(candidates, criteria)
+    for i := 0; i < 3; i++ {
+        if peeked := rr.Peek(candidates, criteria).ID; peeked != "b" {
+            t.Fatalf("peek %d returned %s, want b after a", i, peeked)
+        }
+    }
+    if picked := rr.Pick(candidates, criteria).ID; picked != "b" {
+        t.Errorf("picked %s after peeking, want b", picked)
+    }
+
+    peeking := NewWeightedRandomStrategy(rand.NewSource(7))
+    reference := NewWeightedRandomStrategy(rand.NewSource(7))
+    if peeked := peeking.Peek(candidates, criteria).ID; peeked != "c" {
+        t.Errorf("weighted peek %s, want c with the most spare capacity", peeked)
+    }
+    for i := 0; i < 20; i++ {
+        if got, want := peeking.Pick(candidates, criteria).ID, reference.Pick(candidates, criteria).ID; got != want {
+            t.Fatalf("pick %d: %s after peeking, %s without", i, got, want)
+        }
+    }
+}
+
+func TestLookupsDoNotAdvanceTheRotation(t *testing.T) {
+    as := testAgentService(
+        &models.Agent{ID: "a", Specialties: []string{"billing_discrepancies"}, MaxCapacity: 1, IsOnline: true},
+        &models.Agent{ID: "b", Specialties: []string{"billing_discrepancies"}, MaxCapacity: 1, IsOnline: true},
+        &models.Agent{ID: "c", Specialties: []string{"delivery_problems"}, MaxCapacity: 2, IsOnline: true},
+    )
+    as.SetRoutingStrategy(NewRoundRobinStrategy())
+    criteria := AgentCriteria{Intent: "billing_discrepancies"}
+
+    for i := 0; i < 3; i++ {
+        if _, err := as.FindAvailableAgent(criteria); err != nil {
+            t.Fatal(err)
+        }
+        if _, err := as.FindAnyAvailableAgent(criteria); err != nil {
+            t.Fatal(err)
+        }
+    }
+
+    var got []string
+    for i := 0; i < 2; i++ {
+        agent, err := as.ReserveAgent(criteria)
+        if err != nil {
+            t.Fatal(err)
+        }
+        got = append(got, agent.ID)
+    }
+    // Both specialists are full: the failed specialist reservation picks nobody,
+    // so the fallback takes the first turn after b
+    if _, err := as.ReserveAgent(criteria); err == nil {
+        t.Fatal("reserved a third specialist")
+    }
+    agent, err := as.ReserveAnyAgent(criteria)
+    if err != nil {
+        t.Fatal(err)
+    }
+    got = append(got, agent.ID)
+
+    if strings.Join(got, "") != "abc" {
+        t.Errorf("reserved %s, want abc", strings.Join(got, ""))
+    }
+}
+
+func TestValidateStrategyOverridesRejectsUnknownIntents(t *testing.T) {
+    overrides, err := ParseStrategyOverrides("billing_discrepancies:skill_match, biling_discrepancies:round_robin")
+    if err != nil {
+        t.Fatal(err)
+    }
+    err = ValidateStrategyOverrides(overrides, DefaultTaxonomy())
+    if err == nil || !strings.Contains(err.Error(), "biling_discrepancies") {
+        t.Errorf("got %v, want the misspelt intent reported", err)
+    }
+
+    delete(overrides, "biling_discrepancies")
+    if err := ValidateStrategyOverrides(overrides, DefaultTaxonomy()); err != nil {
+        t.Errorf("rejected a valid override: %v", err)
+    }
+}
@@ -0,0 +1,232 @@
+package services
+
+import (
+    "context"
+    "encoding/json"
+    "fmt"
+    "log"
+    "os"
+    "regexp"
+    "sync/atomic"
+
+    "customer-query-router/models"
+)
+
+// Rule scores an intent when its pattern matches the customer message.
+// Type is one of "keyword" (whole word), "phrase" (substring) or "regex".
+type Rule struct {
+    Intent  string  `json:"intent"`
+    Type    string  `json:"type"`
+    Pattern string  `json:"pattern"`
+    Weight  float64 `json:"weight"`
+}
+
+type compiledRule struct {
+    Rule
+    re *regexp.Regexp
+}
+
+// RuleClassifier is a deterministic keyword/regex classifier that needs no network access
+type RuleClassifier struct {
+    taxonomy     *Taxonomy
+    rules        []compiledRule
+    requestCount int64
+    matchCount   int64
+}
+
+func NewRuleClassifier(rules []Rule, taxonomy *Taxonomy) (*RuleClassifier, error) {
+    intents := taxonomy.AllIntents()
+    compiled := make([]compiledRule, 0, len(rules))
+
+    for i, rule := range rules {
+        if !isKnownIntent(intents, rule.Intent) {
+            return nil, fmt.Errorf("rule %d: unknown intent %q", i, rule.Intent)
+        }
+        if rule.Weight <= 0 {
+            rule.Weight = 1
+        }
+
+        var pattern string
+        switch rule.Type {
+        case "keyword", "":
+            rule.Type = "keyword"
+            pattern = `(?i)\b` + regexp.QuoteMeta(rule.Pattern) + `\b`
+        case "phrase":
+            pattern = `(?i)` + regexp.QuoteMeta(rule.Pattern)
+        case "regex":
+            pattern = `(?i)` + rule.Pattern
+        default:
+            return nil, fmt.Errorf("rule %d: unknown rule type %q", i, rule.Type)
+        }
+
+        re, err := regexp.Compile(pattern)
+        if err != nil {
+            return nil, fmt.Errorf("rule %d: invalid pattern %q: %w", i, rule.Pattern, err)
+        }
+        compiled = append(compiled, compiledRule{Rule: rule, re: re})
+    }
+
+    log.Printf("[RULE CLASSIFIER] Initialized with %d rules", len(compiled))
+
+    return &RuleClassifier{
+        taxonomy: taxonomy,
+        rules:    compiled,
+    }, nil
+}
+
+// LoadRules reads a JSON array of rules from disk
+func LoadRules(filename string) ([]Rule, error) {
+    data, err := os.ReadFile(filename)
+    if err != nil {
+        return nil, fmt.Errorf("error reading rules file: %w", err)
+    }
+
+    var rules []Rule
+    if err := json.Unmarshal(data, &rules); err != nil {
+        return nil, fmt.Errorf("error parsing rules file: %w", err)
+    }
+    return rules, nil
+}
+
+func (rc *RuleClassifier) ClassifyQuery(ctx context.Context, customerMessage string) (*models.ClassificationResult, error) {
+    atomic.AddInt64(&rc.requestCount, 1)
+
+    intents := rc.taxonomy.Intents()
+    scores := rc.score(customerMessage, intents)
+
+    totalScore := 0.0
+    for _, score := range scores {
+        totalScore += score
+    }
+    if totalScore == 0 {
+        return &models.ClassificationResult{
+            Intent:     "general",
+            Agent:      agentForIntent(intents, "general"),
+            Confidence: 0,
+            Backend:    rc.Name(),
+        }, nil
+    }
+    atomic.AddInt64(&rc.matchCount, 1)
+
+    // Normalize so each intent's share of the total weight reads as a confidence
+    for intent := range scores {
+        scores[intent] /= totalScore
+    }
+    ranked := rankIntents(intents, scores)
+
+    return &models.ClassificationResult{
+        Intent:       ranked[0].Intent,
+        Agent:        agentForIntent(intents, ranked[0].Intent),
+        Confidence:   ranked[0].Score,
+        Backend:      rc.Name(),
+        Alternatives: ranked[1:],
+    }, nil
+}
+
+// score sums the weights of all matching rules per intent.
+// Rules for intents that are disabled in the taxonomy are skipped.
+func (rc *RuleClassifier) score(customerMessage string, intents []Intent) map[string]float64 {
+    scores := make(map[string]float64)
+    for _, rule := range rc.rules {
+        if isKnownIntent(intents, rule.Intent) && rule.re.MatchString(customerMessage) {
+            scores[rule.Intent] += rule.Weight
+        }
+    }
+    return scores
+}
+
+// UncoveredIntents lists intents without any rule, which this backend can never predict
+func (rc *RuleClassifier) UncoveredIntents(intents []Intent) []string {
+    uncovered := []string{}
+    for _, intent := range intents {
+        covered := intent.Name == "general" // The fallback needs no rule
+        for _, rule := range rc.rules {
+            if rule.Intent == intent.Name {
+                covered = true
+            }
+        }
+        if !covered {
+            uncovered = append(uncovered, intent.Name)
+        }
+    }
+    return uncovered
+}
+
+func (rc *RuleClassifier) GetAllIntents() []Intent {
+    return rc.taxonomy.Intents()
+}
+
+func (rc *RuleClassifier) GetStats() map[string]interface{} {
+    matchCount := atomic.LoadInt64(&rc.matchCount)
+    requestCount := atomic.LoadInt64(&rc.requestCount)
+    return map[string]interface{}{
+        "backend":        rc.Name(),
+        "total_requests": requestCount,
+        "matched":        matchCount,
+        "fallbacks":      requestCount - matchCount,
+        "rules":          len(rc.rules),
+    }
+}
+
+func (rc *RuleClassifier) Name() string {
+    return "rules"
+}
+
+// DefaultRules is the built-in rule set, tuned on the BrownBox transcripts
+func DefaultRules() []Rule {
+    return []Rule{
+        {"account_access_issues", "keyword", "log in", 2},
+        {"account_access_issues", "keyword", "login", 2},
+        {"account_access_issues", "keyword", "password", 2},
+        {"account_access_issues", "keyword", "account", 1},
+        {"account_access_issues", "keyword", "verification", 1},
+        {"account_access_issues", "regex", `locked out|can'?t (sign|log) ?in|unable to (sign|log) ?in`, 3},
+
+        {"billing_discrepancies", "keyword", "charged", 2},
+        {"billing_discrepancies", "keyword", "bill", 1},
+        {"billing_discrepancies", "keyword", "invoice", 2},
+        {"billing_discrepancies", "keyword", "payment", 1},
+        {"billing_discrepancies", "regex", `(double|twice|extra|over)[ -]?charged|charged (twice|two times)`, 3},
+
+        {"delivery_problems", "keyword", "delivery", 2},
+        {"delivery_problems", "keyword", "package", 1},
+        {"delivery_problems", "keyword", "courier", 1},
+        {"delivery_problems", "phrase", "not received", 2},
+        {"delivery_problems", "regex", `(never|hasn'?t|haven'?t|didn'?t) (arrived?|received?)|wrong address|damaged in transit`, 3},
+
+        {"installation_support_requests", "keyword", "install", 2},
+        {"installation_support_requests", "keyword", "installation", 3},
+        {"installation_support_requests", "keyword", "set up", 2},
+        {"installation_support_requests", "keyword", "setup", 2},
+        {"installation_support_requests", "keyword", "technician", 2},
+
+        {"order_cancellation_requests", "keyword", "cancel", 3},
+        {"order_cancellation_requests", "keyword", "cancellation", 3},
+        {"order_cancellation_requests", "phrase", "cancel button", 2},
+
+        {"order_status_uncertainty", "keyword", "status", 2},
+        {"order_status_uncertainty", "keyword", "track", 1},
+        {"order_status_uncertainty", "keyword", "tracking", 1},
+        {"order_status_uncertainty", "regex", `where is my order|when will (it|my order) (arrive|be delivered|ship)`, 3},
+
+        {"product_availability_inquiries", "keyword", "in stock", 3},
+        {"product_availability_inquiries", "keyword", "out of stock", 3},
+        {"product_availability_inquiries", "keyword", "available", 2},
+        {"product_availability_inquiries", "keyword", "availability", 3},
+        {"product_availability_inquiries", "keyword", "restock", 3},
+
+        {"refund_processing_issues", "keyword", "refund", 3},
+        {"refund_processing_issues", "keyword", "reimbursement", 2},
+        {"refund_processing_issues", "regex", `money back|haven'?t (got|received) (my|the) (refund|money)`, 2},
+
+        {"return_process_inquiries", "keyword", "return", 3},
+        {"return_process_inquiries", "keyword", "exchange", 2},
+        {"return_process_inquiries", "keyword", "ship it back", 2},
+        {"return_process_inquiries", "keyword", "replacement", 1},
+
+        {"warranty_terms_inquiries", "keyword", "warranty", 3},
+        {"warranty_terms_inquiries", "keyword", "guarantee", 2},
+        {"warranty_terms_inquiries", "keyword", "coverage", 1},
+        {"warranty_terms_inquiries", "keyword", "extended", 1},
+    }
+}
\ No newline at end of file
@@ -0,0 +1,75 @@
+package services
+
+import (
+    "context"
+    "math"
+    "testing"
+)
+
+func testRules() []Rule {
+    return []Rule{
+        {Intent: "billing_discrepancies", Type: "keyword", Pattern: "charged", Weight: 2},
+        {Intent: "billing_discrepancies", Type: "phrase", Pattern: "double bill"},
+        {Intent: "refund_processing_issues", Type: "keyword", Pattern: "refund"},
+        {Intent: "order_status_uncertainty", Type: "regex", Pattern: `order\s+#?\d{5,}`},
+    }
+}
+
+func TestRuleClassifierScoresMatchingRules(t *testing.T) {
+    rc, err := NewRuleClassifier(testRules(), DefaultTaxonomy())
+    if err != nil {
+        t.Fatal(err)
+    }
+
+    tests := []struct {
+        message     string
+        intent      string
+        confidence  float64
+        alternative string
+    }{
+        {"I was CHARGED twice", "billing_discrepancies", 1, ""},
+        {"Looks like a double billing", "billing_discrepancies", 1, ""},
+        {"Where is order #123456?", "order_status_uncertainty", 1, ""},
+        // Weight 2 against 1: two thirds of the total
+        {"I was charged and want a refund", "billing_discrepancies", 2.0 / 3, "refund_processing_issues"},
+        // Keywords match whole words only
+        {"My refunded amount is wrong", "general", 0, ""},
+        {"Hello there", "general", 0, ""},
+    }
+
+    for _, tt := range tests {
+        result, err := rc.ClassifyQuery(context.Background(), tt.message)
+        if err != nil {
+            t.Fatalf("%q: %v", tt.message, err)
+        }
+        if result.Intent != tt.intent || math.Abs(result.Confidence-tt.confidence) > 1e-9 {
+            t.Errorf("%q: got %s at %.3f, want %s at %.3f", tt.message, result.Intent, result.Confidence, tt.intent, tt.confidence)
+        }
+        if tt.alternative != "" && (len(result.Alternatives) != 1 || result.Alternatives[0].Intent != tt.alternative) {
+            t.Errorf("%q: alternatives %+v, want %s", tt.message, result.Alternatives, tt.alternative)
+        }
+        if result.Agent == "" {
+            t.Errorf("%q: no agent for %s", tt.message, result.Intent)
+        }
+    }
+}
+
+func TestRuleClassifierRejectsInvalidRules(t *testing.T) {
+    tests := map[string]Rule{
+        "unknown intent":  {Intent: "teleportation", Pattern: "beam"},
+        "unknown type":    {Intent: "billing_discrepancies", Type: "fuzzy", Pattern: "bill"},
+        "invalid pattern": {Intent: "billing_discrepancies", Type: "regex", Pattern: "bill("},
+    }
+
+    for name, rule := range tests {
+        if _, err := NewRuleClassifier([]Rule{rule}, DefaultTaxonomy()); err == nil {
+            t.Errorf("%s: accepted %+v", name, rule)
+        }
+    }
+}
+
+func TestDefaultRulesMatchTheTaxonomy(t *testing.T) {
+    if _, err := NewRuleClassifier(DefaultRules(), DefaultTaxonomy()); err != nil {
+        t.Fatal(err)
+    }
+}
@@ -0,0 +1,240 @@
+package services
+
+import (
+    "context"
+    "fmt"
+    "math"
+    "regexp"
+    "strings"
+    "unicode"
+
+    "customer-query-router/models"
+)
+
+const (
+    MinPriority     = 1
+    DefaultPriority = 3
+    MaxPriority     = 5
+
+    // HighPriority and above goes to senior agents first
+    HighPriority = 4
+
+    SentimentNegative = "negative"
+    SentimentNeutral  = "neutral"
+    SentimentPositive = "positive"
+    // SentimentUnscored marks messages in a language the word lists do not cover
+    SentimentUnscored = "unscored"
+
+    // Scores beyond these bounds count as negative or positive
+    sentimentThreshold = 0.25
+    // At or below this score a customer counts as furious
+    furiousThreshold = -0.6
+)
+
+var negativeWords = map[string]float64{
+    "angry": 2, "furious": 3, "outraged": 3, "livid": 3, "frustrated": 2, "frustrating": 2,
+    "annoyed": 1.5, "upset": 1.5, "disappointed": 1.5, "disappointing": 1.5, "unhappy": 1.5,
+    "terrible": 2, "horrible": 2, "awful": 2, "worst": 2.5, "pathetic": 2.5, "useless": 2,
+    "ridiculous": 2, "unacceptable": 2.5, "disgusting": 2.5, "incompetent": 2.5, "rude": 2,
+    "scam": 3, "fraud": 3, "stolen": 2, "cheated": 2.5, "lied": 2, "hate": 2, "never": 0.5,
+    "broken": 1, "damaged": 1, "wrong": 1, "problem": 0.5, "issue": 0.5, "complaint": 1.5,
+}
+
+var positiveWords = map[string]float64{
+    "thanks": 1, "thank": 1, "great": 1.5, "good": 1, "happy": 1.5, "appreciate": 1.5,
+    "excellent": 2, "wonderful": 2, "love": 2, "pleased": 1.5, "perfect": 2, "awesome": 2,
+    "helpful": 1.5, "glad": 1, "satisfied": 1.5,
+}
+
+var negations = map[string]bool{
+    "not": true, "no": true, "never": true, "isn't": true, "wasn't": true, "don't": true,
+    "didn't": true, "doesn't": true, "can't": true, "cannot": true, "won't": true, "hardly": true,
+}
+
+// urgencySignals raise urgency by their weight, capped at 1
+var urgencySignals = []struct {
+    re     *regexp.Regexp
+    weight float64
+}{
+    {regexp.MustCompile(`(?i)\b(urgent|urgently|asap|emergency|immediately|right now|right away)\b`), 0.5},
+    {regexp.MustCompile(`(?i)\b(today|tonight|within the hour|as soon as possible)\b`), 0.2},
+    {regexp.MustCompile(`(?i)\b(still (waiting|not|haven'?t)|(second|third|\d+(st|nd|rd|th)) time|again|for (days|weeks)|\d+ (days|weeks))\b`), 0.3},
+    {regexp.MustCompile(`(?i)\b(lawyer|legal action|chargeback|dispute|report you|consumer court|bank)\b`), 0.4},
+    {regexp.MustCompile(`(?i)((double|twice|extra|over)[ -]?charged|charged (twice|two times)|unauthori[sz]ed)`), 0.4},
+    {regexp.MustCompile(`!{2,}`), 0.2},
+}
+
+// SentimentAnalyzer scores the tone and urgency of a message with word lists,
+// so every backend gets it without an extra model call
+type SentimentAnalyzer struct{}
+
+func NewSentimentAnalyzer() *SentimentAnalyzer {
+    return &SentimentAnalyzer{}
+}
+
+// Analyze returns the sentiment label, a score from -1 (furious) to 1 (delighted)
+// and an urgency from 0 to 1
+func (sa *SentimentAnalyzer) Analyze(text string) (string, float64, float64) {
+    words := strings.FieldsFunc(text, func(r rune) bool {
+        return !unicode.IsLetter(r) && r != '\''
+    })
+
+    negative, positive := 0.0, 0.0
+    for i, word := range words {
+        lower := strings.ToLower(word)
+        weight := 1.0
+        if len(word) > 2 && word == strings.ToUpper(word) {
+            weight = 1.5 // Shouting
+        }
+        negated := i > 0 && negations[strings.ToLower(words[i-1])]
+
+        if w, ok := negativeWords[lower]; ok {
+            if negated {
+                positive += w * 0.5 * weight
+            } else {
+                negative += w * weight
+            }
+        }
+        if w, ok := positiveWords[lower]; ok {
+            if negated {
+                negative += w * weight
+            } else {
+                positive += w * weight
+            }
+        }
+    }
+
+    score := 0.0
+    if total := negative + positive; total > 0 {
+        // Sure of the tone once there is enough evidence, unsure for a single mild word
+        score = (positive - negative) / total * math.Min(1, total/3)
+    }
+
+    label := SentimentNeutral
+    switch {
+    case score <= -sentimentThreshold:
+        label = SentimentNegative
+    case score >= sentimentThreshold:
+        label = SentimentPositive
+    }
+
+    urgency := 0.0
+    for _, signal := range urgencySignals {
+        if signal.re.MatchString(text) {
+            urgency += signal.weight
+        }
+    }
+    return label, score, math.Min(urgency, 1)
+}
+
+// Priority combines the intent's base priority with sentiment and urgency:
+// a negative tone adds one level, a furious one two, and urgency up to two more
+func Priority(basePriority int, sentimentScore, urgency float64) int {
+    if basePriority == 0 {
+        basePriority = DefaultPriority
+    }
+
+    priority := basePriority
+    if sentimentScore <= -sentimentThreshold {
+        priority++
+    }
+    if sentimentScore <= furiousThreshold {
+        priority++
+    }
+    priority += int(math.Round(urgency * 2))
+
+    if priority > MaxPriority {
+        return MaxPriority
+    }
+    if priority < MinPriority {
+        return MinPriority
+    }
+    return priority
+}
+
+// PriorityClassifier adds sentiment, urgency and a numeric priority to every result
+type PriorityClassifier struct {
+    Classifier
+    analyzer *SentimentAnalyzer
+}
+
+func NewPriorityClassifier(inner Classifier, analyzer *SentimentAnalyzer) *PriorityClassifier {
+    return &PriorityClassifier{
+        Classifier: inner,
+        analyzer:   analyzer,
+    }
+}
+
+func (pc *PriorityClassifier) ClassifyQuery(ctx context.Context, customerMessage string) (*models.ClassificationResult, error) {
+    result, err := pc.Classifier.ClassifyQuery(ctx, customerMessage)
+    if err != nil {
+        return nil, err
+    }
+
+    // The word lists are English, so a translated message is scored in translation. Other
+    // languages keep the intent's base priority rather than passing for neutral.
+    text := customerMessage
+    if result.Translation != "" {
+        text = result.Translation
+    } else if !scorableLanguage(result.Language) {
+        result.Sentiment = SentimentUnscored
+        result.Priority = Priority(intentPriority(pc.GetAllIntents(), result.Intent), 0, 0)
+        return result, nil
+    }
+    result.Sentiment, result.SentimentScore, result.Urgency = pc.analyzer.Analyze(text)
+    result.Priority = Priority(intentPriority(pc.GetAllIntents(), result.Intent), result.SentimentScore, result.Urgency)
+    return result, nil
+}
+
+// scorableLanguage tells whether the word lists cover a language; undetected counts as English
+func scorableLanguage(language string) bool {
+    return language == "" || language == DefaultLanguage
+}
+
+// QueryPriority returns the priority of a query routed with a known intent: the explicit one
+// when set, otherwise the intent's base priority raised by the tone and urgency of English
+// content. Without an analyzer (SCORE_PRIORITY=false) nothing is derived and queries without
+// an explicit priority get DefaultPriority, like classified ones. VIP customers get one level more.
+func QueryPriority(query models.Query, intent string, intents []Intent, analyzer *SentimentAnalyzer) int {
+    priority := query.Priority
+    if priority == 0 && analyzer != nil {
+        score, urgency := 0.0, 0.0
+        if scorableLanguage(query.Language) {
+            _, score, urgency = analyzer.Analyze(query.Content)
+        }
+        priority = Priority(intentPriority(intents, intent), score, urgency)
+    }
+    return VIPPriority(priority, query.VIP)
+}
+
+// VIPPriority raises priority one level for VIP customers, up to MaxPriority
+func VIPPriority(priority int, vip bool) int {
+    if priority == 0 {
+        priority = DefaultPriority
+    }
+    if vip && priority < MaxPriority {
+        priority++
+    }
+    return priority
+}
+
+func intentPriority(intents []Intent, intent string) int {
+    for _, i := range intents {
+        if i.Name == intent {
+            return i.Priority
+        }
+    }
+    return DefaultPriority
+}
+
+// DescribePriority explains a priority for routing reasons and logs
+func DescribePriority(result *models.ClassificationResult) string {
+    description := fmt.Sprintf("priority %d", result.Priority)
+    if result.Sentiment == SentimentNegative {
+        description += ", " + result.Sentiment + " sentiment"
+    }
+    if result.Urgency >= 0.5 {
+        description += ", urgent"
+    }
+    return description
+}
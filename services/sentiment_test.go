@@ -0,0 +1,44 @@
+package services
+
+import (
+    "testing"
+
+    "customer-query-router/models"
+)
+
+func TestQueryPriorityScoresOnlyEnglish(t *testing.T) {
+    analyzer := NewSentimentAnalyzer()
+    intents := DefaultTaxonomy().Intents()
+
+    tests := []struct {
+        name  string
+        query models.Query
+        want  int
+    }{
+        {"furious English", models.Query{Content: "This is UNACCEPTABLE, worst service, I am furious", Language: "en"}, 5},
+        {"undetected language counts as English", models.Query{Content: "This is UNACCEPTABLE, worst service, I am furious"}, 5},
+        {"Spanish keeps the base priority", models.Query{Content: "¡Esto es inaceptable, estoy furioso!", Language: "es"}, 3},
+        {"even with English words in it", models.Query{Content: "furious furious furious", Language: "fr"}, 3},
+        {"explicit priority wins", models.Query{Content: "furious", Language: "en", Priority: 2}, 2},
+        {"VIP", models.Query{Content: "Hola", Language: "es", VIP: true}, 4},
+    }
+
+    for _, tt := range tests {
+        if got := QueryPriority(tt.query, "billing_discrepancies", intents, analyzer); got != tt.want {
+            t.Errorf("%s: priority %d, want %d", tt.name, got, tt.want)
+        }
+    }
+}
+
+func TestQueryPriorityWithoutAnalyzer(t *testing.T) {
+    intents := DefaultTaxonomy().Intents()
+    furious := models.Query{Content: "This is UNACCEPTABLE, worst service, I am furious", Language: "en"}
+
+    if got := QueryPriority(furious, "billing_discrepancies", intents, nil); got != DefaultPriority {
+        t.Errorf("priority %d without scoring, want %d", got, DefaultPriority)
+    }
+    furious.Priority = 5
+    if got := QueryPriority(furious, "billing_discrepancies", intents, nil); got != 5 {
+        t.Errorf("explicit priority became %d, want 5", got)
+    }
+}
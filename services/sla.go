@@ -0,0 +1,140 @@
+package services
+
+import (
+    "log"
+    "sync"
+    "time"
+)
+
+// SLA statuses of a ticket waiting for an agent, and met or breached once it is assigned
+const (
+    SLAOnTrack  = "on_track"
+    SLAAtRisk   = "at_risk"
+    SLABreached = "breached"
+    SLAMet      = "met"
+
+    DefaultSLATarget = 15 * time.Minute
+    // DefaultSLAAtRisk is the share of the target after which a waiting ticket is at risk
+    DefaultSLAAtRisk = 0.8
+)
+
+// SLAMonitor measures time to assign, from ticket creation to its first assignment, against
+// each intent's SLA target from the taxonomy
+type SLAMonitor struct {
+    taxonomy      *Taxonomy
+    defaultTarget time.Duration
+    atRisk        float64
+
+    mu     sync.Mutex
+    counts map[string]*slaCounts // Intent -> outcomes
+}
+
+type slaCounts struct {
+    assigned  int64
+    breached  int64
+    rejected  int64 // Never assigned: no agent and no place in the queue
+    totalWait time.Duration
+    maxWait   time.Duration
+}
+
+func NewSLAMonitor(taxonomy *Taxonomy, defaultTarget time.Duration, atRisk float64) *SLAMonitor {
+    log.Printf("[SLA] Default target %v to assign, at risk after %.0f%%", defaultTarget, atRisk*100)
+
+    return &SLAMonitor{
+        taxonomy:      taxonomy,
+        defaultTarget: defaultTarget,
+        atRisk:        atRisk,
+        counts:        make(map[string]*slaCounts),
+    }
+}
+
+// Target returns the time to assign the intent's SLA allows
+func (m *SLAMonitor) Target(intent string) time.Duration {
+    for _, i := range m.taxonomy.AllIntents() {
+        if i.Name == intent && i.SLA != "" {
+            if target, err := time.ParseDuration(i.SLA); err == nil {
+                return target // Validated when the taxonomy was loaded
+            }
+        }
+    }
+    return m.defaultTarget
+}
+
+// Status tells whether a ticket of intent that has waited this long is on track, at risk or breached
+func (m *SLAMonitor) Status(intent string, waited time.Duration) string {
+    target := m.Target(intent)
+    switch {
+    case waited >= target:
+        return SLABreached
+    case float64(waited) >= m.atRisk*float64(target):
+        return SLAAtRisk
+    default:
+        return SLAOnTrack
+    }
+}
+
+// RecordAssigned counts a ticket of intent that got its first agent after waiting this long
+func (m *SLAMonitor) RecordAssigned(intent string, waited time.Duration) {
+    breached := waited >= m.Target(intent)
+
+    m.mu.Lock()
+    defer m.mu.Unlock()
+
+    counts := m.intentCounts(intent)
+    counts.assigned++
+    counts.totalWait += waited
+    if waited > counts.maxWait {
+        counts.maxWait = waited
+    }
+    if breached {
+        counts.breached++
+    }
+}
+
+// RecordRejected counts a ticket of intent that was turned away without an agent
+func (m *SLAMonitor) RecordRejected(intent string) {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+    m.intentCounts(intent).rejected++
+}
+
+// intentCounts returns the outcomes of intent, creating them on first use. Callers hold mu.
+func (m *SLAMonitor) intentCounts(intent string) *slaCounts {
+    counts, ok := m.counts[intent]
+    if !ok {
+        counts = &slaCounts{}
+        m.counts[intent] = counts
+    }
+    return counts
+}
+
+// Stats reports per intent the target, how many tickets were assigned within it, breached it
+// or were rejected, and the share that met it
+func (m *SLAMonitor) Stats() map[string]interface{} {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+
+    intents := make(map[string]interface{})
+    for intent, counts := range m.counts {
+        stats := map[string]interface{}{
+            "target":   m.Target(intent).String(),
+            "assigned": counts.assigned,
+            "met":      counts.assigned - counts.breached,
+            "breached": counts.breached,
+            "rejected": counts.rejected,
+        }
+        if total := counts.assigned + counts.rejected; total > 0 {
+            stats["compliance"] = float64(counts.assigned-counts.breached) / float64(total)
+        }
+        if counts.assigned > 0 {
+            stats["avg_wait_seconds"] = (counts.totalWait / time.Duration(counts.assigned)).Seconds()
+            stats["max_wait_seconds"] = counts.maxWait.Seconds()
+        }
+        intents[intent] = stats
+    }
+    return map[string]interface{}{
+        "intents":        intents,
+        "default_target": m.defaultTarget.String(),
+        "at_risk_after":  m.atRisk,
+    }
+}
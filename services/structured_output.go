@@ -0,0 +1,166 @@
+package services
+
+import (
+    "encoding/json"
+    "regexp"
+    "strings"
+
+    openai "github.com/sashabaranov/go-openai"
+    "github.com/sashabaranov/go-openai/jsonschema"
+)
+
+const classifyFunctionName = "classify_query"
+
+// secondaryIntentDiscount scores a secondary intent the model gave no confidence for
+// this much lower than the one before it, so it never ties with the primary
+const secondaryIntentDiscount = 0.8
+
+// classificationAnswer is what the model returns, either as function
+// arguments or as JSON/plain text from backends without tool support.
+// Confidence is negative when the model did not report one.
+type classificationAnswer struct {
+    Intent            string   `json:"intent"`
+    Confidence        float64  `json:"confidence"`
+    Rationale         string   `json:"rationale"`
+    AdditionalIntents []additionalIntent `json:"additional_intents"`
+}
+
+// additionalIntent is a secondary intent with its own confidence, negative when not reported.
+// Plain intent names are accepted too.
+type additionalIntent struct {
+    Intent     string  `json:"intent"`
+    Confidence float64 `json:"confidence"`
+}
+
+func (a *additionalIntent) UnmarshalJSON(data []byte) error {
+    var name string
+    if err := json.Unmarshal(data, &name); err == nil {
+        *a = additionalIntent{Intent: name, Confidence: -1}
+        return nil
+    }
+
+    type plain additionalIntent
+    parsed := plain{Confidence: -1}
+    if err := json.Unmarshal(data, &parsed); err != nil {
+        return err
+    }
+    *a = additionalIntent(parsed)
+    return nil
+}
+
+// classifyTool describes the classify_query function with an enum of intent names
+func classifyTool(intents []Intent, multiLabel bool) openai.Tool {
+    names := make([]string, len(intents))
+    for i, intent := range intents {
+        names[i] = intent.Name
+    }
+
+    properties := map[string]jsonschema.Definition{
+        "intent": {
+            Type:        jsonschema.String,
+            Enum:        names,
+            Description: `The single best matching intent, or "general" if none fits`,
+        },
+        "confidence": {
+            Type:        jsonschema.Number,
+            Description: "How sure you are of the intent, from 0 to 1",
+        },
+        "rationale": {
+            Type:        jsonschema.String,
+            Description: "One short sentence explaining the choice",
+        },
+    }
+    required := []string{"intent", "confidence", "rationale"}
+
+    if multiLabel {
+        properties["additional_intents"] = jsonschema.Definition{
+            Type: jsonschema.Array,
+            Items: &jsonschema.Definition{
+                Type: jsonschema.Object,
+                Properties: map[string]jsonschema.Definition{
+                    "intent": {Type: jsonschema.String, Enum: names},
+                    "confidence": {
+                        Type:        jsonschema.Number,
+                        Description: "How sure you are the message raises this intent too, from 0 to 1",
+                    },
+                },
+                Required: []string{"intent", "confidence"},
+            },
+            Description: "Other intents raised in the same message, most important first",
+        }
+    }
+
+    return openai.Tool{
+        Type: openai.ToolTypeFunction,
+        Function: &openai.FunctionDefinition{
+            Name:        classifyFunctionName,
+            Description: "Record the intent of a customer service message",
+            Parameters: &jsonschema.Definition{
+                Type:       jsonschema.Object,
+                Properties: properties,
+                Required:   required,
+            },
+        },
+    }
+}
+
+var jsonObjectPattern = regexp.MustCompile(`(?s)\{.*\}`)
+
+// parseClassificationAnswer reads the model's answer from a tool call when there is one,
+// then from a JSON object in the text, and finally from the plain text itself
+func parseClassificationAnswer(message openai.ChatCompletionMessage, multiLabel bool) classificationAnswer {
+    for _, call := range message.ToolCalls {
+        if call.Function.Name != classifyFunctionName {
+            continue
+        }
+        answer := classificationAnswer{Confidence: -1}
+        if err := json.Unmarshal([]byte(call.Function.Arguments), &answer); err == nil {
+            return answer
+        }
+    }
+
+    content := strings.TrimSpace(message.Content)
+    if match := jsonObjectPattern.FindString(content); match != "" {
+        answer := classificationAnswer{Confidence: -1}
+        if err := json.Unmarshal([]byte(match), &answer); err == nil && answer.Intent != "" {
+            return answer
+        }
+    }
+
+    // Plain text: "intent" or, in multi-label mode, "intent, other_intent"
+    answer := classificationAnswer{Intent: content, Confidence: -1}
+    if multiLabel {
+        labels := strings.Split(content, ",")
+        answer.Intent = labels[0]
+        for _, label := range labels[1:] {
+            answer.AdditionalIntents = append(answer.AdditionalIntents, additionalIntent{Intent: label, Confidence: -1})
+        }
+    }
+    return answer
+}
+
+var nonIntentCharacters = regexp.MustCompile(`[^a-z0-9_]+`)
+
+// normalizeIntent maps loosely formatted model output ("Billing Discrepancies.",
+// "`delivery-problems`", "Intent: refund_processing_issues") onto a known intent name
+func normalizeIntent(intents []Intent, raw string) (string, bool) {
+    cleaned := strings.ToLower(strings.TrimSpace(raw))
+    cleaned = strings.NewReplacer("-", "_", " ", "_").Replace(cleaned)
+    cleaned = strings.Trim(nonIntentCharacters.ReplaceAllString(cleaned, "_"), "_")
+
+    if isKnownIntent(intents, cleaned) {
+        return cleaned, true
+    }
+
+    // Fall back to the first intent name mentioned anywhere in the text,
+    // preferring specific intents over "general"
+    for _, intent := range intents {
+        if intent.Name != "general" && strings.Contains(cleaned, intent.Name) {
+            return intent.Name, true
+        }
+    }
+    if strings.Contains(cleaned, "general") {
+        return "general", true
+    }
+    return "", false
+}
\ No newline at end of file
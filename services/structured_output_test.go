@@ -0,0 +1,44 @@
+package services
+
+import (
+    "testing"
+
+    openai "github.com/sashabaranov/go-openai"
+)
+
+func TestParseClassificationAnswerReadsSecondaryConfidences(t *testing.T) {
+    tests := []struct {
+        name    string
+        message openai.ChatCompletionMessage
+        want    []additionalIntent
+    }{
+        {
+            name: "tool call with confidences",
+            message: openai.ChatCompletionMessage{ToolCalls: []openai.ToolCall{{Function: openai.FunctionCall{
+                Name:      classifyFunctionName,
+                Arguments: `{"intent": "billing_discrepancies", "confidence": 0.9, "additional_intents": [{"intent": "delivery_problems", "confidence": 0.6}]}`,
+            }}}},
+            want: []additionalIntent{{Intent: "delivery_problems", Confidence: 0.6}},
+        },
+        {
+            name:    "JSON with plain names",
+            message: openai.ChatCompletionMessage{Content: `{"intent": "billing_discrepancies", "additional_intents": ["delivery_problems"]}`},
+            want:    []additionalIntent{{Intent: "delivery_problems", Confidence: -1}},
+        },
+        {
+            name:    "plain text",
+            message: openai.ChatCompletionMessage{Content: "billing_discrepancies, delivery_problems"},
+            want:    []additionalIntent{{Intent: " delivery_problems", Confidence: -1}},
+        },
+    }
+
+    for _, tt := range tests {
+        answer := parseClassificationAnswer(tt.message, true)
+        if answer.Intent != "billing_discrepancies" {
+            t.Errorf("%s: primary %q", tt.name, answer.Intent)
+        }
+        if len(answer.AdditionalIntents) != len(tt.want) || answer.AdditionalIntents[0] != tt.want[0] {
+            t.Errorf("%s: additional intents %+v, want %+v", tt.name, answer.AdditionalIntents, tt.want)
+        }
+    }
+}
@@ -0,0 +1,249 @@
+package services
+
+import (
+    "encoding/json"
+    "errors"
+    "fmt"
+    "log"
+    "os"
+    "regexp"
+    "sync"
+    "time"
+)
+
+// Intent is one category of the taxonomy and the team that handles it
+type Intent struct {
+    Name        string   `json:"name"`
+    Agent       string   `json:"team"`
+    Description string   `json:"description,omitempty"`
+    Examples    []string `json:"examples,omitempty"`
+    Enabled     bool     `json:"enabled"`
+    Priority    int      `json:"priority,omitempty"` // Base priority from 1 (low) to 5, 0 means DefaultPriority
+    SLA         string   `json:"sla,omitempty"`      // Target time to assign, e.g. "5m"; empty uses the default target
+}
+
+// UnmarshalJSON defaults Enabled to true so taxonomy files only need to mark disabled intents
+func (i *Intent) UnmarshalJSON(data []byte) error {
+    type rawIntent Intent
+    raw := rawIntent{Enabled: true}
+    if err := json.Unmarshal(data, &raw); err != nil {
+        return err
+    }
+    *i = Intent(raw)
+    return nil
+}
+
+// ErrInvalidTaxonomy marks a taxonomy file that was read but is malformed or fails validation
+var ErrInvalidTaxonomy = errors.New("invalid taxonomy")
+
+var intentNamePattern = regexp.MustCompile(`^[a-z0-9_]+$`)
+
+// Taxonomy holds the intent catalogue and can be reloaded from disk while the server runs.
+// Classifiers read it on every request, so a reload takes effect immediately.
+type Taxonomy struct {
+    mu       sync.RWMutex
+    all      []Intent
+    enabled  []Intent
+    filename string
+    modTime  time.Time
+    version  int
+    onReload []func(intents []Intent)
+}
+
+// DefaultTaxonomy is the built-in catalogue, used when no taxonomy file is configured
+func DefaultTaxonomy() *Taxonomy {
+    taxonomy := &Taxonomy{}
+    if err := taxonomy.set(defaultIntents()); err != nil {
+        panic(err) // The built-in catalogue is always valid
+    }
+    return taxonomy
+}
+
+// LoadTaxonomy reads and validates a JSON taxonomy file
+func LoadTaxonomy(filename string) (*Taxonomy, error) {
+    taxonomy := &Taxonomy{filename: filename}
+    if err := taxonomy.Reload(); err != nil {
+        return nil, err
+    }
+    return taxonomy, nil
+}
+
+// Reload re-reads the taxonomy file. On error the current taxonomy is kept.
+func (t *Taxonomy) Reload() error {
+    if t.filename == "" {
+        return fmt.Errorf("taxonomy was not loaded from a file")
+    }
+
+    info, err := os.Stat(t.filename)
+    if err != nil {
+        return fmt.Errorf("error reading taxonomy file: %w", err)
+    }
+    data, err := os.ReadFile(t.filename)
+    if err != nil {
+        return fmt.Errorf("error reading taxonomy file: %w", err)
+    }
+
+    var file struct {
+        Intents []Intent `json:"intents"`
+    }
+    if err := json.Unmarshal(data, &file); err != nil {
+        return fmt.Errorf("%w: error parsing taxonomy file: %v", ErrInvalidTaxonomy, err)
+    }
+
+    if err := t.set(file.Intents); err != nil {
+        return fmt.Errorf("%w %s: %w", ErrInvalidTaxonomy, t.filename, err)
+    }
+
+    t.mu.Lock()
+    t.modTime = info.ModTime()
+    listeners := t.onReload
+    t.mu.Unlock()
+
+    log.Printf("[TAXONOMY] Loaded %d intents (%d enabled) from %s, version %d",
+        len(file.Intents), len(t.Intents()), t.filename, t.Version())
+    for _, listener := range listeners {
+        listener(t.Intents())
+    }
+    return nil
+}
+
+// OnReload registers a function called with the enabled intents after every successful reload
+func (t *Taxonomy) OnReload(listener func(intents []Intent)) {
+    t.mu.Lock()
+    defer t.mu.Unlock()
+    t.onReload = append(t.onReload, listener)
+}
+
+// Watch polls the taxonomy file and reloads it whenever it changes, until stop is closed
+func (t *Taxonomy) Watch(interval time.Duration, stop <-chan struct{}) {
+    if t.filename == "" || interval <= 0 {
+        return
+    }
+
+    ticker := time.NewTicker(interval)
+    defer ticker.Stop()
+
+    for {
+        select {
+        case <-stop:
+            return
+        case <-ticker.C:
+            info, err := os.Stat(t.filename)
+            if err != nil {
+                log.Printf("[TAXONOMY] WARNING - Cannot stat %s: %v", t.filename, err)
+                continue
+            }
+
+            t.mu.RLock()
+            changed := info.ModTime().After(t.modTime)
+            t.mu.RUnlock()
+
+            if changed {
+                if err := t.Reload(); err != nil {
+                    log.Printf("[TAXONOMY] WARNING - Reload failed, keeping version %d: %v", t.Version(), err)
+                }
+            }
+        }
+    }
+}
+
+// Intents returns the enabled intents in catalogue order
+func (t *Taxonomy) Intents() []Intent {
+    t.mu.RLock()
+    defer t.mu.RUnlock()
+    return t.enabled
+}
+
+// AllIntents returns every intent, including disabled ones
+func (t *Taxonomy) AllIntents() []Intent {
+    t.mu.RLock()
+    defer t.mu.RUnlock()
+    return t.all
+}
+
+// Version increases on every successful load, so caches can tell taxonomies apart
+func (t *Taxonomy) Version() int {
+    t.mu.RLock()
+    defer t.mu.RUnlock()
+    return t.version
+}
+
+func (t *Taxonomy) set(intents []Intent) error {
+    if err := ValidateIntents(intents); err != nil {
+        return err
+    }
+
+    enabled := []Intent{}
+    for _, intent := range intents {
+        if intent.Enabled {
+            enabled = append(enabled, intent)
+        }
+    }
+
+    t.mu.Lock()
+    defer t.mu.Unlock()
+    t.all = intents
+    t.enabled = enabled
+    t.version++
+    return nil
+}
+
+// ValidateIntents checks names are unique snake_case, every intent has a team,
+// and an enabled "general" fallback exists
+func ValidateIntents(intents []Intent) error {
+    if len(intents) == 0 {
+        return fmt.Errorf("no intents defined")
+    }
+
+    seen := make(map[string]bool)
+    hasGeneral := false
+    for i, intent := range intents {
+        if !intentNamePattern.MatchString(intent.Name) {
+            return fmt.Errorf("intent %d: name %q must be lowercase snake_case", i, intent.Name)
+        }
+        if seen[intent.Name] {
+            return fmt.Errorf("intent %q is defined twice", intent.Name)
+        }
+        seen[intent.Name] = true
+
+        if intent.Agent == "" {
+            return fmt.Errorf("intent %q has no team", intent.Name)
+        }
+        if intent.Priority < 0 || intent.Priority > MaxPriority {
+            return fmt.Errorf("intent %q: priority must be between 1 and %d", intent.Name, MaxPriority)
+        }
+        if intent.SLA != "" {
+            if target, err := time.ParseDuration(intent.SLA); err != nil || target <= 0 {
+                return fmt.Errorf("intent %q: sla %q must be a positive duration like \"5m\"", intent.Name, intent.SLA)
+            }
+        }
+        if intent.Name == "general" {
+            if !intent.Enabled {
+                return fmt.Errorf(`the "general" fallback intent cannot be disabled`)
+            }
+            hasGeneral = true
+        }
+    }
+
+    if !hasGeneral {
+        return fmt.Errorf(`the "general" fallback intent is required`)
+    }
+    return nil
+}
+
+// defaultIntents is the built-in intent catalogue
+func defaultIntents() []Intent {
+    return []Intent{
+        {Name: "account_access_issues", Agent: "account-support", Enabled: true},
+        {Name: "billing_discrepancies", Agent: "billing-team", Enabled: true},
+        {Name: "delivery_problems", Agent: "logistics-team", Enabled: true},
+        {Name: "installation_support_requests", Agent: "technical-support", Enabled: true},
+        {Name: "order_cancellation_requests", Agent: "order-management", Enabled: true},
+        {Name: "order_status_uncertainty", Agent: "order-tracking", Enabled: true},
+        {Name: "product_availability_inquiries", Agent: "inventory-team", Enabled: true, Priority: 2},
+        {Name: "refund_processing_issues", Agent: "finance-team", Enabled: true},
+        {Name: "return_process_inquiries", Agent: "returns-team", Enabled: true},
+        {Name: "warranty_terms_inquiries", Agent: "warranty-team", Enabled: true, Priority: 2},
+        {Name: "general", Agent: "general-agent", Enabled: true}, // Fallback
+    }
+}
\ No newline at end of file
@@ -0,0 +1,105 @@
+package services
+
+import (
+    "errors"
+    "os"
+    "path/filepath"
+    "testing"
+)
+
+func TestValidateIntents(t *testing.T) {
+    general := Intent{Name: "general", Agent: "general-support", Enabled: true}
+    billing := Intent{Name: "billing_discrepancies", Agent: "billing-team", Enabled: true}
+
+    tests := []struct {
+        name    string
+        intents []Intent
+        valid   bool
+    }{
+        {"valid", []Intent{billing, general}, true},
+        {"general may come first", []Intent{general, billing}, true},
+        {"disabled intent", []Intent{{Name: "legacy", Agent: "team"}, general}, true},
+        {"priority and sla", []Intent{{Name: "urgent", Agent: "team", Enabled: true, Priority: 5, SLA: "5m"}, general}, true},
+        {"empty", nil, false},
+        {"no general", []Intent{billing}, false},
+        {"general disabled", []Intent{billing, {Name: "general", Agent: "general-support"}}, false},
+        {"duplicate", []Intent{billing, billing, general}, false},
+        {"not snake_case", []Intent{{Name: "Billing-Issues", Agent: "team", Enabled: true}, general}, false},
+        {"no team", []Intent{{Name: "billing", Enabled: true}, general}, false},
+        {"priority out of range", []Intent{{Name: "billing", Agent: "team", Enabled: true, Priority: 6}, general}, false},
+        {"invalid sla", []Intent{{Name: "billing", Agent: "team", Enabled: true, SLA: "soon"}, general}, false},
+        {"negative sla", []Intent{{Name: "billing", Agent: "team", Enabled: true, SLA: "-5m"}, general}, false},
+    }
+
+    for _, tt := range tests {
+        if err := ValidateIntents(tt.intents); (err == nil) != tt.valid {
+            t.Errorf("%s: error %v, want valid=%v", tt.name, err, tt.valid)
+        }
+    }
+}
+
+func writeTaxonomy(t *testing.T, filename, content string) {
+    t.Helper()
+    if err := os.WriteFile(filename, []byte(content), 0o644); err != nil {
+        t.Fatal(err)
+    }
+}
+
+func TestTaxonomyReloadSwapsOrKeepsTheCatalogue(t *testing.T) {
+    filename := filepath.Join(t.TempDir(), "taxonomy.json")
+    writeTaxonomy(t, filename, `{"intents": [
+        {"name": "billing_discrepancies", "team": "billing-team"},
+        {"name": "legacy_requests", "team": "legacy-team", "enabled": false},
+        {"name": "general", "team": "general-support"}
+    ]}`)
+
+    taxonomy, err := LoadTaxonomy(filename)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if len(taxonomy.AllIntents()) != 3 || len(taxonomy.Intents()) != 2 {
+        t.Fatalf("loaded %d intents, %d enabled, want 3 and 2", len(taxonomy.AllIntents()), len(taxonomy.Intents()))
+    }
+    var reloaded [][]Intent
+    taxonomy.OnReload(func(intents []Intent) {
+        reloaded = append(reloaded, intents)
+    })
+
+    steps := []struct {
+        name    string
+        content string
+        invalid bool // Read but rejected with ErrInvalidTaxonomy
+        intents int  // Enabled intents afterwards
+    }{
+        {"swapped", `{"intents": [
+            {"name": "billing_discrepancies", "team": "billing-team"},
+            {"name": "delivery_problems", "team": "logistics-team"},
+            {"name": "general", "team": "general-support"}
+        ]}`, false, 3},
+        {"malformed", `{"intents": [`, true, 3},
+        {"fails validation", `{"intents": [{"name": "billing_discrepancies", "team": "billing-team"}]}`, true, 3},
+    }
+
+    for _, step := range steps {
+        writeTaxonomy(t, filename, step.content)
+        err := taxonomy.Reload()
+        if step.invalid != (err != nil) || err != nil && !errors.Is(err, ErrInvalidTaxonomy) {
+            t.Errorf("%s: error %v", step.name, err)
+        }
+        if len(taxonomy.Intents()) != step.intents {
+            t.Errorf("%s: %d enabled intents, want %d", step.name, len(taxonomy.Intents()), step.intents)
+        }
+    }
+    if taxonomy.Version() != 2 || len(reloaded) != 1 {
+        t.Errorf("version %d after %d reload notifications, want 2 after 1", taxonomy.Version(), len(reloaded))
+    }
+
+    // A file that cannot be read is not an invalid taxonomy
+    os.Remove(filename)
+    if err := taxonomy.Reload(); err == nil || errors.Is(err, ErrInvalidTaxonomy) {
+        t.Errorf("missing file: error %v", err)
+    }
+    if len(taxonomy.Intents()) != 3 {
+        t.Error("a missing file replaced the catalogue")
+    }
+}
@@ -0,0 +1,87 @@
+package services
+
+import (
+    "math"
+)
+
+// TFIDFIndex scores how similar a text is to each document of a fixed corpus
+type TFIDFIndex struct {
+    idf     map[string]float64
+    vectors []map[string]float64
+}
+
+func NewTFIDFIndex(documents []string) *TFIDFIndex {
+    documentFrequency := make(map[string]int)
+    tokenized := make([][]string, len(documents))
+    for i, document := range documents {
+        tokenized[i] = Tokenize(document)
+        seen := make(map[string]bool)
+        for _, token := range tokenized[i] {
+            if !seen[token] {
+                seen[token] = true
+                documentFrequency[token]++
+            }
+        }
+    }
+
+    index := &TFIDFIndex{
+        idf:     make(map[string]float64, len(documentFrequency)),
+        vectors: make([]map[string]float64, len(documents)),
+    }
+    for token, frequency := range documentFrequency {
+        index.idf[token] = math.Log(float64(1+len(documents))/float64(1+frequency)) + 1
+    }
+    for i, tokens := range tokenized {
+        index.vectors[i] = index.vectorize(tokens)
+    }
+    return index
+}
+
+// Similarities returns the cosine similarity of text to every indexed document
+func (index *TFIDFIndex) Similarities(text string) []float64 {
+    query := index.Vectorize(text)
+    similarities := make([]float64, len(index.vectors))
+    for i, vector := range index.vectors {
+        similarities[i] = cosine(query, vector)
+    }
+    return similarities
+}
+
+// Vectorize turns text into a unit-length TF-IDF vector. Unknown tokens get the highest IDF.
+func (index *TFIDFIndex) Vectorize(text string) map[string]float64 {
+    return index.vectorize(Tokenize(text))
+}
+
+func (index *TFIDFIndex) vectorize(tokens []string) map[string]float64 {
+    vector := make(map[string]float64)
+    unseenIDF := math.Log(float64(1+len(index.vectors))) + 1
+    for _, token := range tokens {
+        idf, ok := index.idf[token]
+        if !ok {
+            idf = unseenIDF
+        }
+        vector[token] += idf
+    }
+
+    norm := 0.0
+    for _, weight := range vector {
+        norm += weight * weight
+    }
+    norm = math.Sqrt(norm)
+    for token := range vector {
+        vector[token] /= norm
+    }
+    return vector
+}
+
+// cosine of two unit-length vectors
+func cosine(a, b map[string]float64) float64 {
+    if len(a) > len(b) {
+        a, b = b, a
+    }
+    dot := 0.0
+    for token, weight := range a {
+        dot += weight * b[token]
+    }
+    return dot
+}
\ No newline at end of file
@@ -0,0 +1,13 @@
+package services
+
+import (
+    "fmt"
+    "sync/atomic"
+)
+
+var ticketCounter int64
+
+// NewTicketID returns a process-unique ticket identifier
+func NewTicketID() string {
+    return fmt.Sprintf("TKT-%06d", atomic.AddInt64(&ticketCounter, 1))
+}
\ No newline at end of file
@@ -0,0 +1,39 @@
+package services
+
+import (
+    "regexp"
+    "strings"
+    "unicode"
+)
+
+// redactionToken matches the placeholders left by the Redactor, e.g. [ORDER_ID_1]
+var redactionToken = regexp.MustCompile(`\[[A-Z_]+_\d+\]`)
+
+// stopWords are dropped by Tokenize, they carry no intent signal
+var stopWords = map[string]bool{
+    "a": true, "an": true, "and": true, "are": true, "as": true, "at": true, "be": true,
+    "but": true, "by": true, "can": true, "could": true, "do": true, "for": true, "from": true,
+    "have": true, "hi": true, "hello": true, "i": true, "if": true, "in": true, "is": true,
+    "it": true, "it's": true, "i'm": true, "me": true, "my": true, "of": true, "on": true,
+    "or": true, "please": true, "so": true, "that": true, "the": true, "this": true, "to": true,
+    "was": true, "we": true, "with": true, "you": true, "your": true, "thank": true, "thanks": true,
+}
+
+// Tokenize lowercases text and splits it into words, dropping stop words,
+// single characters and redaction tokens. Apostrophes inside words are kept ("haven't").
+func Tokenize(text string) []string {
+    text = redactionToken.ReplaceAllString(text, " ")
+    fields := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+        return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '\''
+    })
+
+    tokens := make([]string, 0, len(fields))
+    for _, field := range fields {
+        field = strings.Trim(field, "'")
+        if len(field) < 2 || stopWords[field] {
+            continue
+        }
+        tokens = append(tokens, field)
+    }
+    return tokens
+}
\ No newline at end of file
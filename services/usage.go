@@ -0,0 +1,287 @@
+package services
+
+import (
+    "context"
+    "fmt"
+    "log"
+    "sort"
+    "strings"
+    "sync"
+    "time"
+
+    "customer-query-router/models"
+)
+
+const (
+    // DefaultClient is billed for requests that do not identify their API client
+    DefaultClient = "anonymous"
+
+    // TranslationUsage is the intent translation calls are recorded under
+    TranslationUsage = "translation"
+
+    usageDayFormat   = "2006-01-02"
+    usageHistoryDays = 31
+)
+
+// ErrBudgetExceeded is returned while a daily budget is used up. It wraps
+// ErrClassifierUnavailable so a fallback chain moves on to a cheaper backend.
+var ErrBudgetExceeded = fmt.Errorf("%w: daily budget exceeded", ErrClassifierUnavailable)
+
+// ModelPrice is what a model costs in USD per million tokens
+type ModelPrice struct {
+    Prompt     float64 `json:"prompt"`
+    Completion float64 `json:"completion"`
+}
+
+// DefaultModelPrices are list prices. Models are matched by longest prefix,
+// unknown models (local servers) cost nothing.
+var DefaultModelPrices = map[string]ModelPrice{
+    "gpt-3.5-turbo": {Prompt: 0.50, Completion: 1.50},
+    "gpt-4o-mini":   {Prompt: 0.15, Completion: 0.60},
+    "gpt-4o":        {Prompt: 2.50, Completion: 10.00},
+    "gpt-4-turbo":   {Prompt: 10.00, Completion: 30.00},
+    "gpt-4":         {Prompt: 30.00, Completion: 60.00},
+}
+
+// PriceForModel looks up the list price of model
+func PriceForModel(model string) ModelPrice {
+    best := ""
+    for name := range DefaultModelPrices {
+        if strings.HasPrefix(model, name) && len(name) > len(best) {
+            best = name
+        }
+    }
+    return DefaultModelPrices[best]
+}
+
+// priceForConfig is the price configured for an OpenAI model, or its list price
+func priceForConfig(config OpenAIConfig) ModelPrice {
+    if config.PromptPrice > 0 || config.CompletionPrice > 0 {
+        return ModelPrice{Prompt: config.PromptPrice, Completion: config.CompletionPrice}
+    }
+    return PriceForModel(config.Model)
+}
+
+// Cost of a call in USD
+func (price ModelPrice) Cost(promptTokens, completionTokens int) float64 {
+    return (float64(promptTokens)*price.Prompt + float64(completionTokens)*price.Completion) / 1e6
+}
+
+type clientKey struct{}
+
+// WithClient tags a request with the API client its usage is billed to
+func WithClient(ctx context.Context, client string) context.Context {
+    return context.WithValue(ctx, clientKey{}, client)
+}
+
+// ClientFromContext returns the API client set by WithClient, or DefaultClient
+func ClientFromContext(ctx context.Context) string {
+    if client, ok := ctx.Value(clientKey{}).(string); ok && client != "" {
+        return client
+    }
+    return DefaultClient
+}
+
+// UsageTotals adds up the token usage of a group of requests
+type UsageTotals struct {
+    Requests         int64   `json:"requests"`
+    PromptTokens     int64   `json:"prompt_tokens"`
+    CompletionTokens int64   `json:"completion_tokens"`
+    TotalTokens      int64   `json:"total_tokens"`
+    CostUSD          float64 `json:"cost_usd"`
+}
+
+func (totals *UsageTotals) add(usage *models.TokenUsage) {
+    totals.Requests++
+    totals.PromptTokens += int64(usage.PromptTokens)
+    totals.CompletionTokens += int64(usage.CompletionTokens)
+    totals.TotalTokens += int64(usage.TotalTokens)
+    totals.CostUSD += usage.CostUSD
+}
+
+// DailyUsage is the usage of one UTC day, broken down by intent, model and API client
+type DailyUsage struct {
+    Date     string                  `json:"date"`
+    Total    UsageTotals             `json:"total"`
+    ByIntent map[string]*UsageTotals `json:"by_intent"`
+    ByModel  map[string]*UsageTotals `json:"by_model"`
+    ByClient map[string]*UsageTotals `json:"by_client"`
+}
+
+// clone copies the day so it can be encoded without holding the tracker's lock
+func (day *DailyUsage) clone() *DailyUsage {
+    return &DailyUsage{
+        Date:     day.Date,
+        Total:    day.Total,
+        ByIntent: cloneGroups(day.ByIntent),
+        ByModel:  cloneGroups(day.ByModel),
+        ByClient: cloneGroups(day.ByClient),
+    }
+}
+
+func cloneGroups(groups map[string]*UsageTotals) map[string]*UsageTotals {
+    copied := make(map[string]*UsageTotals, len(groups))
+    for key, totals := range groups {
+        value := *totals
+        copied[key] = &value
+    }
+    return copied
+}
+
+func newDailyUsage(date string) *DailyUsage {
+    return &DailyUsage{
+        Date:     date,
+        ByIntent: make(map[string]*UsageTotals),
+        ByModel:  make(map[string]*UsageTotals),
+        ByClient: make(map[string]*UsageTotals),
+    }
+}
+
+func addTo(groups map[string]*UsageTotals, key string, usage *models.TokenUsage) {
+    totals, ok := groups[key]
+    if !ok {
+        totals = &UsageTotals{}
+        groups[key] = totals
+    }
+    totals.add(usage)
+}
+
+// UsageTracker aggregates token usage and enforces optional daily budgets in USD:
+// dailyBudget across all clients and clientBudget for each client (0 means unlimited).
+// The last usageHistoryDays days are kept.
+type UsageTracker struct {
+    dailyBudget  float64
+    clientBudget float64
+
+    mu   sync.Mutex
+    days map[string]*DailyUsage
+    now  func() time.Time
+}
+
+func NewUsageTracker(dailyBudget, clientBudget float64) *UsageTracker {
+    log.Printf("[USAGE] Daily budget $%g, per client $%g (0 is unlimited)", dailyBudget, clientBudget)
+
+    return &UsageTracker{
+        dailyBudget:  dailyBudget,
+        clientBudget: clientBudget,
+        days:         make(map[string]*DailyUsage),
+        now:          time.Now,
+    }
+}
+
+// Record adds the usage of one request
+func (ut *UsageTracker) Record(client, intent string, usage *models.TokenUsage) {
+    ut.mu.Lock()
+    defer ut.mu.Unlock()
+
+    day := ut.today()
+    day.Total.add(usage)
+    addTo(day.ByIntent, intent, usage)
+    addTo(day.ByModel, usage.Model, usage)
+    addTo(day.ByClient, client, usage)
+}
+
+// CheckBudget returns ErrBudgetExceeded once today's spend reaches a budget
+func (ut *UsageTracker) CheckBudget(client string) error {
+    ut.mu.Lock()
+    defer ut.mu.Unlock()
+
+    day := ut.today()
+    if ut.dailyBudget > 0 && day.Total.CostUSD >= ut.dailyBudget {
+        return fmt.Errorf("%w: spent $%.4f of $%g today", ErrBudgetExceeded, day.Total.CostUSD, ut.dailyBudget)
+    }
+    if totals, ok := day.ByClient[client]; ok && ut.clientBudget > 0 && totals.CostUSD >= ut.clientBudget {
+        return fmt.Errorf("%w: %s spent $%.4f of $%g today", ErrBudgetExceeded, client, totals.CostUSD, ut.clientBudget)
+    }
+    return nil
+}
+
+// today returns the bucket for the current UTC day, dropping days beyond the history. Callers hold mu.
+func (ut *UsageTracker) today() *DailyUsage {
+    date := ut.now().UTC().Format(usageDayFormat)
+    day, ok := ut.days[date]
+    if ok {
+        return day
+    }
+
+    day = newDailyUsage(date)
+    ut.days[date] = day
+    cutoff := ut.now().UTC().AddDate(0, 0, -usageHistoryDays).Format(usageDayFormat)
+    for old := range ut.days {
+        if old <= cutoff {
+            delete(ut.days, old)
+        }
+    }
+    return day
+}
+
+// Report returns usage per day, newest first, and the state of the budgets
+func (ut *UsageTracker) Report() map[string]interface{} {
+    ut.mu.Lock()
+    defer ut.mu.Unlock()
+
+    today := ut.today()
+    days := make([]*DailyUsage, 0, len(ut.days))
+    for _, day := range ut.days {
+        days = append(days, day.clone())
+    }
+    sort.Slice(days, func(i, j int) bool {
+        return days[i].Date > days[j].Date
+    })
+
+    return map[string]interface{}{
+        "today": today.Total,
+        "budgets": map[string]interface{}{
+            "daily_usd":            ut.dailyBudget,
+            "per_client_daily_usd": ut.clientBudget,
+            "daily_exceeded":       ut.dailyBudget > 0 && today.Total.CostUSD >= ut.dailyBudget,
+        },
+        "days": days,
+    }
+}
+
+// MeteredClassifier records the token usage of a backend and stops calling it
+// while a daily budget is exceeded. Put a cheaper backend after it in the fallback chain.
+type MeteredClassifier struct {
+    Classifier
+    tracker *UsageTracker
+
+    mu       sync.Mutex
+    rejected int64
+}
+
+func NewMeteredClassifier(inner Classifier, tracker *UsageTracker) *MeteredClassifier {
+    return &MeteredClassifier{
+        Classifier: inner,
+        tracker:    tracker,
+    }
+}
+
+func (mc *MeteredClassifier) ClassifyQuery(ctx context.Context, customerMessage string) (*models.ClassificationResult, error) {
+    client := ClientFromContext(ctx)
+    if err := mc.tracker.CheckBudget(client); err != nil {
+        mc.mu.Lock()
+        mc.rejected++
+        mc.mu.Unlock()
+        log.Printf("[USAGE] Not calling %s: %v", mc.Classifier.Name(), err)
+        return nil, err
+    }
+
+    result, err := mc.Classifier.ClassifyQuery(ctx, customerMessage)
+    if err != nil {
+        return nil, err
+    }
+    if result.Usage != nil {
+        mc.tracker.Record(client, result.Intent, result.Usage)
+    }
+    return result, nil
+}
+
+func (mc *MeteredClassifier) GetStats() map[string]interface{} {
+    stats := mc.Classifier.GetStats()
+
+    mc.mu.Lock()
+    defer mc.mu.Unlock()
+    stats["budget_rejections"] = mc.rejected
+    return stats
+}
@@ -0,0 +1,125 @@
+package services
+
+import (
+    "context"
+    "errors"
+    "testing"
+    "time"
+
+    "customer-query-router/models"
+)
+
+func TestPriceForModel(t *testing.T) {
+    tests := []struct {
+        model string
+        want  ModelPrice
+    }{
+        {"gpt-4o-mini-2024-07-18", DefaultModelPrices["gpt-4o-mini"]},
+        {"gpt-4o-2024-08-06", DefaultModelPrices["gpt-4o"]},
+        {"gpt-4-turbo", DefaultModelPrices["gpt-4-turbo"]},
+        {"gpt-4-0613", DefaultModelPrices["gpt-4"]},
+        {"llama3", ModelPrice{}},
+    }
+    for _, tt := range tests {
+        if got := PriceForModel(tt.model); got != tt.want {
+            t.Errorf("PriceForModel(%q) = %+v, want %+v", tt.model, got, tt.want)
+        }
+    }
+
+    if cost := (ModelPrice{Prompt: 2.5, Completion: 10}).Cost(2000, 500); cost != 0.01 {
+        t.Errorf("2000 + 500 tokens cost $%g, want $0.01", cost)
+    }
+}
+
+func testUsage(cost float64) *models.TokenUsage {
+    return &models.TokenUsage{Model: "gpt-4o", PromptTokens: 100, CompletionTokens: 20, TotalTokens: 120, CostUSD: cost}
+}
+
+func TestUsageTrackerAddsUpUsage(t *testing.T) {
+    tracker := NewUsageTracker(0, 0)
+    tracker.Record("web", "billing_discrepancies", testUsage(0.01))
+    tracker.Record("web", "delivery_problems", testUsage(0.02))
+    tracker.Record("mobile", "billing_discrepancies", testUsage(0.04))
+
+    report := tracker.Report()
+    today := report["today"].(UsageTotals)
+    if today.Requests != 3 || today.PromptTokens != 300 || today.CompletionTokens != 60 || today.TotalTokens != 360 {
+        t.Errorf("today %+v, want 3 requests of 100 + 20 tokens", today)
+    }
+    day := report["days"].([]*DailyUsage)[0]
+    for _, tt := range []struct {
+        name   string
+        totals *UsageTotals
+        cost   float64
+    }{
+        {"total", &day.Total, 0.07},
+        {"billing", day.ByIntent["billing_discrepancies"], 0.05},
+        {"web", day.ByClient["web"], 0.03},
+        {"mobile", day.ByClient["mobile"], 0.04},
+        {"gpt-4o", day.ByModel["gpt-4o"], 0.07},
+    } {
+        if tt.totals == nil || tt.totals.CostUSD < tt.cost-1e-9 || tt.totals.CostUSD > tt.cost+1e-9 {
+            t.Errorf("%s: %+v, want $%g", tt.name, tt.totals, tt.cost)
+        }
+    }
+}
+
+func TestUsageTrackerBudgets(t *testing.T) {
+    now := time.Date(2024, 3, 1, 23, 0, 0, 0, time.UTC)
+    tracker := NewUsageTracker(0.5, 0.25)
+    tracker.now = func() time.Time { return now }
+
+    tracker.Record("web", "general", testUsage(0.25))
+    if err := tracker.CheckBudget("web"); !errors.Is(err, ErrBudgetExceeded) {
+        t.Errorf("web at its client budget: %v, want ErrBudgetExceeded", err)
+    }
+    if err := tracker.CheckBudget("mobile"); err != nil {
+        t.Errorf("mobile is refused with web's budget used up: %v", err)
+    }
+
+    tracker.Record("mobile", "general", testUsage(0.125))
+    tracker.Record(DefaultClient, "general", testUsage(0.125))
+    err := tracker.CheckBudget("another")
+    if !errors.Is(err, ErrBudgetExceeded) || !errors.Is(err, ErrClassifierUnavailable) {
+        t.Errorf("at the daily budget: %v, want ErrBudgetExceeded as an unavailable classifier", err)
+    }
+    if exceeded := tracker.Report()["budgets"].(map[string]interface{})["daily_exceeded"]; exceeded != true {
+        t.Errorf("daily_exceeded is %v", exceeded)
+    }
+
+    now = now.Add(2 * time.Hour) // The next UTC day
+    if err := tracker.CheckBudget("web"); err != nil {
+        t.Errorf("refused the next day: %v", err)
+    }
+}
+
+func TestMeteredClassifierStopsAtTheBudget(t *testing.T) {
+    inner := &resultClassifier{
+        scriptedClassifier: scriptedClassifier{name: "openai"},
+        result:             models.ClassificationResult{Intent: "billing_discrepancies", Usage: testUsage(0.03)},
+    }
+    tracker := NewUsageTracker(0, 0.05)
+    metered := NewMeteredClassifier(inner, tracker)
+    web := WithClient(context.Background(), "web")
+
+    for i, wantErr := range []bool{false, false, true} {
+        _, err := metered.ClassifyQuery(web, "I was charged twice")
+        if wantErr != errors.Is(err, ErrBudgetExceeded) {
+            t.Errorf("call %d: %v", i, err)
+        }
+    }
+    if inner.calls != 2 {
+        t.Errorf("backend called %d times, want 2", inner.calls)
+    }
+    if _, err := metered.ClassifyQuery(context.Background(), "I was charged twice"); err != nil {
+        t.Errorf("anonymous refused with web's budget used up: %v", err)
+    }
+
+    day := tracker.Report()["days"].([]*DailyUsage)[0]
+    if day.ByClient["web"].Requests != 2 || day.ByClient[DefaultClient].Requests != 1 || day.ByIntent["billing_discrepancies"].Requests != 3 {
+        t.Errorf("recorded %+v by client, %+v by intent", day.ByClient, day.ByIntent)
+    }
+    if rejected := metered.GetStats()["budget_rejections"]; rejected != int64(1) {
+        t.Errorf("budget_rejections is %v, want 1", rejected)
+    }
+}
@@ -74,7 +74,7 @@ async function classifyMessage() {
         // Step 2: AI Analysis
         activateStep(2);
         
-        // Make API call
+        // Classify only: routing from the demo would reserve real agent capacity
         const response = await fetch('/api/classify', {
             method: 'POST',
             headers: {
@@ -85,12 +85,12 @@ async function classifyMessage() {
             })
         });
         
+        const data = await response.json();
+        
         if (!response.ok) {
-            throw new Error('HTTP error! status: ' + response.status);
+            throw new Error(data.error || 'HTTP error! status: ' + response.status);
         }
         
-        const data = await response.json();
-        
         await sleep(1000);
         completeStep(2);
         
@@ -108,7 +108,7 @@ async function classifyMessage() {
         const endTime = Date.now();
         const processingTime = ((endTime - startTime) / 1000).toFixed(2);
         
-        displayResults(data.intent, data.recommended_agent, processingTime);
+        displayResults(data.intent, data.recommended_agent, data.confidence, processingTime);
         
     } catch (error) {
         console.error('Error:', error);
@@ -122,10 +122,11 @@ async function classifyMessage() {
 }
 
 // Display the classification results
-function displayResults(intent, agent, processingTime) {
+function displayResults(intent, agent, confidence, processingTime) {
     // Update result values
     const detectedIntentElement = document.getElementById('detectedIntent');
     const assignedAgentElement = document.getElementById('assignedAgent');
+    const confidenceElement = document.getElementById('confidence');
     const processingTimeElement = document.getElementById('processingTime');
     
     if (detectedIntentElement) {
@@ -136,6 +137,10 @@ function displayResults(intent, agent, processingTime) {
         assignedAgentElement.textContent = agent.replace(/-/g, ' ').toUpperCase();
     }
     
+    if (confidenceElement) {
+        confidenceElement.textContent = Math.round(confidence * 100) + '%';
+    }
+    
     if (processingTimeElement) {
         processingTimeElement.textContent = processingTime + 's';
     }